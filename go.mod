@@ -4,26 +4,27 @@ go 1.19
 
 require (
 	github.com/davecgh/go-spew v1.1.1
+	github.com/gorilla/websocket v1.5.0
 	github.com/pkg/errors v0.9.1
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/smacker/go-tree-sitter v0.0.0-20220209044044-0d3022e933c3
 	github.com/spf13/cobra v1.3.0
+	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.7.0
 	go.lsp.dev/jsonrpc2 v0.9.0
+	go.lsp.dev/pkg v0.0.0-20210323044036-f7deec69b52e
 	go.lsp.dev/protocol v0.11.2
 	go.lsp.dev/uri v0.3.0
 	go.uber.org/zap v1.20.0
 	golang.org/x/exp v0.0.0-20220414153411-bcd21879b8fd
+	gopkg.in/yaml.v3 v3.0.0
 )
 
 require (
 	github.com/benbjohnson/clock v1.1.0 // indirect
 	github.com/francoispqt/gojay v1.2.13 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/segmentio/encoding v0.2.7 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-	go.lsp.dev/pkg v0.0.0-20210323044036-f7deec69b52e // indirect
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0 // indirect
 )
@@ -11,6 +11,21 @@ type Analyzer struct {
 	builtins *Builtins
 	context  context.Context
 	logger   *zap.Logger
+	// structConstructors are functions that create struct-like values whose
+	// fields are given as keyword arguments
+	structConstructors map[string]bool
+	// typeChecking enables type mismatch diagnostics
+	typeChecking bool
+	// disabledDiagnostics are the codes of diagnostics that aren't reported
+	disabledDiagnostics map[string]bool
+
+	// starlarkBuiltins are the builtins of the language itself, which are
+	// shared by all profiles
+	starlarkBuiltins *Builtins
+	profiles         []Profile
+	profileBuiltins  []*Builtins
+	// profile is the name of the profile selected by ForDocument
+	profile string
 }
 
 type AnalyzerOption func(*Analyzer) error
@@ -19,6 +34,8 @@ func NewAnalyzer(ctx context.Context, opts ...AnalyzerOption) (*Analyzer, error)
 	analyzer := Analyzer{
 		context:  ctx,
 		builtins: NewBuiltins(),
+
+		structConstructors: map[string]bool{"struct": true},
 	}
 	logger := protocol.LoggerFromContext(ctx)
 	logger = logger.Named("analyzer")
@@ -31,6 +48,8 @@ func NewAnalyzer(ctx context.Context, opts ...AnalyzerOption) (*Analyzer, error)
 		}
 	}
 
+	analyzer.resolveProfiles()
+
 	if len(analyzer.builtins.Functions) != 0 {
 		logger.Debug("registered built-in functions", zap.Int("count", len(analyzer.builtins.Functions)))
 	}
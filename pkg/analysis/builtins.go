@@ -107,14 +107,17 @@ func WithStarlarkBuiltins() AnalyzerOption {
 		if err != nil {
 			return errors.Wrapf(err, "loading builtins from builtins.py")
 		}
-		analyzer.builtins.Update(&Builtins{
+		starlark := NewBuiltins()
+		starlark.Update(&Builtins{
 			Symbols: []query.Symbol{
 				{Name: "False", Kind: protocol.SymbolKindBoolean},
 				{Name: "None", Kind: protocol.SymbolKindNull},
 				{Name: "True", Kind: protocol.SymbolKindBoolean},
 			},
 		})
-		analyzer.builtins.Update(builtins)
+		starlark.Update(builtins)
+		analyzer.builtins.Update(starlark)
+		analyzer.starlarkBuiltins = starlark
 		return nil
 	}
 }
@@ -298,6 +301,71 @@ func copyBuiltinsToParent(mod, parentMod *Builtins, modName string) {
 	})
 }
 
+// BuiltinModule is a module of builtins, e.g. `os.path`.
+type BuiltinModule struct {
+	// Name is the dotted name of the module, or empty for the top level.
+	Name string
+	// Functions and Variables are named relative to the module.
+	Functions []query.Signature
+	Variables []query.Symbol
+}
+
+// Modules splits the functions and variables of the builtins into those of
+// the top level and those of the submodules that copyBuiltinsToParent
+// created, with modules, functions and variables in the order of their
+// names.
+func (b *Builtins) Modules() []BuiltinModule {
+	modules := map[string]*BuiltinModule{"": {}}
+	module := func(name string) *BuiltinModule {
+		m, found := modules[name]
+		if !found {
+			m = &BuiltinModule{Name: name}
+			modules[name] = m
+		}
+		return m
+	}
+
+	for fnName, fn := range b.Functions {
+		modName := ""
+		if i := strings.LastIndex(fnName, "."); i >= 0 {
+			modName, fn.Name = fnName[:i], fnName[i+1:]
+		}
+		m := module(modName)
+		m.Functions = append(m.Functions, fn)
+	}
+
+	seen := make(map[string]bool)
+	var walk func(modName string, symbols []query.Symbol)
+	walk = func(modName string, symbols []query.Symbol) {
+		for _, sym := range symbols {
+			qualified := sym.Name
+			if modName != "" {
+				qualified = modName + "." + sym.Name
+			}
+			switch {
+			case len(sym.Children) > 0:
+				walk(qualified, sym.Children)
+			case sym.Kind == protocol.SymbolKindFunction || sym.Kind == protocol.SymbolKindMethod:
+				// part of the functions
+			case !seen[qualified]:
+				seen[qualified] = true
+				m := module(modName)
+				m.Variables = append(m.Variables, sym)
+			}
+		}
+	}
+	walk("", b.Symbols)
+
+	result := make([]BuiltinModule, 0, len(modules))
+	for _, m := range modules {
+		sort.Slice(m.Functions, func(i, j int) bool { return m.Functions[i].Name < m.Functions[j].Name })
+		sort.SliceStable(m.Variables, func(i, j int) bool { return m.Variables[i].Name < m.Variables[j].Name })
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
 func LoadBuiltins(ctx context.Context, path string) (*Builtins, error) {
 	fileInfo, err := os.Stat(path)
 	if err != nil {
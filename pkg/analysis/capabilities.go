@@ -0,0 +1,36 @@
+package analysis
+
+import (
+	"context"
+
+	"go.lsp.dev/protocol"
+)
+
+type clientCapabilitiesKey struct{}
+
+// WithClientCapabilities attaches the capabilities the editor advertised
+// during initialization to the context, so that analysis results can be
+// tailored to what the client is able to render.
+func WithClientCapabilities(ctx context.Context, caps protocol.ClientCapabilities) context.Context {
+	return context.WithValue(ctx, clientCapabilitiesKey{}, caps)
+}
+
+// ClientCapabilitiesFromContext returns the client capabilities attached to
+// the context, or an empty set of capabilities if there are none.
+func ClientCapabilitiesFromContext(ctx context.Context) protocol.ClientCapabilities {
+	if ctx == nil {
+		return protocol.ClientCapabilities{}
+	}
+	if caps, ok := ctx.Value(clientCapabilitiesKey{}).(protocol.ClientCapabilities); ok {
+		return caps
+	}
+	return protocol.ClientCapabilities{}
+}
+
+func snippetSupport(ctx context.Context) bool {
+	caps := ClientCapabilitiesFromContext(ctx)
+	return caps.TextDocument != nil &&
+		caps.TextDocument.Completion != nil &&
+		caps.TextDocument.Completion.CompletionItem != nil &&
+		caps.TextDocument.Completion.CompletionItem.SnippetSupport
+}
@@ -1,6 +1,7 @@
 package analysis
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -22,19 +23,6 @@ func SymbolMatching(symbols []query.Symbol, name string) query.Symbol {
 	return query.Symbol{}
 }
 
-func SymbolsStartingWith(symbols []query.Symbol, prefix string) []query.Symbol {
-	if prefix == "" {
-		return symbols
-	}
-	result := []query.Symbol{}
-	for _, sym := range symbols {
-		if strings.HasPrefix(sym.Name, prefix) {
-			result = append(result, sym)
-		}
-	}
-	return result
-}
-
 func ToCompletionItemKind(k protocol.SymbolKind) protocol.CompletionItemKind {
 	switch k {
 	case protocol.SymbolKindField:
@@ -48,35 +36,65 @@ func ToCompletionItemKind(k protocol.SymbolKind) protocol.CompletionItemKind {
 	}
 }
 
-func (a *Analyzer) Completion(doc document.Document, pos protocol.Position) *protocol.CompletionList {
+func (a *Analyzer) Completion(ctx context.Context, doc document.Document, pos protocol.Position) *protocol.CompletionList {
 	pt := query.PositionToPoint(pos)
+	if fields, _, prefix, ok := a.dictKeyContext(doc, pt); ok {
+		return a.dictKeyCompletion(ctx, fields, prefix)
+	}
+
 	nodes, ok := a.nodesAtPointForCompletion(doc, pt)
-	symbols := []query.Symbol{}
+	candidates := []completionCandidate{}
 
 	if ok {
-		symbols = a.completeExpression(doc, nodes, pt)
+		candidates = a.completionCandidates(doc, nodes, pt)
 	}
+	rankCandidates(candidates, completionHistoryFromContext(ctx))
 
-	completionList := &protocol.CompletionList{
-		Items: make([]protocol.CompletionItem, len(symbols)),
+	completionList := &protocol.CompletionList{}
+	if len(candidates) > maxCompletionItems {
+		candidates = candidates[:maxCompletionItems]
+		completionList.IsIncomplete = true
+	}
+	completionList.Items = make([]protocol.CompletionItem, len(candidates))
+
+	snippets := ok && snippetSupport(ctx)
+	var identifiers []string
+	var nodeAtPoint *sitter.Node
+	if snippets {
+		identifiers = query.ExtractIdentifiers(doc, nodes, &pt)
+		nodeAtPoint = nodes[len(nodes)-1]
+		snippets = !followedByParen(doc, pt)
 	}
 
-	names := make([]string, len(symbols))
-	for i, sym := range symbols {
+	names := make([]string, len(candidates))
+	for i, c := range candidates {
+		sym := c.Symbol
 		names[i] = sym.Name
-		var sortText string
-		if strings.HasSuffix(sym.Name, "=") {
-			sortText = fmt.Sprintf("0%s", sym.Name)
-		} else {
-			sortText = fmt.Sprintf("1%s", sym.Name)
-		}
 		firstDetailLine := strings.SplitN(sym.Detail, "\n", 2)[0]
-		completionList.Items[i] = protocol.CompletionItem{
-			Label:    sym.Name,
-			Detail:   firstDetailLine,
-			Kind:     ToCompletionItemKind(sym.Kind),
-			SortText: sortText,
+		item := protocol.CompletionItem{
+			Label:  sym.Name,
+			Detail: firstDetailLine,
+			Kind:   ToCompletionItemKind(sym.Kind),
+			Command: &protocol.Command{
+				Command:   CompletionItemAcceptedCommand,
+				Arguments: []interface{}{sym.Name},
+			},
 		}
+		if snippets {
+			if sig, found := a.completionSignature(doc, nodeAtPoint, identifiers, sym); found {
+				item.InsertText = functionSnippet(sym.Name, sig)
+				item.InsertTextFormat = protocol.InsertTextFormatSnippet
+			}
+		}
+		completionList.Items[i] = item
+	}
+
+	if snippets {
+		completionList.Items = append(completionList.Items, structuralSnippetItems(doc, pt, identifiers)...)
+	}
+
+	for i := range completionList.Items {
+		completionList.Items[i].SortText = fmt.Sprintf("%04d", i)
 	}
 
 	if len(names) > 0 {
@@ -85,7 +103,74 @@ func (a *Analyzer) Completion(doc document.Document, pos protocol.Position) *pro
 	return completionList
 }
 
+// dictKeyCompletion completes the string keys of a dict literal inside of a
+// subscript expression.
+func (a *Analyzer) dictKeyCompletion(ctx context.Context, fields []literalField, prefix string) *protocol.CompletionList {
+	candidates := matchCandidates(fieldSymbols(fields), prefix, func(query.Symbol) int { return tierModule })
+	rankCandidates(candidates, completionHistoryFromContext(ctx))
+	completionList := &protocol.CompletionList{
+		Items: make([]protocol.CompletionItem, len(candidates)),
+	}
+	for i, c := range candidates {
+		completionList.Items[i] = protocol.CompletionItem{
+			Label:    c.Name,
+			Detail:   c.Detail,
+			Kind:     protocol.CompletionItemKindField,
+			SortText: fmt.Sprintf("%04d", i),
+		}
+	}
+	return completionList
+}
+
+// CompletionResolve fills in the full documentation for a completion item
+// that was previously returned by Completion for the same document and
+// position. Completion only includes the first line of the detail for each
+// item to keep responses small.
+func (a *Analyzer) CompletionResolve(ctx context.Context, doc document.Document, pos protocol.Position, item protocol.CompletionItem) protocol.CompletionItem {
+	pt := query.PositionToPoint(pos)
+	nodes, ok := a.nodesAtPointForCompletion(doc, pt)
+	if !ok || item.Kind == protocol.CompletionItemKindSnippet {
+		return item
+	}
+
+	var sym query.Symbol
+	for _, s := range a.completeExpression(doc, nodes, pt) {
+		if s.Name == item.Label {
+			sym = s
+			break
+		}
+	}
+	if sym.Name == "" {
+		return item
+	}
+
+	identifiers := query.ExtractIdentifiers(doc, nodes, &pt)
+	m := newMarkup(protocol.Markdown)
+	if sig, found := a.completionSignature(doc, nodes[len(nodes)-1], identifiers, sym); found {
+		item.Detail = sym.Name + sig.Label()
+		m.Documentation(sig.Documentation())
+	} else {
+		m.Text(sym.Detail)
+	}
+	if source := symbolSource(doc, sym); source != "" {
+		m.Text(fmt.Sprintf("Defined in %s", m.InlineCode(source)))
+	}
+	if docs := m.Content(); docs.Value != "" {
+		item.Documentation = docs
+	}
+	return item
+}
+
 func (a *Analyzer) completeExpression(doc document.Document, nodes []*sitter.Node, pt sitter.Point) []query.Symbol {
+	candidates := a.completionCandidates(doc, nodes, pt)
+	symbols := make([]query.Symbol, len(candidates))
+	for i, c := range candidates {
+		symbols[i] = c.Symbol
+	}
+	return symbols
+}
+
+func (a *Analyzer) completionCandidates(doc document.Document, nodes []*sitter.Node, pt sitter.Point) []completionCandidate {
 	var nodeAtPoint *sitter.Node
 	if len(nodes) > 0 {
 		nodeAtPoint = nodes[len(nodes)-1]
@@ -105,6 +190,7 @@ func (a *Analyzer) completeExpression(doc document.Document, nodes []*sitter.Nod
 		zap.Strings("identifiers", identifiers),
 	)
 
+	candidates := []completionCandidate{}
 	for i, id := range identifiers {
 		if i < len(identifiers)-1 {
 			sym := SymbolMatching(symbols, id)
@@ -119,19 +205,28 @@ func (a *Analyzer) completeExpression(doc document.Document, nodes []*sitter.Nod
 					return names
 				}()))
 		} else {
-			symbols = SymbolsStartingWith(symbols, id)
+			tierOf := func(query.Symbol) int { return tierBuiltin }
+			if len(identifiers) == 1 {
+				tierOf = a.symbolTiers(doc, nodeAtPoint)
+			}
+			candidates = matchCandidates(symbols, id, tierOf)
 		}
 	}
 
-	if len(symbols) == 0 {
+	if len(candidates) == 0 {
 		lastId := identifiers[len(identifiers)-1]
 		expr := a.findAttrObjectExpression(nodes, sitter.Point{Row: pt.Row, Column: pt.Column - uint32(len(lastId))})
 		if expr != nil {
-			symbols = append(symbols, SymbolsStartingWith(a.availableMembers(doc, expr), lastId)...)
+			members, typed := a.availableMembers(doc, expr)
+			tier := tierBuiltin
+			if typed {
+				tier = tierTypedMember
+			}
+			candidates = matchCandidates(members, lastId, func(query.Symbol) int { return tier })
 		}
 	}
 
-	return symbols
+	return candidates
 }
 
 // Returns a list of available symbols for completion as follows:
@@ -150,16 +245,14 @@ func (a *Analyzer) availableSymbols(doc document.Document, nodeAtPoint *sitter.N
 		}
 		symbols = append(symbols, query.SymbolsInScope(doc, nodeAtPoint)...)
 	}
+	seen := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		seen[s.Name] = true
+	}
 	docAndBuiltin := append(doc.Symbols(), a.builtins.Symbols...)
 	for _, sym := range docAndBuiltin {
-		found := false
-		for _, s := range symbols {
-			if sym.Name == s.Name {
-				found = true
-				break
-			}
-		}
-		if !found {
+		if !seen[sym.Name] {
+			seen[sym.Name] = true
 			symbols = append(symbols, sym)
 		}
 	}
@@ -296,6 +389,11 @@ func (a *Analyzer) findAttrObjectExpression(nodes []*sitter.Node, pt sitter.Poin
 	var parentNode *sitter.Node
 	for i := len(nodes) - 1; i >= 0; i-- {
 		parentNode = nodes[i]
+		// in incomplete code, the dot itself might be one of the nodes
+		if parentNode.Type() == "." && parentNode.StartPoint() == searchRange.StartPoint {
+			dot = parentNode
+			break
+		}
 		dot = query.FindChildNode(parentNode, func(n *sitter.Node) int {
 			if query.PointBeforeOrEqual(n.EndPoint(), searchRange.StartPoint) {
 				return -1
@@ -334,61 +432,43 @@ func (a *Analyzer) findAttrObjectExpression(nodes []*sitter.Node, pt sitter.Poin
 	return nil
 }
 
-// Perform some rudimentary type analysis to determine the Starlark type of the node
-func (a *Analyzer) analyzeType(doc document.Document, node *sitter.Node) string {
-	if node == nil {
-		return ""
+// availableMembers returns the members that can be accessed on the
+// expression, and whether they are specific to the type of the expression.
+func (a *Analyzer) availableMembers(doc document.Document, node *sitter.Node) ([]query.Symbol, bool) {
+	if fields, kind := a.literalFields(doc, node); kind == literalStruct {
+		return fieldSymbols(fields), true
 	}
-	switch node.Type() {
-	case query.NodeTypeString:
-		return "String"
-	case query.NodeTypeDictionary:
-		return "Dict"
-	case query.NodeTypeList:
-		return "List"
-	case query.NodeTypeIdentifier:
-		sym, found := a.FindDefinition(doc, node, doc.Content(node))
-		if found {
-			switch sym.Kind {
-			case protocol.SymbolKindString:
-				return "String"
-			case protocol.SymbolKindObject:
-				return "Dict"
-			case protocol.SymbolKindArray:
-				return "List"
-			}
-		}
-	case query.NodeTypeCall:
-		fnName := doc.Content(node.ChildByFieldName("function"))
-		args := node.ChildByFieldName("arguments")
-		sig, found := a.signatureInformation(doc, node, callWithArguments{fnName: fnName, argsNode: args})
-		if found && sig.ReturnType != "" {
-			switch strings.ToLower(sig.ReturnType) {
-			case "str", "string":
-				return "String"
-			case "list":
-				return "List"
-			case "dict":
-				return "Dict"
-			default:
-				return sig.ReturnType
-			}
-		}
+	if members, ok := a.typeMembers(a.InferType(doc, node)); ok {
+		return members, true
 	}
-	return ""
+	return a.builtins.Members, false
 }
 
-func (a *Analyzer) availableMembers(doc document.Document, node *sitter.Node) []query.Symbol {
-	if t := a.analyzeType(doc, node); t != "" {
-		if class, found := a.builtins.Types[t]; found {
-			return class.Members
+// typeMembers returns the members of all alternatives of the type, or false
+// if the type (or one of its alternatives) isn't known well enough.
+func (a *Analyzer) typeMembers(t Type) ([]query.Symbol, bool) {
+	if t.IsUnknown() || t.IsAny() {
+		return nil, false
+	}
+	members := []query.Symbol{}
+	seen := make(map[string]bool)
+	for _, alt := range t.Alternatives() {
+		class, found := a.builtins.Types[alt.Name]
+		if !found {
+			switch alt.Name {
+			case typeNameNone, typeNameBool, typeNameInt, typeNameFloat, typeNameTuple, typeNameFunction:
+				continue
+			}
+			return nil, false
 		}
-		switch t {
-		case "None", "bool", "int", "float":
-			return []query.Symbol{}
+		for _, m := range class.Members {
+			if !seen[m.Name] {
+				seen[m.Name] = true
+				members = append(members, m)
+			}
 		}
 	}
-	return a.builtins.Members
+	return members, true
 }
 
 func (a *Analyzer) FindDefinition(doc document.Document, node *sitter.Node, name string) (query.Symbol, bool) {
@@ -1,12 +1,18 @@
 package analysis
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
 
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
 	"github.com/tilt-dev/starlark-lsp/pkg/query"
 )
 
@@ -40,11 +46,11 @@ func TestSimpleCompletion(t *testing.T) {
 	f.Symbols("foo", "bar", "baz")
 
 	doc := f.MainDoc("")
-	result := f.a.Completion(doc, protocol.Position{})
+	result := f.a.Completion(f.ctx, doc, protocol.Position{})
 	assertCompletionResult(t, []string{"foo", "bar", "baz"}, result)
 
 	doc = f.MainDoc("ba")
-	result = f.a.Completion(doc, protocol.Position{Character: 2})
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Character: 2})
 	assertCompletionResult(t, []string{"bar", "baz"}, result)
 }
 
@@ -141,7 +147,7 @@ func TestCompletions(t *testing.T) {
 				f.osSysSymbols()
 			}
 			doc := f.MainDoc(tt.doc)
-			result := f.a.Completion(doc, protocol.Position{Line: tt.line, Character: tt.char})
+			result := f.a.Completion(f.ctx, doc, protocol.Position{Line: tt.line, Character: tt.char})
 			assertCompletionResult(t, tt.expected, result)
 		})
 	}
@@ -267,7 +273,7 @@ func TestKeywordArgCompletion(t *testing.T) {
 			f.ParseBuiltins(functionFixture)
 
 			doc := f.MainDoc(tt.doc)
-			result := f.a.Completion(doc, protocol.Position{Line: tt.line, Character: tt.char})
+			result := f.a.Completion(f.ctx, doc, protocol.Position{Line: tt.line, Character: tt.char})
 			assertCompletionResult(t, tt.expected, result)
 		})
 	}
@@ -290,7 +296,7 @@ func TestMemberCompletion(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.doc, func(t *testing.T) {
 			doc := f.MainDoc(tt.doc)
-			result := f.a.Completion(doc, protocol.Position{Line: tt.line, Character: tt.char})
+			result := f.a.Completion(f.ctx, doc, protocol.Position{Line: tt.line, Character: tt.char})
 			assertCompletionResult(t, tt.expected, result)
 		})
 	}
@@ -329,8 +335,404 @@ s.i`, line: 1, char: 3, expected: []string{"items"}},
 	for _, tt := range tests {
 		t.Run(tt.doc, func(t *testing.T) {
 			doc := f.MainDoc(tt.doc)
-			result := f.a.Completion(doc, protocol.Position{Line: tt.line, Character: tt.char})
+			result := f.a.Completion(f.ctx, doc, protocol.Position{Line: tt.line, Character: tt.char})
 			assertCompletionResult(t, tt.expected, result)
 		})
 	}
 }
+
+func TestInferredTypeMemberCompletion(t *testing.T) {
+	f := newFixture(t)
+	_ = WithStarlarkBuiltins()(f.a)
+
+	tests := []struct {
+		doc        string
+		line, char uint32
+		expected   []string
+	}{
+		{doc: `for name in dir(x):
+  name.isa`, line: 1, char: 10, expected: []string{"isalnum", "isalpha"}},
+		{doc: `parts = "a b".split()
+parts[0].isa`, line: 1, char: 12, expected: []string{"isalnum", "isalpha"}},
+		{doc: `def names():
+  return ["a"]
+names().ext`, line: 2, char: 11, expected: []string{"extend"}},
+		{doc: `x = "a"
+x = []
+x.ext`, line: 2, char: 5, expected: []string{"extend"}},
+		{doc: `x = None
+if c:
+  x = "a"
+x.isa`, line: 3, char: 5, expected: []string{"isalnum", "isalpha"}},
+		{doc: `x = 1
+x.`, line: 1, char: 2, expected: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.doc, func(t *testing.T) {
+			doc := f.MainDoc(tt.doc)
+			result := f.a.Completion(f.ctx, doc, protocol.Position{Line: tt.line, Character: tt.char})
+			assertCompletionResult(t, tt.expected, result)
+		})
+	}
+}
+
+func snippetCapabilities() protocol.ClientCapabilities {
+	return protocol.ClientCapabilities{
+		TextDocument: &protocol.TextDocumentClientCapabilities{
+			Completion: &protocol.CompletionTextDocumentClientCapabilities{
+				CompletionItem: &protocol.CompletionTextDocumentClientCapabilitiesItem{
+					SnippetSupport: true,
+				},
+			},
+		},
+	}
+}
+
+func findCompletionItem(result *protocol.CompletionList, label string) (protocol.CompletionItem, bool) {
+	for _, item := range result.Items {
+		if item.Label == label {
+			return item, true
+		}
+	}
+	return protocol.CompletionItem{}, false
+}
+
+func TestSnippetCompletion(t *testing.T) {
+	tests := []struct {
+		doc        string
+		line, char uint32
+		label      string
+		expected   string
+	}{
+		{doc: "loc", char: 3, label: "local", expected: "local(${1:command})$0"},
+		{doc: "docker", char: 6, label: "docker_build", expected: "docker_build(${1:ref}, ${2:context})$0"},
+		{doc: "x = loc", char: 7, label: "local", expected: "local(${1:command})$0"},
+		{doc: customFn + "f", line: 6, char: 1, label: "fn", expected: "fn(${1:a}, ${2:b}, ${3:c})$0"},
+		// keyword-only parameters follow `*` or `*args`
+		{doc: "def kw(a, *, b, c=1):\n  pass\n\nk", line: 3, char: 1, label: "kw", expected: "kw(${1:a}, b=${2:b})$0"},
+		{doc: "def kw(a, *args, b):\n  pass\n\nk", line: 3, char: 1, label: "kw", expected: "kw(${1:a}, b=${2:b})$0"},
+		{doc: "def kw(a, *args: int, b):\n  pass\n\nk", line: 3, char: 1, label: "kw", expected: "kw(${1:a}, b=${2:b})$0"},
+		// arguments are already present
+		{doc: "loc()", char: 3, label: "local", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.doc, func(t *testing.T) {
+			f := newFixture(t)
+			f.ParseBuiltins(functionFixture)
+
+			doc := f.MainDoc(tt.doc)
+			ctx := WithClientCapabilities(f.ctx, snippetCapabilities())
+			result := f.a.Completion(ctx, doc, protocol.Position{Line: tt.line, Character: tt.char})
+			item, found := findCompletionItem(result, tt.label)
+			assert.True(t, found)
+			assert.Equal(t, tt.expected, item.InsertText)
+			if tt.expected != "" {
+				assert.Equal(t, protocol.InsertTextFormatSnippet, item.InsertTextFormat)
+			}
+		})
+	}
+}
+
+func TestSnippetCompletionWithoutClientSupport(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(functionFixture)
+
+	doc := f.MainDoc("loc")
+	result := f.a.Completion(f.ctx, doc, protocol.Position{Character: 3})
+	item, found := findCompletionItem(result, "local")
+	assert.True(t, found)
+	assert.Equal(t, "", item.InsertText)
+
+	doc = f.MainDoc("de")
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Character: 2})
+	_, found = findCompletionItem(result, "def")
+	assert.False(t, found)
+}
+
+func TestStructuralSnippetCompletion(t *testing.T) {
+	tests := []struct {
+		doc        string
+		line, char uint32
+		expected   []string
+	}{
+		{doc: "de", char: 2, expected: []string{"def"}},
+		{doc: "lo", char: 2, expected: []string{"load"}},
+		{doc: "x = de", char: 6, expected: []string{}},
+		{doc: "x = di", char: 6, expected: []string{"dictcomp"}},
+		{doc: "x = l", char: 5, expected: []string{"listcomp"}},
+		{doc: "def f():\n  lo", line: 1, char: 4, expected: []string{}},
+		{doc: "def f():\n  fo", line: 1, char: 4, expected: []string{"for"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.doc, func(t *testing.T) {
+			f := newFixture(t)
+			doc := f.MainDoc(tt.doc)
+			ctx := WithClientCapabilities(f.ctx, snippetCapabilities())
+			result := f.a.Completion(ctx, doc, protocol.Position{Line: tt.line, Character: tt.char})
+			snippets := []string{}
+			for _, item := range result.Items {
+				if item.Kind == protocol.CompletionItemKindSnippet {
+					snippets = append(snippets, item.Label)
+				}
+			}
+			assert.ElementsMatch(t, tt.expected, snippets)
+		})
+	}
+}
+
+func TestCompletionResolve(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(`
+def local(command: str, quiet: bool = False) -> Blob:
+    """Runs a command.
+
+    Args:
+      command: Command to run.
+      quiet: Suppress output.
+
+    Returns:
+      The output of the command.
+    """
+    pass
+`)
+
+	doc := f.MainDoc("loc")
+	pos := protocol.Position{Character: 3}
+	result := f.a.Completion(f.ctx, doc, pos)
+	item, found := findCompletionItem(result, "local")
+	require.True(t, found)
+	assert.Equal(t, "Runs a command.", item.Detail)
+	assert.Nil(t, item.Documentation)
+
+	resolved := f.a.CompletionResolve(f.ctx, doc, pos, item)
+	assert.Equal(t, "local(command: str, quiet: bool = False) -> Blob", resolved.Detail)
+	assert.Equal(t, protocol.MarkupContent{
+		Kind: protocol.Markdown,
+		Value: "Runs a command.\n\n" +
+			"**Parameters**\n\n| Parameter | Type | Default | Description |\n| --- | --- | --- | --- |\n" +
+			"| `command` | `str` |  | Command to run. |\n| `quiet` | `bool` | `False` | Suppress output. |\n\n" +
+			"**Returns**\n\nThe output of the command.",
+	}, resolved.Documentation)
+}
+
+func TestCompletionResolveLoadedSymbol(t *testing.T) {
+	f := newFixture(t)
+	f.Document("lib.star", `
+def helper():
+  """Helps."""
+  pass
+`)
+
+	doc := f.MainDoc("load('lib.star', 'helper')\nhel")
+	pos := protocol.Position{Line: 1, Character: 3}
+	result := f.a.Completion(f.ctx, doc, pos)
+	item, found := findCompletionItem(result, "helper")
+	require.True(t, found)
+
+	resolved := f.a.CompletionResolve(f.ctx, doc, pos, item)
+	assert.Equal(t, "helper()", resolved.Detail)
+	assert.Equal(t, protocol.MarkupContent{
+		Kind:  protocol.Markdown,
+		Value: "Helps.\n\nDefined in `lib.star`",
+	}, resolved.Documentation)
+}
+
+func completionLabels(result *protocol.CompletionList) []string {
+	labels := make([]string, len(result.Items))
+	for i, item := range result.Items {
+		labels[i] = item.Label
+	}
+	return labels
+}
+
+func TestFuzzyCompletion(t *testing.T) {
+	f := newFixture(t)
+	f.Symbols("k8s_yaml", "k8s_resource", "docker_build", "kustomize")
+
+	doc := f.MainDoc("kyml")
+	result := f.a.Completion(f.ctx, doc, protocol.Position{Character: 4})
+	assert.Equal(t, []string{"k8s_yaml"}, completionLabels(result))
+
+	doc = f.MainDoc("k")
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Character: 1})
+	assert.Equal(t, []string{"k8s_resource", "k8s_yaml", "kustomize"}, completionLabels(result))
+}
+
+func TestCompletionRanking(t *testing.T) {
+	f := newFixture(t)
+	f.Symbols("value_builtin", "value_builtin2")
+	f.Document("lib.star", "value_loaded = 1\n")
+
+	doc := f.MainDoc(`load("lib.star", "value_loaded")
+value_module = 1
+def fn(value_param):
+  value_local = 2
+  value
+`)
+	pos := protocol.Position{Line: 4, Character: 7}
+	result := f.a.Completion(f.ctx, doc, pos)
+	assert.Equal(t, []string{"value_local", "value_param", "value_module", "value_loaded", "value_builtin", "value_builtin2"}, completionLabels(result))
+
+	for i, item := range result.Items {
+		assert.Equal(t, fmt.Sprintf("%04d", i), item.SortText)
+		require.NotNil(t, item.Command)
+		assert.Equal(t, CompletionItemAcceptedCommand, item.Command.Command)
+	}
+
+	// recently used items come first within their scope
+	history := NewCompletionHistory()
+	history.Add("value_builtin2")
+	history.Add("value_param")
+	result = f.a.Completion(WithCompletionHistory(f.ctx, history), doc, pos)
+	assert.Equal(t, []string{"value_param", "value_local", "value_module", "value_loaded", "value_builtin2", "value_builtin"}, completionLabels(result))
+
+	// scope takes precedence over better matches
+	doc = f.MainDoc("my_value = 1\nvalue")
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Line: 1, Character: 5})
+	assert.Equal(t, []string{"my_value", "value_builtin", "value_builtin2"}, completionLabels(result))
+}
+
+func TestCompletionKeywordArgsFirst(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(functionFixture)
+
+	doc := f.MainDoc("local(d")
+	result := f.a.Completion(f.ctx, doc, protocol.Position{Character: 7})
+	assert.Equal(t, []string{"dir=", "docker_build"}, completionLabels(result))
+}
+
+func TestCompletionIsIncomplete(t *testing.T) {
+	f := newFixture(t)
+	for i := 0; i < maxCompletionItems+10; i++ {
+		f.Symbols(fmt.Sprintf("sym%03d", i))
+	}
+
+	doc := f.MainDoc("sym")
+	result := f.a.Completion(f.ctx, doc, protocol.Position{Character: 3})
+	assert.True(t, result.IsIncomplete)
+	assert.Len(t, result.Items, maxCompletionItems)
+
+	doc = f.MainDoc("sym10")
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Character: 5})
+	assert.False(t, result.IsIncomplete)
+	// sym100-sym109, but not sym010, which only matches with a gap in the
+	// first segment
+	assert.Len(t, result.Items, 10)
+}
+
+// tiltLikeBuiltins generates a builtins stub with a number of functions
+// roughly the size of Tilt's API, with similar naming.
+func tiltLikeBuiltins(n int) string {
+	prefixes := []string{"k8s", "docker", "local", "helm", "custom", "config", "watch", "os", "v1alpha1", "extension"}
+	nouns := []string{"yaml", "resource", "build", "kind", "context", "image", "repo", "file", "settings", "link"}
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		sb.WriteString(fmt.Sprintf(`
+def %s_%s_%d(name: str, labels: List[str] = [], resource_deps: List[str] = []) -> None:
+    """Does something with a %s."""
+    pass
+`, prefixes[i%len(prefixes)], nouns[(i/len(prefixes))%len(nouns)], i, nouns[i%len(nouns)]))
+	}
+	return sb.String()
+}
+
+func benchmarkCompletion(b *testing.B, content string, pos protocol.Position) {
+	ctx := context.Background()
+	builtins, err := LoadBuiltinsFromSource(ctx, []byte(tiltLikeBuiltins(1000)), "api.py")
+	require.NoError(b, err)
+	a, err := NewAnalyzer(ctx)
+	require.NoError(b, err)
+	a.builtins.Update(builtins)
+
+	tree, err := query.Parse(ctx, []byte(content))
+	require.NoError(b, err)
+	doc := document.NewDocument(uri.File("Tiltfile"), []byte(content), tree)
+	b.Cleanup(doc.Close)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Completion(ctx, doc, pos)
+	}
+}
+
+func BenchmarkCompletionEmptyPrefix(b *testing.B) {
+	benchmarkCompletion(b, "", protocol.Position{})
+}
+
+func BenchmarkCompletionFuzzyPrefix(b *testing.B) {
+	benchmarkCompletion(b, "kyml", protocol.Position{Character: 4})
+}
+
+func BenchmarkFuzzyMatch(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FuzzyMatch("kyml", "k8s_kind_yaml_resource_loader")
+	}
+}
+
+func TestStructFieldCompletion(t *testing.T) {
+	f := newFixture(t)
+	cfg := `cfg = struct(name="x", port=8080, nested=struct(enabled=True))
+`
+
+	doc := f.MainDoc(cfg + "cfg.")
+	result := f.a.Completion(f.ctx, doc, protocol.Position{Line: 1, Character: 4})
+	assert.Equal(t, []string{"name", "nested", "port"}, completionLabels(result))
+	assert.Equal(t, protocol.CompletionItemKindField, result.Items[0].Kind)
+	assert.Equal(t, `name="x"`, result.Items[0].Detail)
+
+	doc = f.MainDoc(cfg + "cfg.nested.")
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Line: 1, Character: 11})
+	assert.Equal(t, []string{"enabled"}, completionLabels(result))
+
+	doc = f.MainDoc(cfg + "cfg.po")
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Line: 1, Character: 6})
+	assert.Equal(t, []string{"port"}, completionLabels(result))
+}
+
+func TestStructFieldCompletionFromFunctionReturn(t *testing.T) {
+	f := newFixture(t)
+
+	doc := f.MainDoc(`def make_cfg(debug):
+  def helper():
+    return struct(ignored=1)
+  if debug:
+    return struct(name="dbg", verbose=True)
+  return struct(name="prod")
+
+cfg = make_cfg(False)
+cfg.`)
+	result := f.a.Completion(f.ctx, doc, protocol.Position{Line: 8, Character: 4})
+	assert.Equal(t, []string{"name", "verbose"}, completionLabels(result))
+}
+
+func TestStructFieldCompletionCustomConstructor(t *testing.T) {
+	f := newFixture(t)
+	var err error
+	f.a, err = NewAnalyzer(f.ctx, WithStructConstructors("provider_info"))
+	require.NoError(t, err)
+
+	doc := f.MainDoc(`info = provider_info(files=[], runfiles=None)
+info.`)
+	result := f.a.Completion(f.ctx, doc, protocol.Position{Line: 1, Character: 5})
+	assert.Equal(t, []string{"files", "runfiles"}, completionLabels(result))
+}
+
+func TestDictKeyCompletion(t *testing.T) {
+	f := newFixture(t)
+
+	doc := f.MainDoc(`cfg = {"name": "x", "port": 8080, 1: "skipped"}
+cfg[""]
+cfg['po']
+cfg["`)
+	result := f.a.Completion(f.ctx, doc, protocol.Position{Line: 1, Character: 5})
+	assert.Equal(t, []string{"name", "port"}, completionLabels(result))
+	assert.Equal(t, protocol.CompletionItemKindField, result.Items[0].Kind)
+
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Line: 2, Character: 7})
+	assert.Equal(t, []string{"port"}, completionLabels(result))
+
+	result = f.a.Completion(f.ctx, doc, protocol.Position{Line: 3, Character: 5})
+	assert.Equal(t, []string{"name", "port"}, completionLabels(result))
+}
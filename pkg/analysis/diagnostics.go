@@ -0,0 +1,35 @@
+package analysis
+
+import (
+	"fmt"
+
+	"go.lsp.dev/protocol"
+)
+
+// WithDisabledDiagnostics leaves out the diagnostics with the given codes,
+// e.g. "type-mismatch" or "load-symbol-not-found".
+func WithDisabledDiagnostics(codes ...string) AnalyzerOption {
+	return func(analyzer *Analyzer) error {
+		if analyzer.disabledDiagnostics == nil {
+			analyzer.disabledDiagnostics = make(map[string]bool)
+		}
+		for _, code := range codes {
+			analyzer.disabledDiagnostics[code] = true
+		}
+		return nil
+	}
+}
+
+func (a *Analyzer) enabledDiagnostics(diags []protocol.Diagnostic) []protocol.Diagnostic {
+	if len(a.disabledDiagnostics) == 0 {
+		return diags
+	}
+	enabled := diags[:0]
+	for _, diag := range diags {
+		if diag.Code != nil && a.disabledDiagnostics[fmt.Sprint(diag.Code)] {
+			continue
+		}
+		enabled = append(enabled, diag)
+	}
+	return enabled
+}
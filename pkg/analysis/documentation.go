@@ -0,0 +1,41 @@
+package analysis
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// symbolSource describes where a symbol was defined, relative to the
+// document it's being used in. Returns "" for symbols defined in the
+// document itself and for builtins.
+func symbolSource(doc document.Document, sym query.Symbol) string {
+	if !sym.HasLocation() || sym.Location.URI == doc.URI() {
+		return ""
+	}
+	path, err := uriFilename(sym.Location.URI)
+	if err != nil {
+		return string(sym.Location.URI)
+	}
+	if docPath, err := uriFilename(doc.URI()); err == nil {
+		if rel, err := filepath.Rel(filepath.Dir(docPath), path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return path
+}
+
+// uriFilename is like uri.URI.Filename() but returns an error for non-file
+// URIs instead of panicking.
+func uriFilename(u uri.URI) (fn string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return u.Filename(), nil
+}
@@ -0,0 +1,288 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// maxFieldResolutionDepth bounds how many assignments, attribute accesses and
+// function returns are followed when determining the fields of a value, which
+// also protects against recursive definitions.
+const maxFieldResolutionDepth = 10
+
+type literalKind int
+
+const (
+	literalUnknown literalKind = iota
+	literalStruct
+	literalDict
+)
+
+// literalField is a struct field or a string dict key from a literal
+// definition, along with the node for its value.
+type literalField struct {
+	query.Symbol
+	value *sitter.Node
+}
+
+// WithStructConstructors registers additional functions that create
+// struct-like values from keyword arguments, like the builtin `struct`.
+func WithStructConstructors(names ...string) AnalyzerOption {
+	return func(analyzer *Analyzer) error {
+		for _, name := range names {
+			analyzer.structConstructors[name] = true
+		}
+		return nil
+	}
+}
+
+// literalFields determines the fields of the struct or the string keys of
+// the dict that the expression evaluates to, by following it back to a
+// `struct(...)` call or dict literal in the document.
+func (a *Analyzer) literalFields(doc document.Document, node *sitter.Node) ([]literalField, literalKind) {
+	return a.literalFieldsWithDepth(doc, node, 0)
+}
+
+func (a *Analyzer) literalFieldsWithDepth(doc document.Document, node *sitter.Node, depth int) ([]literalField, literalKind) {
+	if node == nil || depth > maxFieldResolutionDepth {
+		return nil, literalUnknown
+	}
+
+	switch node.Type() {
+	case "parenthesized_expression":
+		return a.literalFieldsWithDepth(doc, node.NamedChild(0), depth+1)
+
+	case query.NodeTypeIdentifier:
+		return a.literalFieldsWithDepth(doc, a.assignedValue(doc, node, doc.Content(node)), depth+1)
+
+	case query.NodeTypeAttribute:
+		fields, kind := a.literalFieldsWithDepth(doc, node.ChildByFieldName("object"), depth+1)
+		if kind != literalStruct {
+			return nil, literalUnknown
+		}
+		return a.literalFieldsWithDepth(doc, findField(fields, doc.Content(node.ChildByFieldName("attribute"))), depth+1)
+
+	case "subscript":
+		key := node.ChildByFieldName("subscript")
+		if key == nil || key.Type() != query.NodeTypeString {
+			return nil, literalUnknown
+		}
+		fields, kind := a.literalFieldsWithDepth(doc, node.ChildByFieldName("value"), depth+1)
+		if kind != literalDict {
+			return nil, literalUnknown
+		}
+		return a.literalFieldsWithDepth(doc, findField(fields, query.Unquote(doc.Input(), key)), depth+1)
+
+	case query.NodeTypeDictionary:
+		return dictFields(doc, node), literalDict
+
+	case query.NodeTypeCall:
+		fn := node.ChildByFieldName("function")
+		if a.structConstructors[doc.Content(fn)] {
+			return structFields(doc, node), literalStruct
+		}
+		if fn.Type() == query.NodeTypeIdentifier {
+			return a.returnedFields(doc, fn, depth+1)
+		}
+	}
+	return nil, literalUnknown
+}
+
+// literalFieldsOfName is like literalFields for a (possibly dotted) name
+// that's visible at the given node.
+func (a *Analyzer) literalFieldsOfName(doc document.Document, node *sitter.Node, name string) ([]literalField, literalKind) {
+	ids := strings.Split(name, ".")
+	fields, kind := a.literalFields(doc, a.assignedValue(doc, node, ids[0]))
+	for _, id := range ids[1:] {
+		if kind != literalStruct {
+			return nil, literalUnknown
+		}
+		fields, kind = a.literalFields(doc, findField(fields, id))
+	}
+	return fields, kind
+}
+
+func findField(fields []literalField, name string) *sitter.Node {
+	for _, f := range fields {
+		if f.Name == name {
+			return f.value
+		}
+	}
+	return nil
+}
+
+func fieldSymbols(fields []literalField) []query.Symbol {
+	symbols := make([]query.Symbol, len(fields))
+	for i, f := range fields {
+		symbols[i] = f.Symbol
+	}
+	return symbols
+}
+
+func structFields(doc document.Document, call *sitter.Node) []literalField {
+	fields := []literalField{}
+	args := call.ChildByFieldName("arguments")
+	for i := 0; i < int(args.NamedChildCount()); i++ {
+		arg := args.NamedChild(i)
+		if arg.Type() != query.NodeTypeKeywordArgument {
+			continue
+		}
+		fields = append(fields, literalField{
+			Symbol: query.Symbol{
+				Name:     doc.Content(arg.ChildByFieldName("name")),
+				Detail:   doc.Content(arg),
+				Kind:     protocol.SymbolKindField,
+				Location: query.NodeLocation(arg, doc.URI()),
+			},
+			value: arg.ChildByFieldName("value"),
+		})
+	}
+	return fields
+}
+
+func dictFields(doc document.Document, dict *sitter.Node) []literalField {
+	fields := []literalField{}
+	for i := 0; i < int(dict.NamedChildCount()); i++ {
+		pair := dict.NamedChild(i)
+		if pair.Type() != "pair" {
+			continue
+		}
+		key := pair.ChildByFieldName("key")
+		if key == nil || key.Type() != query.NodeTypeString {
+			continue
+		}
+		fields = append(fields, literalField{
+			Symbol: query.Symbol{
+				Name:     query.Unquote(doc.Input(), key),
+				Detail:   doc.Content(pair),
+				Kind:     protocol.SymbolKindField,
+				Location: query.NodeLocation(pair, doc.URI()),
+			},
+			value: pair.ChildByFieldName("value"),
+		})
+	}
+	return fields
+}
+
+// assignedValue finds the value assigned to the variable with the given name
+// that's visible at the node, if the assignment is in the same document.
+func (a *Analyzer) assignedValue(doc document.Document, node *sitter.Node, name string) *sitter.Node {
+	n := a.definitionNode(doc, node, name)
+	if n == nil {
+		return nil
+	}
+	if n.Type() == query.NodeTypeExpressionStatement {
+		n = n.NamedChild(0)
+	}
+	if n == nil || n.Type() != query.NodeTypeAssignment {
+		return nil
+	}
+	return n.ChildByFieldName("right")
+}
+
+// definitionNode finds the node that defines the symbol with the given name
+// visible at the node, if it's defined in the same document.
+func (a *Analyzer) definitionNode(doc document.Document, node *sitter.Node, name string) *sitter.Node {
+	sym, found := a.FindDefinition(doc, node, name)
+	if !found || sym.Location.URI != doc.URI() {
+		return nil
+	}
+	r := query.SitterRange(sym.Location.Range)
+	n := doc.Tree().RootNode().NamedDescendantForPointRange(r.StartPoint, r.EndPoint)
+	// the smallest node might be a child spanning the same range
+	for n != nil && n.Parent() != nil && n.Parent().StartPoint() == r.StartPoint && n.Parent().EndPoint() == r.EndPoint {
+		n = n.Parent()
+	}
+	return n
+}
+
+// returnedFields determines the fields of the values returned by the
+// function with the given name.
+func (a *Analyzer) returnedFields(doc document.Document, fn *sitter.Node, depth int) ([]literalField, literalKind) {
+	fnNode := a.definitionNode(doc, fn, doc.Content(fn))
+	if fnNode == nil || fnNode.Type() != query.NodeTypeFunctionDef {
+		return nil, literalUnknown
+	}
+
+	var fields []literalField
+	kind := literalUnknown
+	seen := make(map[string]bool)
+	query.Query(fnNode.ChildByFieldName(query.FieldBody), `(return_statement) @return`, func(q *sitter.Query, match *sitter.QueryMatch) bool {
+		for _, c := range match.Captures {
+			// skip returns from nested functions
+			enclosing := c.Node.Parent()
+			for enclosing != nil && enclosing.Type() != query.NodeTypeFunctionDef {
+				enclosing = enclosing.Parent()
+			}
+			if enclosing == nil || !enclosing.Equal(fnNode) {
+				continue
+			}
+
+			retFields, retKind := a.literalFieldsWithDepth(doc, c.Node.NamedChild(0), depth+1)
+			if retKind == literalUnknown || (kind != literalUnknown && kind != retKind) {
+				continue
+			}
+			kind = retKind
+			for _, f := range retFields {
+				if !seen[f.Name] {
+					seen[f.Name] = true
+					fields = append(fields, f)
+				}
+			}
+		}
+		return true
+	})
+	return fields, kind
+}
+
+// keyContextPattern matches an incomplete subscript with a string key at the
+// end of a line, e.g. `cfg["na`, which Tree-sitter can't parse.
+var keyContextPattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_.]*)\[\s*["']([^"'\\]*)$`)
+
+// dictKeyContext determines whether the point is inside the string key of a
+// subscript expression, and if so returns the fields of the dict being
+// subscripted, the key string node (if it could be parsed) and the part of
+// the key before the point.
+func (a *Analyzer) dictKeyContext(doc document.Document, pt sitter.Point) ([]literalField, *sitter.Node, string, bool) {
+	node, ok := query.NodeAtPoint(doc, pt)
+	if !ok {
+		return nil, nil, "", false
+	}
+
+	str := node
+	if str.Type() != query.NodeTypeString && str.Parent() != nil {
+		str = str.Parent()
+	}
+	if str.Type() == query.NodeTypeString && query.PointCovered(pt, str) &&
+		str.Parent() != nil && str.Parent().Type() == "subscript" &&
+		str.Parent().ChildByFieldName("subscript") != nil &&
+		str.Equal(str.Parent().ChildByFieldName("subscript")) {
+		fields, kind := a.literalFields(doc, str.Parent().ChildByFieldName("value"))
+		if kind != literalDict {
+			return nil, nil, "", false
+		}
+		// the prefix starts after the opening quote
+		start := str.Child(0).EndPoint()
+		prefix := ""
+		if start.Row == pt.Row && start.Column <= pt.Column {
+			prefix = linePrefix(doc, pt.Row, pt.Column)[start.Column:]
+		}
+		return fields, str, prefix, true
+	}
+
+	match := keyContextPattern.FindStringSubmatch(linePrefix(doc, pt.Row, pt.Column))
+	if match == nil {
+		return nil, nil, "", false
+	}
+	fields, kind := a.literalFieldsOfName(doc, node, match[1])
+	if kind != literalDict {
+		return nil, nil, "", false
+	}
+	return fields, nil, match[2], true
+}
@@ -0,0 +1,157 @@
+package analysis
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	fuzzyScoreStart       = 8
+	fuzzyScoreSegment     = 6
+	fuzzyScoreConsecutive = 5
+	fuzzyScoreCase        = 1
+	fuzzyPenaltyGap       = 1
+	fuzzyPrefixBonus      = 20
+)
+
+// FuzzyMatch reports whether all characters of the pattern appear in order in
+// the candidate, and if so, how well they match (higher is better).
+//
+// Matching is case-insensitive unless the pattern character is uppercase
+// ("smart case"), except for the first character of the pattern, which has to
+// match exactly as with a prefix, so that `f` doesn't match `False`. The
+// first character has to match at the start of the candidate or at the start
+// of a segment, where segments are separated by `_` and `.`, or start at a
+// lowercase to uppercase transition (camel humps). The characters matched in
+// that segment have to be consecutive; after it, characters can match
+// anywhere, but matches at segment starts and consecutive matches score
+// higher, so that `kyml` matches `k8s_yaml` better than it matches
+// `kustomize_yaml`, while `end` doesn't match `extend`.
+func FuzzyMatch(pattern, candidate string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	p := []rune(pattern)
+	c := []rune(candidate)
+	if len(p) > len(c) {
+		return 0, false
+	}
+
+	segmentStart := make([]bool, len(c))
+	for j := range c {
+		segmentStart[j] = j == 0 ||
+			c[j-1] == '_' || c[j-1] == '.' ||
+			(unicode.IsLower(c[j-1]) && unicode.IsUpper(c[j])) ||
+			(unicode.IsDigit(c[j-1]) && unicode.IsLetter(c[j]))
+	}
+
+	// segment[j] is the start of the segment c[j] is in
+	segment := make([]int, len(c))
+	for j := range c {
+		if !segmentStart[j] {
+			segment[j] = segment[j-1]
+		} else {
+			segment[j] = j
+		}
+	}
+
+	// inFirst[i][j] is the best score for matching p[:i+1] where p[i] is
+	// matched to c[j] in the segment the match starts in, which requires
+	// p[:i+1] to be matched consecutively, and beyond[i][j] the best score
+	// where c[j] is in a later segment, or noMatch if there is no such match
+	const noMatch = -1 << 30
+	inFirst := make([][]int, len(p))
+	beyond := make([][]int, len(p))
+	for i := range p {
+		inFirst[i] = make([]int, len(c))
+		beyond[i] = make([]int, len(c))
+		for j := range c {
+			inFirst[i][j] = noMatch
+			beyond[i][j] = noMatch
+			if i == 0 && p[i] != c[j] || !runeMatches(p[i], c[j]) {
+				continue
+			}
+			score := 0
+			if c[j] == p[i] {
+				score += fuzzyScoreCase
+			}
+			if j == 0 {
+				score += fuzzyScoreStart
+			} else if segmentStart[j] {
+				score += fuzzyScoreSegment
+			}
+
+			if i == 0 {
+				if segmentStart[j] {
+					inFirst[i][j] = score
+				}
+				continue
+			}
+			if j < i {
+				continue
+			}
+
+			// the previous character matched consecutively, in the same
+			// segment or at the end of the segment before
+			if prev := inFirst[i-1][j-1]; prev != noMatch {
+				if segmentStart[j] {
+					beyond[i][j] = max(beyond[i][j], prev+fuzzyScoreConsecutive+score)
+				} else {
+					inFirst[i][j] = prev + fuzzyScoreConsecutive + score
+				}
+			}
+			if prev := beyond[i-1][j-1]; prev != noMatch {
+				beyond[i][j] = max(beyond[i][j], prev+fuzzyScoreConsecutive+score)
+			}
+			// or with a gap, leaving the segment the match starts in
+			for k := i - 1; k < j-1; k++ {
+				gap := fuzzyPenaltyGap * (j - k - 1)
+				if prev := beyond[i-1][k]; prev != noMatch {
+					beyond[i][j] = max(beyond[i][j], prev-gap+score)
+				}
+				if prev := inFirst[i-1][k]; prev != noMatch && segment[j] > k {
+					beyond[i][j] = max(beyond[i][j], prev-gap+score)
+				}
+			}
+		}
+	}
+
+	result := noMatch
+	last := len(p) - 1
+	for j := range c {
+		result = max(result, max(inFirst[last][j], beyond[last][j]))
+	}
+	if result == noMatch {
+		return 0, false
+	}
+	if hasPrefixFold(candidate, pattern) {
+		result += fuzzyPrefixBonus
+	}
+	return result, true
+}
+
+func runeMatches(p, c rune) bool {
+	if unicode.IsUpper(p) {
+		return p == c
+	}
+	return p == unicode.ToLower(c)
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	for _, r := range prefix {
+		c, size := utf8.DecodeRuneInString(s)
+		if size == 0 || !runeMatches(r, c) {
+			return false
+		}
+		s = s[size:]
+	}
+	return true
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern, candidate string
+		match              bool
+	}{
+		{"", "anything", true},
+		{"k8s", "k8s_yaml", true},
+		{"kyml", "k8s_yaml", true},
+		{"dbuild", "docker_build", true},
+		{"db", "docker_build", true},
+		{"lr", "local_resource", true},
+		{"gCwd", "getCwd", true},
+		{"gc", "getCwd", true},
+		{"yaml", "k8s_yaml", true},
+		{"aml", "k8s_yaml", false},
+		{"K", "k8s_yaml", false},
+		{"T", "True", true},
+		{"t", "True", false},
+		{"f", "False", false},
+		{"end", "extend", false},
+		{"isa", "isspace", false},
+		{"isa", "is_alpha", true},
+		{"sym10", "sym010", false},
+		{"T", "tuple", false},
+		{"docker_build", "docker", false},
+		{"xyz", "docker_build", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.candidate, func(t *testing.T) {
+			_, ok := FuzzyMatch(tt.pattern, tt.candidate)
+			assert.Equal(t, tt.match, ok)
+		})
+	}
+}
+
+func TestFuzzyMatchScore(t *testing.T) {
+	tests := []struct {
+		pattern, better, worse string
+	}{
+		{"k", "k8s_yaml", "helm_kustomize"},
+		{"kyml", "k8s_yaml", "kustomize_yaml"},
+		{"loc", "local", "load_config"},
+		{"dbuild", "docker_build", "docker_compose_build_args"},
+		{"env", "environ", "e_n_v"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			better, ok := FuzzyMatch(tt.pattern, tt.better)
+			assert.True(t, ok)
+			worse, ok := FuzzyMatch(tt.pattern, tt.worse)
+			assert.True(t, ok)
+			assert.Greater(t, better, worse)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package analysis
+
+import (
+	"context"
+	"sync"
+)
+
+// CompletionItemAcceptedCommand is attached to completion items so that the
+// editor notifies the server when an item is accepted, which is used to rank
+// recently used items higher in subsequent completions.
+const CompletionItemAcceptedCommand = "starlark-lsp.completionItemAccepted"
+
+const defaultCompletionHistorySize = 50
+
+// CompletionHistory keeps track of the most recently accepted completion
+// items. It is safe for concurrent use.
+type CompletionHistory struct {
+	mu    sync.Mutex
+	size  int
+	names []string
+}
+
+func NewCompletionHistory() *CompletionHistory {
+	return &CompletionHistory{size: defaultCompletionHistorySize}
+}
+
+// Add records that the completion item with the given label was accepted.
+func (h *CompletionHistory) Add(label string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, name := range h.names {
+		if name == label {
+			h.names = append(h.names[:i], h.names[i+1:]...)
+			break
+		}
+	}
+	h.names = append(h.names, label)
+	if len(h.names) > h.size {
+		h.names = h.names[len(h.names)-h.size:]
+	}
+}
+
+// recency returns a value between 0 (not recently used) and the history size
+// (most recently used).
+func (h *CompletionHistory) recency(label string) int {
+	if h == nil {
+		return 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := len(h.names) - 1; i >= 0; i-- {
+		if h.names[i] == label {
+			return h.size - (len(h.names) - 1 - i)
+		}
+	}
+	return 0
+}
+
+type completionHistoryKey struct{}
+
+// WithCompletionHistory attaches the completion history of the current
+// session to the context.
+func WithCompletionHistory(ctx context.Context, h *CompletionHistory) context.Context {
+	return context.WithValue(ctx, completionHistoryKey{}, h)
+}
+
+func completionHistoryFromContext(ctx context.Context) *CompletionHistory {
+	if ctx == nil {
+		return nil
+	}
+	h, _ := ctx.Value(completionHistoryKey{}).(*CompletionHistory)
+	return h
+}
@@ -2,7 +2,9 @@ package analysis
 
 import (
 	"context"
+	"fmt"
 
+	sitter "github.com/smacker/go-tree-sitter"
 	"go.lsp.dev/protocol"
 
 	"github.com/tilt-dev/starlark-lsp/pkg/document"
@@ -11,24 +13,244 @@ import (
 
 func (a *Analyzer) Hover(ctx context.Context, doc document.Document, pos protocol.Position) *protocol.Hover {
 	pt := query.PositionToPoint(pos)
+	m := newMarkup(hoverMarkupKind(ctx))
+	if fields, key, _, ok := a.dictKeyContext(doc, pt); ok && key != nil {
+		return dictKeyHover(doc, fields, key, m)
+	}
+
+	if pathNode, load, ok := loadPathAtPoint(doc, pt); ok {
+		return loadPathHover(pathNode, load, m)
+	}
+
 	nodes, ok := a.nodesAtPointForCompletion(doc, pt)
 	if !ok {
 		return nil
 	}
 
+	if len(nodes) == 1 && nodes[0].Type() == query.NodeTypeIdentifier {
+		if result, ok := a.identifierHover(doc, nodes[0], m); ok {
+			return result
+		}
+	}
+
 	symbol := a.SymbolAtPosition(doc, pos)
 
 	if symbol.Name == "" {
 		return nil
 	}
 
+	if sig, found := a.hoverSignature(doc, nodes); found {
+		m.Signature(sig, a.normalizedLabel(sig))
+	} else {
+		m.Text(symbol.Detail)
+	}
+	if ls, source, found := loadedSymbol(doc, nodes, symbol); found {
+		origin := fmt.Sprintf("Loaded from %s", m.InlineCode(source))
+		if ls.Alias != ls.Name {
+			origin += fmt.Sprintf(" as %s", m.InlineCode(ls.Alias))
+		}
+		m.Text(origin)
+	}
+
 	r := query.NodesRange(nodes)
 	result := &protocol.Hover{
-		Range: &r,
-		Contents: protocol.MarkupContent{
-			Kind:  protocol.Markdown,
-			Value: symbol.Detail,
-		},
+		Range:    &r,
+		Contents: m.Content(),
 	}
 	return result
 }
+
+// identifierHover describes keyword argument names, function parameters and
+// variables assigned in the document. It returns false for other identifiers,
+// e.g. builtins or loaded symbols.
+func (a *Analyzer) identifierHover(doc document.Document, id *sitter.Node, m *markup) (*protocol.Hover, bool) {
+	name := doc.Content(id)
+	r := query.NodeRange(id)
+	hover := func() (*protocol.Hover, bool) {
+		return &protocol.Hover{Range: &r, Contents: m.Content()}, true
+	}
+
+	if kwarg := id.Parent(); kwarg != nil && kwarg.Type() == query.NodeTypeKeywordArgument {
+		if nameNode := kwarg.ChildByFieldName(query.FieldName); nameNode != nil && nameNode.Equal(id) {
+			args := kwarg.Parent()
+			call := args.Parent()
+			if call == nil || call.Type() != query.NodeTypeCall {
+				return nil, true
+			}
+			fn := call.ChildByFieldName("function")
+			sig, found := a.calledSignature(doc, call, fn, args)
+			if !found {
+				return nil, true
+			}
+			for _, p := range sig.Params {
+				if p.Name == name && !isVariadicParam(p) {
+					m.Parameter(p, Type{}, sig.Docs)
+					m.Text(fmt.Sprintf("Parameter of %s", m.InlineCode(doc.Content(fn))))
+					return hover()
+				}
+			}
+			return nil, true
+		}
+	}
+
+	b, found := findBinding(doc, id, name)
+	if !found {
+		return nil, false
+	}
+	if b.fn != nil {
+		sig := query.ExtractSignature(doc, b.fn)
+		for _, p := range sig.Params {
+			if p.Name == name {
+				m.Parameter(p, a.InferType(doc, id), sig.Docs)
+				m.Text(fmt.Sprintf("Parameter of %s", m.InlineCode(sig.Name)))
+				return hover()
+			}
+		}
+		return nil, false
+	}
+
+	if t := a.InferType(doc, id); !t.IsUnknown() {
+		m.Code(fmt.Sprintf("%s: %s", name, t))
+	}
+	m.Code(bindingSource(doc, b.stmt))
+	if stmt := b.stmt.Parent(); stmt != nil && stmt.Type() == query.NodeTypeExpressionStatement {
+		m.Text(query.ExtractVariableAssignment(doc, stmt).Detail)
+	}
+	return hover()
+}
+
+// hoverSignature finds the signature of the function or method named by the
+// last identifier of the nodes.
+func (a *Analyzer) hoverSignature(doc document.Document, nodes []*sitter.Node) (query.Signature, bool) {
+	var id *sitter.Node
+	for i := len(nodes) - 1; i >= 0 && id == nil; i-- {
+		switch nodes[i].Type() {
+		case query.NodeTypeIdentifier:
+			id = nodes[i]
+		case query.NodeTypeAttribute:
+			id = nodes[i].ChildByFieldName("attribute")
+		}
+	}
+	if id == nil {
+		return query.Signature{}, false
+	}
+	name := doc.Content(id)
+
+	parent := id.Parent()
+	if parent == nil || parent.Type() != query.NodeTypeAttribute {
+		return a.signatureInformation(doc, id, callWithArguments{fnName: name})
+	}
+	if sig, found := a.builtins.Functions[doc.Content(parent)]; found {
+		// the module is shown along with the name of the function
+		sig.Name = doc.Content(parent)
+		return sig, true
+	}
+	for _, alt := range a.InferType(doc, parent.ChildByFieldName("object")).Alternatives() {
+		if class, found := a.builtins.Types[alt.Name]; found {
+			if meth, found := class.FindMethod(name); found {
+				return meth, true
+			}
+		}
+	}
+	return query.Signature{}, false
+}
+
+// normalizedLabel is like Signature.Label(), but with the return type
+// resolved against the builtins, so that e.g. `-> list[str]` is shown as
+// `-> List[String]`.
+func (a *Analyzer) normalizedLabel(sig query.Signature) string {
+	if t := a.builtins.ResolveType(sig.ReturnTypeExpr()); !t.IsUnknown() {
+		sig.ReturnType = t.String()
+	}
+	return sig.Label()
+}
+
+// loadPathAtPoint finds the path argument of a load statement at the point,
+// along with the parsed load statement.
+func loadPathAtPoint(doc document.Document, pt sitter.Point) (*sitter.Node, document.LoadStatement, bool) {
+	node, ok := query.NodeAtPoint(doc, pt)
+	for ok && node.Type() != query.NodeTypeString {
+		node = node.Parent()
+		ok = node != nil
+	}
+	if !ok {
+		return nil, document.LoadStatement{}, false
+	}
+
+	args := node.Parent()
+	if args == nil || args.Type() != query.NodeTypeArgList || args.NamedChildCount() == 0 || args.NamedChild(0).StartByte() != node.StartByte() {
+		return nil, document.LoadStatement{}, false
+	}
+	call := args.Parent()
+	if call == nil || call.Type() != query.NodeTypeCall || doc.Content(call.ChildByFieldName("function")) != "load" {
+		return nil, document.LoadStatement{}, false
+	}
+
+	r := query.NodeRange(call)
+	for _, load := range doc.Loads() {
+		if load.Range == r {
+			return node, load, true
+		}
+	}
+	return nil, document.LoadStatement{}, false
+}
+
+// loadPathHover shows the resolved path of the loaded module and its
+// docstring, or why it couldn't be loaded.
+func loadPathHover(pathNode *sitter.Node, load document.LoadStatement, m *markup) *protocol.Hover {
+	if load.URI == "" {
+		return nil
+	}
+	path, err := uriFilename(load.URI)
+	if err != nil {
+		path = string(load.URI)
+	}
+
+	m.Text(m.InlineCode(path))
+	m.Text(load.Docs.Description)
+	for _, diag := range load.Diagnostics {
+		m.Text(diag.Message)
+	}
+
+	r := query.NodeRange(pathNode)
+	return &protocol.Hover{
+		Range:    &r,
+		Contents: m.Content(),
+	}
+}
+
+// loadedSymbol finds the load statement that imported the symbol, if any,
+// and the path of the module it was loaded from.
+func loadedSymbol(doc document.Document, nodes []*sitter.Node, symbol query.Symbol) (document.LoadSymbol, string, bool) {
+	if len(nodes) != 1 || nodes[0].Type() != query.NodeTypeIdentifier {
+		return document.LoadSymbol{}, "", false
+	}
+	source := symbolSource(doc, symbol)
+	if source == "" {
+		return document.LoadSymbol{}, "", false
+	}
+
+	for _, load := range doc.Loads() {
+		for _, ls := range load.Symbols {
+			if ls.Alias == symbol.Name {
+				return ls, source, true
+			}
+		}
+	}
+	return document.LoadSymbol{}, "", false
+}
+
+func dictKeyHover(doc document.Document, fields []literalField, key *sitter.Node, m *markup) *protocol.Hover {
+	name := query.Unquote(doc.Input(), key)
+	for _, f := range fields {
+		if f.Name == name {
+			m.Text(f.Detail)
+			r := query.NodeRange(key)
+			return &protocol.Hover{
+				Range:    &r,
+				Contents: m.Content(),
+			}
+		}
+	}
+	return nil
+}
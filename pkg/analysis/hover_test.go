@@ -1,11 +1,14 @@
 package analysis
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/stretchr/testify/require"
 	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
 
 	"github.com/tilt-dev/starlark-lsp/pkg/document"
 	"github.com/tilt-dev/starlark-lsp/pkg/query"
@@ -19,11 +22,11 @@ func TestBasicHover(t *testing.T) {
 		expectedHoverRangeContent string
 		expectedHoverContent      string
 	}{
-		{"func", 0, 1, "foo", "desc1"},
+		{"func", 0, 1, "foo", "```python\ndef foo()\n```\n\ndesc1"},
 		{"var", 0, 6, "hello", "desc2"},
-		{"module func - hover over module", 1, 1, "baz.quu", "desc3"},
-		{"module func - hover over dot", 1, 3, "baz.quu", "desc3"},
-		{"module func - hover over func", 1, 5, "baz.quu", "desc3"},
+		{"module func - hover over module", 1, 1, "baz.quu", "```python\ndef quu()\n```\n\nFunction of the `baz` module\n\ndesc3"},
+		{"module func - hover over dot", 1, 3, "baz.quu", "```python\ndef quu()\n```\n\nFunction of the `baz` module\n\ndesc3"},
+		{"module func - hover over func", 1, 5, "baz.quu", "```python\ndef quu()\n```\n\nFunction of the `baz` module\n\ndesc3"},
 		{"module var - hover over module", 1, 9, "qux.fd", "desc4"},
 		{"module var - hover over dot", 1, 11, "qux.fd", "desc4"},
 		{"module var - hover over var", 1, 12, "qux.fd", "desc4"},
@@ -45,6 +48,16 @@ baz.quu(qux.fd)`)
 	}
 }
 
+func TestHoverModuleFunction(t *testing.T) {
+	f := newFixture(t)
+	f.AddSymbol("os.getcwd", "")
+	f.builtins.Functions["os.getcwd"] = query.Signature{Name: "getcwd", ReturnType: "str"}
+
+	doc := f.MainDoc(`os.getcwd()`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Character: 4})
+	assertHoverResult(t, doc, "os.getcwd", "```python\ndef getcwd() -> String\n```\n\nFunction of the `os` module", result)
+}
+
 func TestHoverFuncDefinedInFile(t *testing.T) {
 	f := newFixture(t)
 
@@ -58,7 +71,7 @@ def foo():
 foo(hello)
 `)
 	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 7, Character: 2})
-	assertHoverResult(t, doc, "foo", "foos a bar", result)
+	assertHoverResult(t, doc, "foo", "```python\ndef foo()\n```\n\nfoos a bar", result)
 }
 
 func TestHoverFuncDefinedWithArgInFile(t *testing.T) {
@@ -79,7 +92,9 @@ def foo(name: str) -> str:
 foo(hello)
 `)
 	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 12, Character: 2})
-	assertHoverResult(t, doc, "foo", "foos a bar\n## Parameters\nname: name of the bar\n## Returns\nname of the foo", result)
+	assertHoverResult(t, doc, "foo", "```python\ndef foo(name: str) -> String\n```\n\nfoos a bar\n\n"+
+		"**Parameters**\n\n| Parameter | Type | Default | Description |\n| --- | --- | --- | --- |\n| `name` | `str` |  | name of the bar |\n\n"+
+		"**Returns**\n\nname of the foo", result)
 }
 
 func TestHoverFuncDefinedWithMultiArgs(t *testing.T) {
@@ -101,7 +116,10 @@ def foo(name1: str, name2: str) -> str:
 foo(hello, bye)
 `)
 	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 13, Character: 2})
-	assertHoverResult(t, doc, "foo", "foos a bar\n## Parameters\nname1: name1 of the bar\\\nname2: name2 of the bar\n## Returns\nname of the foo", result)
+	assertHoverResult(t, doc, "foo", "```python\ndef foo(name1: str, name2: str) -> String\n```\n\nfoos a bar\n\n"+
+		"**Parameters**\n\n| Parameter | Type | Default | Description |\n| --- | --- | --- | --- |\n"+
+		"| `name1` | `str` |  | name1 of the bar |\n| `name2` | `str` |  | name2 of the bar |\n\n"+
+		"**Returns**\n\nname of the foo", result)
 }
 
 func TestHoverNoMatch(t *testing.T) {
@@ -144,3 +162,237 @@ func contentByRange(doc document.Document, r protocol.Range) string {
 	}
 	return doc.Content(n)
 }
+
+func TestHoverStructFieldAndDictKey(t *testing.T) {
+	f := newFixture(t)
+
+	doc := f.MainDoc(`cfg = struct(name="x")
+d = {"port": 8080}
+print(cfg.name, d["port"])`)
+
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 2, Character: 12})
+	require.NotNil(t, result)
+	require.Equal(t, `name="x"`, result.Contents.Value)
+
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Line: 2, Character: 20})
+	assertHoverResult(t, doc, `"port"`, `"port": 8080`, result)
+}
+
+func TestHoverMethodOfInferredType(t *testing.T) {
+	f := newFixture(t)
+	_ = WithStarlarkBuiltins()(f.a)
+
+	doc := f.MainDoc(`for name in dir(x):
+  name.upper()`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 1, Character: 8})
+	require.NotNil(t, result)
+	require.Contains(t, result.Contents.Value, "S.upper()")
+}
+
+func TestHoverNormalizedReturnType(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(`
+def names(prefix: str = "") -> list[str]:
+  """Lists the names."""
+  pass
+
+class String:
+  def split(self) -> List[String]:
+    """Splits the string."""
+    pass
+`)
+
+	doc := f.MainDoc(`names()
+"a b".split()`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 0, Character: 2})
+	assertHoverResult(t, doc, "names", "```python\ndef names(prefix: str = \"\") -> List[String]\n```\n\nLists the names.\n\n"+
+		"**Parameters**\n\n| Parameter | Type | Default | Description |\n| --- | --- | --- | --- |\n| `prefix` | `str` | `\"\"` |  |", result)
+
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Line: 1, Character: 8})
+	require.NotNil(t, result)
+	require.Equal(t, "```python\ndef split() -> List[String]\n```\n\nSplits the string.", result.Contents.Value)
+}
+
+func TestHoverLoadPath(t *testing.T) {
+	f := newFixture(t)
+	f.Document("lib/util.star", `"""Utilities for the Tiltfile."""
+
+def helper():
+  pass
+`)
+
+	doc := f.MainDoc(`load("./lib/util.star", "helper")
+load("./missing.star", "x")`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 0, Character: 8})
+	assertHoverResult(t, doc, `"./lib/util.star"`,
+		fmt.Sprintf("`%s`\n\nUtilities for the Tiltfile.", uri.File("lib/util.star").Filename()), result)
+
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Line: 1, Character: 8})
+	require.NotNil(t, result)
+	require.Contains(t, result.Contents.Value, uri.File("missing.star").Filename())
+
+	// the symbol names aren't paths
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Line: 0, Character: 27})
+	require.Nil(t, result)
+}
+
+func TestHoverLoadedSymbol(t *testing.T) {
+	f := newFixture(t)
+	f.Document("lib/util.star", `
+def helper(name, count = 1):
+  """Helps with things.
+
+  Args:
+    name: what to help with
+  """
+  pass
+
+VERSION = "1.0"
+`)
+
+	doc := f.MainDoc(`load("./lib/util.star", "VERSION", assist="helper")
+assist("x")
+print(VERSION)`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 1, Character: 2})
+	assertHoverResult(t, doc, "assist", "```python\ndef helper(name, count = 1)\n```\n\n"+
+		"Helps with things.\n\n**Parameters**\n\n| Parameter | Type | Default | Description |\n| --- | --- | --- | --- |\n"+
+		"| `name` |  |  | what to help with |\n| `count` |  | `1` |  |\n\n"+
+		"Loaded from `lib/util.star` as `assist`", result)
+
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Line: 2, Character: 8})
+	require.NotNil(t, result)
+	require.True(t, strings.HasSuffix(result.Contents.Value, "Loaded from `lib/util.star`"), result.Contents.Value)
+}
+
+const documentedFunctionFixture = `
+def run(cmd: str | list, *args, env: dict = {}, **kwargs) -> str:
+  """Runs a command.
+
+  Args:
+    cmd: command to run
+    env: extra environment variables
+    echo_off: don't print the command
+
+  Returns:
+    The output of the command.
+
+  Raises:
+    Error: if the command fails
+
+  Examples:
+    run("make")
+    run(["echo", "hi"])
+  """
+  pass
+`
+
+func TestHoverRichMarkdown(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(documentedFunctionFixture)
+
+	doc := f.MainDoc(`run("ls")`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Character: 1})
+	assertHoverResult(t, doc, "run", "```python\ndef run(cmd: str | list, *args, env: dict = {}, **kwargs) -> String\n```\n\n"+
+		"Runs a command.\n\n"+
+		"**Parameters**\n\n| Parameter | Type | Default | Description |\n| --- | --- | --- | --- |\n"+
+		"| `cmd` | `str \\| list` |  | command to run |\n"+
+		"| `*args` |  |  |  |\n"+
+		"| `env` | `dict` | `{}` | extra environment variables |\n"+
+		"| `**kwargs` |  |  |  |\n"+
+		"| `echo_off` |  |  | don't print the command |\n\n"+
+		"**Returns**\n\nThe output of the command.\n\n"+
+		"**Raises**\n\n- `Error`: if the command fails\n\n"+
+		"**Examples**\n\n```python\nrun(\"make\")\nrun([\"echo\", \"hi\"])\n```", result)
+	require.Equal(t, protocol.Markdown, result.Contents.Kind)
+}
+
+func TestHoverPlainText(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(documentedFunctionFixture)
+
+	ctx := WithClientCapabilities(f.ctx, protocol.ClientCapabilities{
+		TextDocument: &protocol.TextDocumentClientCapabilities{
+			Hover: &protocol.HoverTextDocumentClientCapabilities{
+				ContentFormat: []protocol.MarkupKind{protocol.PlainText},
+			},
+		},
+	})
+	doc := f.MainDoc(`run("ls")`)
+	result := f.a.Hover(ctx, doc, protocol.Position{Character: 1})
+	assertHoverResult(t, doc, "run", "def run(cmd: str | list, *args, env: dict = {}, **kwargs) -> String\n\n"+
+		"Runs a command.\n\n"+
+		"Parameters:\n"+
+		"  cmd (str | list): command to run\n"+
+		"  *args\n"+
+		"  env (dict, default {}): extra environment variables\n"+
+		"  **kwargs\n"+
+		"  echo_off: don't print the command\n\n"+
+		"Returns:\n  The output of the command.\n\n"+
+		"Raises:\n  Error: if the command fails\n\n"+
+		"Examples:\n  run(\"make\")\n  run([\"echo\", \"hi\"])", result)
+	require.Equal(t, protocol.PlainText, result.Contents.Kind)
+}
+
+func TestHoverKeywordArgument(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(`
+def docker_build(ref: str, context: str = ".", **kwargs):
+  """Builds a Docker image.
+
+  Args:
+    ref: name for this image
+    context: path to use as the Docker build context
+  """
+  pass
+`)
+
+	doc := f.MainDoc(`docker_build(ref='x', context='.', extra=1)`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Character: 23})
+	assertHoverResult(t, doc, "context", "```python\ncontext: str = \".\"\n```\n\n"+
+		"path to use as the Docker build context\n\nParameter of `docker_build`", result)
+
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Character: 36})
+	require.Nil(t, result)
+}
+
+func TestHoverParameter(t *testing.T) {
+	f := newFixture(t)
+
+	doc := f.MainDoc(`def deploy(name, replicas = 1):
+  """Deploys the app.
+
+  Args:
+    replicas: number of pods
+  """
+  print(name, replicas)
+`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 6, Character: 15})
+	assertHoverResult(t, doc, "replicas", "```python\nreplicas: int = 1\n```\n\nnumber of pods\n\nParameter of `deploy`", result)
+
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Line: 0, Character: 12})
+	assertHoverResult(t, doc, "name", "```python\nname\n```\n\nParameter of `deploy`", result)
+}
+
+func TestHoverLocalVariable(t *testing.T) {
+	f := newFixture(t)
+
+	doc := f.MainDoc(`def build(items):
+  names = []
+  for item in items:
+    names.append(str(item))
+  label = "%d names" % len(names)
+  return label
+
+port = 8080
+"""The port to listen on."""
+print(port)
+`)
+	result := f.a.Hover(f.ctx, doc, protocol.Position{Line: 5, Character: 10})
+	assertHoverResult(t, doc, "label", "```python\nlabel: String\n```\n\n```python\nlabel = \"%d names\" % len(names)\n```", result)
+
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Line: 3, Character: 21})
+	assertHoverResult(t, doc, "item", "```python\nitem: Any\n```\n\n```python\nfor item in items:\n```", result)
+
+	result = f.a.Hover(f.ctx, doc, protocol.Position{Line: 9, Character: 7})
+	assertHoverResult(t, doc, "port", "```python\nport: int\n```\n\n```python\nport = 8080\n```\n\nThe port to listen on.", result)
+}
@@ -0,0 +1,989 @@
+package analysis
+
+import (
+	"strconv"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// maxTypeInferenceDepth bounds the number of nested expressions, variables
+// and function returns that are followed to infer a type.
+const maxTypeInferenceDepth = 25
+
+// typeInference infers the types of expressions in a document.
+//
+// Variables are resolved flow-sensitively: the type of a variable is the type
+// of the assignments that can reach the point where it's used, so that after
+// `x = "a"` followed by `x = 1`, `x` is an `int`, while after
+// `if c: x = "a"` / `else: x = 1`, `x` is `String | int`.
+//
+// The types of expressions and the return types of functions are cached, so
+// that each is inferred once. A function whose return type is being inferred
+// returns Unknown when it's called again, e.g. recursively, and the types that
+// depend on that aren't cached until its return type is known.
+type typeInference struct {
+	a     *Analyzer
+	doc   document.Document
+	depth int
+
+	nodeTypes   map[nodeKey]Type
+	returnTypes map[nodeKey]Type
+	// inferring are the functions whose return types are being inferred, by
+	// their index in the stack of functions being inferred
+	inferring map[nodeKey]int
+	// dependsOn is the lowest index of a function being inferred that the
+	// type being inferred depends on, or noDependency
+	dependsOn int
+}
+
+// noDependency is the dependsOn of a type that depends on no function whose
+// return type is being inferred.
+const noDependency = int(^uint(0) >> 1)
+
+// nodeKey identifies a node of the document.
+type nodeKey struct {
+	start, end uint32
+	symbol     sitter.Symbol
+}
+
+func keyOf(node *sitter.Node) nodeKey {
+	return nodeKey{start: node.StartByte(), end: node.EndByte(), symbol: node.Symbol()}
+}
+
+func (a *Analyzer) newTypeInference(doc document.Document) *typeInference {
+	return &typeInference{
+		a:           a,
+		doc:         doc,
+		nodeTypes:   make(map[nodeKey]Type),
+		returnTypes: make(map[nodeKey]Type),
+		inferring:   make(map[nodeKey]int),
+		dependsOn:   noDependency,
+	}
+}
+
+// InferType determines the type of the expression.
+func (a *Analyzer) InferType(doc document.Document, node *sitter.Node) Type {
+	return a.newTypeInference(doc).exprType(node)
+}
+
+func (ti *typeInference) exprType(node *sitter.Node) Type {
+	if node == nil {
+		return AnyType
+	}
+	if ti.depth > maxTypeInferenceDepth {
+		// the type is incomplete, so the types depending on it aren't cached
+		ti.dependsOn = -1
+		return AnyType
+	}
+	key := keyOf(node)
+	if t, ok := ti.nodeTypes[key]; ok {
+		return t
+	}
+
+	dependsOn := ti.dependsOn
+	ti.dependsOn = noDependency
+	ti.depth++
+	t := ti.uncachedExprType(node)
+	ti.depth--
+	if ti.dependsOn == noDependency {
+		ti.nodeTypes[key] = t
+	}
+	if dependsOn < ti.dependsOn {
+		ti.dependsOn = dependsOn
+	}
+	return t
+}
+
+func (ti *typeInference) uncachedExprType(node *sitter.Node) Type {
+	switch node.Type() {
+	case query.NodeTypeString, "concatenated_string":
+		return StringType
+	case "integer":
+		return IntType
+	case "float":
+		return FloatType
+	case "true", "false":
+		return BoolType
+	case "none":
+		return NoneType
+	case query.NodeTypeList:
+		return NamedType(typeNameList, ti.joinChildren(node))
+	case "set":
+		return NamedType(typeNameSet, ti.joinChildren(node))
+	case "tuple", "expression_list":
+		args := []Type{}
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			args = append(args, ti.exprType(node.NamedChild(i)))
+		}
+		return NamedType(typeNameTuple, args...)
+	case query.NodeTypeDictionary:
+		var keys, values Type
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			if pair := node.NamedChild(i); pair.Type() == "pair" {
+				keys = keys.Join(ti.exprType(pair.ChildByFieldName("key")))
+				values = values.Join(ti.exprType(pair.ChildByFieldName("value")))
+			}
+		}
+		if keys.IsUnknown() {
+			return NamedType(typeNameDict)
+		}
+		return NamedType(typeNameDict, keys, values)
+	case "list_comprehension", "generator_expression":
+		return NamedType(typeNameList, ti.exprType(node.ChildByFieldName(query.FieldBody)))
+	case "set_comprehension":
+		return NamedType(typeNameSet, ti.exprType(node.ChildByFieldName(query.FieldBody)))
+	case "dictionary_comprehension":
+		pair := node.ChildByFieldName(query.FieldBody)
+		if pair == nil || pair.Type() != "pair" {
+			return NamedType(typeNameDict)
+		}
+		return NamedType(typeNameDict,
+			ti.exprType(pair.ChildByFieldName("key")),
+			ti.exprType(pair.ChildByFieldName("value")))
+	case "parenthesized_expression":
+		return ti.exprType(node.NamedChild(0))
+	case query.NodeTypeIdentifier:
+		return ti.identifierType(node)
+	case query.NodeTypeAttribute:
+		return ti.attributeType(node)
+	case "subscript":
+		return ti.subscriptType(node)
+	case query.NodeTypeCall:
+		return ti.callType(node)
+	case "binary_operator":
+		op := node.ChildByFieldName("operator")
+		if op == nil {
+			return AnyType
+		}
+		return binaryOperatorType(op.Type(),
+			ti.exprType(node.ChildByFieldName("left")),
+			ti.exprType(node.ChildByFieldName("right")))
+	case "comparison_operator", "not_operator":
+		return BoolType
+	case "boolean_operator":
+		return ti.exprType(node.ChildByFieldName("left")).Join(ti.exprType(node.ChildByFieldName("right")))
+	case "unary_operator":
+		return ti.exprType(node.ChildByFieldName("argument"))
+	case "conditional_expression":
+		return ti.exprType(node.NamedChild(0)).Join(ti.exprType(node.NamedChild(2)))
+	case "lambda":
+		return NamedType(typeNameFunction)
+	}
+	return AnyType
+}
+
+func (ti *typeInference) joinChildren(node *sitter.Node) Type {
+	var t Type
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		t = t.Join(ti.exprType(node.NamedChild(i)))
+	}
+	return t
+}
+
+func (ti *typeInference) identifierType(node *sitter.Node) Type {
+	name := ti.doc.Content(node)
+	if t, ok := ti.bindingType(node, name); ok {
+		return t
+	}
+	sym, found := ti.a.FindDefinition(ti.doc, node, name)
+	if !found {
+		return AnyType
+	}
+	if _, isFunc := ti.a.builtins.Functions[name]; isFunc {
+		return NamedType(typeNameFunction)
+	}
+	return symbolType(sym)
+}
+
+// bindingType determines the type of the variable with the given name at the
+// node from the assignments, loops, comprehensions and parameters in the
+// document that bind it.
+func (ti *typeInference) bindingType(node *sitter.Node, name string) (Type, bool) {
+	if t, ok := ti.targetBindingType(node, name); ok {
+		return t, true
+	}
+
+	var acc Type
+	for cur, parent := node, node.Parent(); parent != nil; cur, parent = parent, parent.Parent() {
+		switch parent.Type() {
+		case query.NodeTypeModule, query.NodeTypeBlock, query.NodeTypeERROR:
+			t, definite := ti.reachingType(precedingStatements(parent, cur), name)
+			acc = acc.Join(t)
+			if definite {
+				return acc, true
+			}
+		case query.NodeTypeForStatement:
+			if body := parent.ChildByFieldName(query.FieldBody); body != nil && body.Equal(cur) {
+				if t, ok := ti.loopTargetType(parent, name); ok {
+					return acc.Join(t), true
+				}
+			}
+		case "list_comprehension", "set_comprehension", "dictionary_comprehension", "generator_expression":
+			for i := 0; i < int(parent.NamedChildCount()); i++ {
+				clause := parent.NamedChild(i)
+				if clause.Type() != "for_in_clause" {
+					continue
+				}
+				// the iterable of a clause is evaluated before it binds its
+				// variables
+				if right := clause.ChildByFieldName("right"); right != nil && nodeContains(right, node) {
+					continue
+				}
+				if t, ok := ti.loopTargetType(clause, name); ok {
+					return acc.Join(t), true
+				}
+			}
+		case query.NodeTypeFunctionDef:
+			if body := parent.ChildByFieldName(query.FieldBody); body != nil && body.Equal(cur) {
+				if t, ok := ti.parameterType(parent.ChildByFieldName(query.FieldParameters), name); ok {
+					return acc.Join(t), true
+				}
+			}
+		case "lambda":
+			if params := parent.ChildByFieldName(query.FieldParameters); params != nil && !params.Equal(cur) {
+				if _, ok := ti.parameterType(params, name); ok {
+					return AnyType, true
+				}
+			}
+		}
+	}
+	return acc, !acc.IsUnknown()
+}
+
+// targetBindingType determines the type of a node that is itself the target
+// of an assignment or loop.
+func (ti *typeInference) targetBindingType(node *sitter.Node, name string) (Type, bool) {
+	for n := node.Parent(); n != nil; n = n.Parent() {
+		switch n.Type() {
+		case "pattern_list", "tuple_pattern", "list_pattern":
+			continue
+		case query.NodeTypeAssignment:
+			if left := n.ChildByFieldName("left"); left != nil && nodeContains(left, node) {
+				return ti.assignmentType(n, name)
+			}
+		case query.NodeTypeForStatement, "for_in_clause":
+			if left := n.ChildByFieldName("left"); left != nil && nodeContains(left, node) {
+				return ti.loopTargetType(n, name)
+			}
+		}
+		return Type{}, false
+	}
+	return Type{}, false
+}
+
+// precedingStatements returns the statements of the block that come before
+// the given child.
+func precedingStatements(block, child *sitter.Node) []*sitter.Node {
+	var stmts []*sitter.Node
+	for i := 0; i < int(block.NamedChildCount()); i++ {
+		stmt := block.NamedChild(i)
+		if stmt.StartByte() >= child.StartByte() {
+			break
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// reachingType determines the type of the variable after executing the
+// statements, and whether the statements definitely assign it.
+func (ti *typeInference) reachingType(stmts []*sitter.Node, name string) (Type, bool) {
+	var acc Type
+	for i := len(stmts) - 1; i >= 0; i-- {
+		t, definite := ti.statementType(stmts[i], name)
+		acc = acc.Join(t)
+		if definite {
+			return acc, true
+		}
+	}
+	return acc, false
+}
+
+func (ti *typeInference) blockType(block *sitter.Node, name string) (Type, bool) {
+	if block == nil {
+		return Type{}, false
+	}
+	return ti.reachingType(namedChildren(block), name)
+}
+
+// statementType determines the type that the statement assigns to the
+// variable, if any, and whether it always does so.
+func (ti *typeInference) statementType(stmt *sitter.Node, name string) (Type, bool) {
+	switch stmt.Type() {
+	case query.NodeTypeExpressionStatement:
+		if child := stmt.NamedChild(0); child != nil {
+			return ti.statementType(child, name)
+		}
+	case query.NodeTypeAssignment:
+		return ti.assignmentType(stmt, name)
+	case "augmented_assignment":
+		left := stmt.ChildByFieldName("left")
+		if left != nil && left.Type() == query.NodeTypeIdentifier && ti.doc.Content(left) == name {
+			// the variable keeps its type for the common cases of extending
+			// lists and strings or adding numbers, so join with the previous
+			// assignments
+			return ti.exprType(stmt.ChildByFieldName("right")), false
+		}
+	case query.NodeTypeFunctionDef:
+		if ti.doc.Content(stmt.ChildByFieldName(query.FieldName)) == name {
+			return NamedType(typeNameFunction), true
+		}
+	case "decorated_definition":
+		return ti.statementType(stmt.ChildByFieldName("definition"), name)
+	case query.NodeTypeIfStatement:
+		t, definite := ti.blockType(stmt.ChildByFieldName("consequence"), name)
+		hasElse := false
+		for i := 0; i < int(stmt.NamedChildCount()); i++ {
+			var bt Type
+			var bdef bool
+			switch clause := stmt.NamedChild(i); clause.Type() {
+			case "elif_clause":
+				bt, bdef = ti.blockType(clause.ChildByFieldName("consequence"), name)
+			case "else_clause":
+				hasElse = true
+				bt, bdef = ti.blockType(clause.ChildByFieldName(query.FieldBody), name)
+			default:
+				continue
+			}
+			t = t.Join(bt)
+			definite = definite && bdef
+		}
+		return t, definite && hasElse
+	case query.NodeTypeForStatement, "while_statement":
+		// loops might not run at all
+		t, _ := ti.blockType(stmt.ChildByFieldName(query.FieldBody), name)
+		if stmt.Type() == query.NodeTypeForStatement {
+			if target, ok := ti.loopTargetType(stmt, name); ok {
+				t = t.Join(target)
+			}
+		}
+		if alt := stmt.ChildByFieldName("alternative"); alt != nil {
+			at, _ := ti.blockType(alt.ChildByFieldName(query.FieldBody), name)
+			t = t.Join(at)
+		}
+		return t, false
+	case "with_statement":
+		return ti.blockType(stmt.ChildByFieldName(query.FieldBody), name)
+	case "try_statement":
+		t, _ := ti.blockType(stmt.ChildByFieldName(query.FieldBody), name)
+		for i := 0; i < int(stmt.NamedChildCount()); i++ {
+			clause := stmt.NamedChild(i)
+			switch clause.Type() {
+			case "except_clause", "else_clause":
+				ct, _ := ti.blockType(lastNamedChild(clause), name)
+				t = t.Join(ct)
+			case "finally_clause":
+				if ft, definite := ti.blockType(lastNamedChild(clause), name); definite {
+					return ft, true
+				}
+			}
+		}
+		return t, false
+	}
+	return Type{}, false
+}
+
+// assignmentType determines the type that the assignment, which could be
+// chained (`a = b = 1`), assigns to the variable.
+func (ti *typeInference) assignmentType(assign *sitter.Node, name string) (Type, bool) {
+	var targets []*sitter.Node
+	value := assign
+	for value != nil && value.Type() == query.NodeTypeAssignment {
+		targets = append(targets, value.ChildByFieldName("left"))
+		value = value.ChildByFieldName("right")
+	}
+	for _, target := range targets {
+		if binds(ti.doc, target, name) {
+			if value == nil {
+				// a type annotation without a value, e.g. `x: int`
+				return ti.annotationType(assign.ChildByFieldName("type")), true
+			}
+			return ti.targetType(target, name, ti.exprType(value)), true
+		}
+	}
+	return Type{}, false
+}
+
+func (ti *typeInference) loopTargetType(loop *sitter.Node, name string) (Type, bool) {
+	target := loop.ChildByFieldName("left")
+	if !binds(ti.doc, target, name) {
+		return Type{}, false
+	}
+	return ti.targetType(target, name, elementType(ti.exprType(loop.ChildByFieldName("right")))), true
+}
+
+// targetType determines the type of the variable when the value is assigned
+// to the target, which might unpack it, e.g. `a, b = value`.
+func (ti *typeInference) targetType(target *sitter.Node, name string, value Type) Type {
+	switch target.Type() {
+	case query.NodeTypeIdentifier:
+		return value
+	case "pattern_list", "tuple_pattern", "list_pattern", "tuple", query.NodeTypeList:
+		for i := 0; i < int(target.NamedChildCount()); i++ {
+			child := target.NamedChild(i)
+			if binds(ti.doc, child, name) {
+				var elem Type
+				for _, alt := range value.Alternatives() {
+					if alt.Name == typeNameTuple && len(alt.Args) == int(target.NamedChildCount()) {
+						elem = elem.Join(alt.Args[i])
+					} else {
+						elem = elem.Join(elementType(alt))
+					}
+				}
+				if elem.IsUnknown() {
+					elem = AnyType
+				}
+				return ti.targetType(child, name, elem)
+			}
+		}
+	}
+	return AnyType
+}
+
+// binds reports whether assigning to the target binds the name.
+func binds(doc document.Document, target *sitter.Node, name string) bool {
+	if target == nil {
+		return false
+	}
+	switch target.Type() {
+	case query.NodeTypeIdentifier:
+		return doc.Content(target) == name
+	case "pattern_list", "tuple_pattern", "list_pattern", "tuple", query.NodeTypeList:
+		for i := 0; i < int(target.NamedChildCount()); i++ {
+			if binds(doc, target.NamedChild(i), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parameterType determines the type of the function parameter with the
+// given name from its type hint or default value.
+func (ti *typeInference) parameterType(params *sitter.Node, name string) (Type, bool) {
+	if params == nil {
+		return Type{}, false
+	}
+	for i := 0; i < int(params.NamedChildCount()); i++ {
+		param := params.NamedChild(i)
+		switch param.Type() {
+		case query.NodeTypeIdentifier:
+			if ti.doc.Content(param) == name {
+				return AnyType, true
+			}
+		case "default_parameter", "typed_default_parameter", "typed_parameter":
+			id := param.ChildByFieldName(query.FieldName)
+			if id == nil {
+				id = param.NamedChild(0)
+			}
+			if id == nil || ti.doc.Content(id) != name {
+				continue
+			}
+			if hint := param.ChildByFieldName("type"); hint != nil {
+				return ti.annotationType(hint), true
+			}
+			// None defaults are commonly used for optional values of any type
+			if t := ti.exprType(param.ChildByFieldName("value")); !t.Equal(NoneType) {
+				return t, true
+			}
+			return AnyType, true
+		case "list_splat_pattern":
+			if ti.doc.Content(param.NamedChild(0)) == name {
+				return NamedType(typeNameTuple), true
+			}
+		case "dictionary_splat_pattern":
+			if ti.doc.Content(param.NamedChild(0)) == name {
+				return NamedType(typeNameDict, StringType, AnyType), true
+			}
+		}
+	}
+	return Type{}, false
+}
+
+func (ti *typeInference) annotationType(hint *sitter.Node) Type {
+	if hint == nil {
+		return AnyType
+	}
+	expr, err := query.ParseTypeExpr(ti.doc.Content(hint))
+	if err != nil || expr.IsZero() {
+		return AnyType
+	}
+	return ti.a.builtins.ResolveType(expr)
+}
+
+func (ti *typeInference) attributeType(node *sitter.Node) Type {
+	obj := node.ChildByFieldName("object")
+	attr := ti.doc.Content(node.ChildByFieldName("attribute"))
+
+	if fields, kind := ti.a.literalFields(ti.doc, obj); kind == literalStruct {
+		if value := findField(fields, attr); value != nil {
+			return ti.exprType(value)
+		}
+		return AnyType
+	}
+
+	var t Type
+	for _, alt := range ti.exprType(obj).Alternatives() {
+		if class, found := ti.a.builtins.Types[alt.Name]; found {
+			if _, isMethod := class.FindMethod(attr); isMethod {
+				t = t.Join(NamedType(typeNameFunction))
+			}
+		}
+	}
+	if !t.IsUnknown() {
+		return t
+	}
+
+	// members of builtin modules, e.g. `os.environ`
+	if sym, found := ti.builtinSymbol(node); found {
+		return symbolType(sym)
+	}
+	return AnyType
+}
+
+// builtinSymbol finds the builtin for a dotted name like `os.path.join`.
+func (ti *typeInference) builtinSymbol(node *sitter.Node) (query.Symbol, bool) {
+	var names []string
+	for n := node; ; n = n.ChildByFieldName("object") {
+		if n == nil {
+			return query.Symbol{}, false
+		}
+		if n.Type() == query.NodeTypeIdentifier {
+			names = append([]string{ti.doc.Content(n)}, names...)
+			break
+		}
+		if n.Type() != query.NodeTypeAttribute {
+			return query.Symbol{}, false
+		}
+		names = append([]string{ti.doc.Content(n.ChildByFieldName("attribute"))}, names...)
+	}
+
+	symbols := ti.a.builtins.Symbols
+	var sym query.Symbol
+	for _, name := range names {
+		sym = SymbolMatching(symbols, name)
+		if sym.Name == "" {
+			return query.Symbol{}, false
+		}
+		symbols = sym.Children
+	}
+	return sym, true
+}
+
+func (ti *typeInference) subscriptType(node *sitter.Node) Type {
+	value := node.ChildByFieldName("value")
+	index := node.ChildByFieldName("subscript")
+
+	// the values of dict literals are known per key
+	if index != nil && index.Type() == query.NodeTypeString {
+		if fields, kind := ti.a.literalFields(ti.doc, value); kind == literalDict {
+			if v := findField(fields, query.Unquote(ti.doc.Input(), index)); v != nil {
+				return ti.exprType(v)
+			}
+		}
+	}
+
+	var t Type
+	for _, alt := range ti.exprType(value).Alternatives() {
+		switch alt.Name {
+		case typeNameList:
+			if index != nil && index.Type() == "slice" {
+				t = t.Join(alt)
+			} else {
+				t = t.Join(alt.Arg(0))
+			}
+		case typeNameTuple:
+			if index != nil && index.Type() == "slice" {
+				t = t.Join(NamedType(typeNameTuple))
+			} else if i, err := strconv.Atoi(ti.doc.Content(index)); err == nil && i >= 0 && i < len(alt.Args) {
+				t = t.Join(alt.Args[i])
+			} else {
+				t = t.Join(elementType(alt))
+			}
+		case typeNameDict:
+			t = t.Join(alt.Arg(1))
+		case typeNameString:
+			t = t.Join(StringType)
+		default:
+			return AnyType
+		}
+	}
+	if t.IsUnknown() {
+		return AnyType
+	}
+	return t
+}
+
+func (ti *typeInference) callType(call *sitter.Node) Type {
+	fn := call.ChildByFieldName("function")
+	args := call.ChildByFieldName("arguments")
+	if fn == nil {
+		return AnyType
+	}
+
+	switch fn.Type() {
+	case query.NodeTypeAttribute:
+		method := ti.doc.Content(fn.ChildByFieldName("attribute"))
+		if t := ti.methodCallType(ti.exprType(fn.ChildByFieldName("object")), method, args); !t.IsUnknown() {
+			return t
+		}
+	case query.NodeTypeIdentifier:
+		name := ti.doc.Content(fn)
+		if ti.a.structConstructors[name] {
+			return NamedType(typeNameStruct)
+		}
+		if fnDef := ti.a.definitionNode(ti.doc, fn, name); fnDef != nil {
+			if fnDef.Type() != query.NodeTypeFunctionDef {
+				return AnyType
+			}
+			return ti.functionReturnType(fnDef)
+		}
+		if t, ok := ti.builtinCallType(name, args); ok {
+			return t
+		}
+	}
+
+	// functions from other documents and builtin modules
+	sig, found := ti.a.signatureInformation(ti.doc, call, callWithArguments{fnName: ti.doc.Content(fn), argsNode: args})
+	if found {
+		if t := ti.a.builtins.ResolveType(sig.ReturnTypeExpr()); !t.IsUnknown() {
+			return t
+		}
+	}
+	return AnyType
+}
+
+// builtinCallType determines the result type of calls to builtin functions
+// whose result depends on their arguments.
+func (ti *typeInference) builtinCallType(name string, args *sitter.Node) (Type, bool) {
+	var first *sitter.Node
+	if args != nil && args.NamedChildCount() > 0 && args.NamedChild(0).Type() != query.NodeTypeKeywordArgument {
+		first = args.NamedChild(0)
+	}
+	switch name {
+	case "list", "sorted", "reversed":
+		if first != nil {
+			return NamedType(typeNameList, elementType(ti.exprType(first))), true
+		}
+	case "tuple":
+		if first != nil {
+			return NamedType(typeNameTuple), true
+		}
+	case "set":
+		if first != nil {
+			return NamedType(typeNameSet, elementType(ti.exprType(first))), true
+		}
+		return NamedType(typeNameSet), true
+	case "enumerate":
+		if first != nil {
+			return NamedType(typeNameList, NamedType(typeNameTuple, IntType, elementType(ti.exprType(first)))), true
+		}
+	case "min", "max":
+		if first != nil && args.NamedChildCount() == 1 {
+			return elementType(ti.exprType(first)), true
+		}
+	case "abs":
+		if first != nil {
+			return ti.exprType(first), true
+		}
+	}
+	return Type{}, false
+}
+
+// functionReturnType determines the return type of a function defined in the
+// document from its annotation or its return statements.
+func (ti *typeInference) functionReturnType(fnDef *sitter.Node) Type {
+	if hint := fnDef.ChildByFieldName("return_type"); hint != nil {
+		return ti.annotationType(hint)
+	}
+	key := keyOf(fnDef)
+	if t, ok := ti.returnTypes[key]; ok {
+		return t
+	}
+	if index, ok := ti.inferring[key]; ok {
+		if index < ti.dependsOn {
+			ti.dependsOn = index
+		}
+		return Type{}
+	}
+
+	index := len(ti.inferring)
+	ti.inferring[key] = index
+	dependsOn := ti.dependsOn
+	ti.dependsOn = noDependency
+	t := ti.inferReturnType(fnDef)
+	if t.IsUnknown() {
+		// it only returns the results of recursive calls
+		t = AnyType
+	}
+	delete(ti.inferring, key)
+	if ti.dependsOn >= index {
+		// the return type only depends on the function itself
+		ti.dependsOn = noDependency
+		ti.returnTypes[key] = t
+	}
+	if dependsOn < ti.dependsOn {
+		ti.dependsOn = dependsOn
+	}
+	return t
+}
+
+// inferReturnType infers the return type of a function from its return
+// statements.
+func (ti *typeInference) inferReturnType(fnDef *sitter.Node) Type {
+	var t Type
+	returnsValue := false
+	query.Query(fnDef.ChildByFieldName(query.FieldBody), `(return_statement) @return`, func(q *sitter.Query, match *sitter.QueryMatch) bool {
+		for _, c := range match.Captures {
+			if enclosingFunction(c.Node) == nil || !enclosingFunction(c.Node).Equal(fnDef) {
+				continue
+			}
+			if value := c.Node.NamedChild(0); value != nil {
+				t = t.Join(ti.exprType(value))
+				returnsValue = true
+			} else {
+				t = t.Join(NoneType)
+			}
+		}
+		return true
+	})
+	if !returnsValue {
+		// falling off the end of a function returns None
+		return NoneType
+	}
+	if !ti.returnsAtEnd(fnDef.ChildByFieldName(query.FieldBody)) {
+		t = t.Join(NoneType)
+	}
+	return t
+}
+
+// returnsAtEnd reports whether the last statement of the block always
+// returns or fails.
+func (ti *typeInference) returnsAtEnd(block *sitter.Node) bool {
+	last := lastNamedChild(block)
+	for last != nil && last.Type() == query.NodeTypeComment {
+		last = last.PrevNamedSibling()
+	}
+	if last == nil {
+		return false
+	}
+	switch last.Type() {
+	case "return_statement":
+		return true
+	case query.NodeTypeExpressionStatement:
+		// `fail()` never returns
+		call := last.NamedChild(0)
+		return call != nil && call.Type() == query.NodeTypeCall &&
+			ti.doc.Content(call.ChildByFieldName("function")) == "fail"
+	case query.NodeTypeIfStatement:
+		if !ti.returnsAtEnd(last.ChildByFieldName("consequence")) {
+			return false
+		}
+		hasElse := false
+		for i := 0; i < int(last.NamedChildCount()); i++ {
+			switch clause := last.NamedChild(i); clause.Type() {
+			case "elif_clause":
+				if !ti.returnsAtEnd(clause.ChildByFieldName("consequence")) {
+					return false
+				}
+			case "else_clause":
+				hasElse = true
+				if !ti.returnsAtEnd(clause.ChildByFieldName(query.FieldBody)) {
+					return false
+				}
+			}
+		}
+		return hasElse
+	}
+	return false
+}
+
+func enclosingFunction(node *sitter.Node) *sitter.Node {
+	for n := node.Parent(); n != nil; n = n.Parent() {
+		if n.Type() == query.NodeTypeFunctionDef {
+			return n
+		}
+	}
+	return nil
+}
+
+// methodCallType determines the result type of calling a method of a builtin
+// type, taking the element types of lists and dicts into account.
+func (ti *typeInference) methodCallType(recv Type, method string, args *sitter.Node) Type {
+	var t Type
+	for _, alt := range recv.Alternatives() {
+		switch {
+		case alt.Name == typeNameDict && (method == "get" || method == "setdefault"):
+			// without a default value, the result is None for missing keys
+			result := alt.Arg(1)
+			if args != nil && args.NamedChildCount() > 1 {
+				result = result.Join(ti.exprType(args.NamedChild(1)))
+			} else if method == "get" {
+				result = result.Join(NoneType)
+			}
+			t = t.Join(result)
+		case alt.Name == typeNameDict && method == "pop":
+			t = t.Join(alt.Arg(1))
+		case alt.Name == typeNameDict && method == "keys":
+			t = t.Join(NamedType(typeNameList, alt.Arg(0)))
+		case alt.Name == typeNameDict && method == "values":
+			t = t.Join(NamedType(typeNameList, alt.Arg(1)))
+		case alt.Name == typeNameDict && method == "items":
+			t = t.Join(NamedType(typeNameList, NamedType(typeNameTuple, alt.Arg(0), alt.Arg(1))))
+		case alt.Name == typeNameDict && method == "popitem":
+			t = t.Join(NamedType(typeNameTuple, alt.Arg(0), alt.Arg(1)))
+		case alt.Name == typeNameList && method == "pop":
+			t = t.Join(alt.Arg(0))
+		default:
+			class, found := ti.a.builtins.Types[alt.Name]
+			if !found {
+				continue
+			}
+			if sig, found := class.FindMethod(method); found {
+				rt := ti.a.builtins.ResolveType(sig.ReturnTypeExpr())
+				if rt.IsUnknown() {
+					rt = AnyType
+				}
+				t = t.Join(rt)
+			}
+		}
+	}
+	return t
+}
+
+// elementType determines the type of the elements when iterating over a
+// value of the given type.
+func elementType(t Type) Type {
+	var elem Type
+	for _, alt := range t.Alternatives() {
+		switch alt.Name {
+		case typeNameList, typeNameSet, typeNameDict:
+			elem = elem.Join(alt.Arg(0))
+		case typeNameTuple:
+			if len(alt.Args) == 0 {
+				return AnyType
+			}
+			elem = elem.Join(UnionType(alt.Args...))
+		default:
+			return AnyType
+		}
+	}
+	if elem.IsUnknown() {
+		return AnyType
+	}
+	return elem
+}
+
+// binaryOperatorType determines the result type of a binary operator for all
+// combinations of the types of its operands.
+func binaryOperatorType(op string, left, right Type) Type {
+	if op == "%" && left.Equal(StringType) {
+		// string formatting works with any value
+		return StringType
+	}
+	if left.IsUnknown() || right.IsUnknown() || left.IsAny() || right.IsAny() {
+		return AnyType
+	}
+	var t Type
+	for _, l := range left.Alternatives() {
+		for _, r := range right.Alternatives() {
+			result := binaryOperatorAlternativeType(op, l, r)
+			if result.IsUnknown() {
+				return AnyType
+			}
+			t = t.Join(result)
+		}
+	}
+	return t
+}
+
+func binaryOperatorAlternativeType(op string, l, r Type) Type {
+	numeric := func(t Type) bool {
+		return t.Name == typeNameInt || t.Name == typeNameFloat || t.Name == typeNameBool
+	}
+	switch {
+	case op == "%" && l.Name == typeNameString:
+		return StringType
+	case numeric(l) && numeric(r):
+		switch {
+		case op == "/":
+			return FloatType
+		case l.Name == typeNameFloat || r.Name == typeNameFloat:
+			return FloatType
+		}
+		return IntType
+	case op == "+" && l.Name == r.Name:
+		switch l.Name {
+		case typeNameString:
+			return StringType
+		case typeNameList:
+			return joinArgs(l, r)
+		case typeNameTuple:
+			return Type{Name: typeNameTuple, Args: append(append([]Type{}, l.Args...), r.Args...)}
+		}
+	case op == "*" && (l.Name == typeNameInt || r.Name == typeNameInt):
+		seq := l
+		if l.Name == typeNameInt {
+			seq = r
+		}
+		switch seq.Name {
+		case typeNameString, typeNameList, typeNameTuple:
+			if seq.Name == typeNameTuple {
+				return NamedType(typeNameTuple)
+			}
+			return seq
+		}
+	case op == "|" && l.Name == r.Name && (l.Name == typeNameDict || l.Name == typeNameSet):
+		return joinArgs(l, r)
+	case (op == "&" || op == "^" || op == "-") && l.Name == typeNameSet && r.Name == typeNameSet:
+		return joinArgs(l, r)
+	}
+	return Type{}
+}
+
+// symbolType determines the type of a symbol that isn't defined in the
+// document, e.g. a builtin or a symbol loaded from another document.
+func symbolType(sym query.Symbol) Type {
+	switch sym.Kind {
+	case protocol.SymbolKindString:
+		return StringType
+	case protocol.SymbolKindObject:
+		return NamedType(typeNameDict)
+	case protocol.SymbolKindArray:
+		return NamedType(typeNameList)
+	case protocol.SymbolKindBoolean:
+		return BoolType
+	case protocol.SymbolKindNumber:
+		return IntType
+	case protocol.SymbolKindNull:
+		return NoneType
+	case protocol.SymbolKindFunction, protocol.SymbolKindMethod:
+		return NamedType(typeNameFunction)
+	}
+	return AnyType
+}
+
+// nodeContains reports whether the inner node is (a descendant of) the outer
+// node.
+func nodeContains(outer, inner *sitter.Node) bool {
+	return outer.StartByte() <= inner.StartByte() && inner.EndByte() <= outer.EndByte()
+}
+
+func namedChildren(node *sitter.Node) []*sitter.Node {
+	children := make([]*sitter.Node, node.NamedChildCount())
+	for i := range children {
+		children[i] = node.NamedChild(i)
+	}
+	return children
+}
+
+func lastNamedChild(node *sitter.Node) *sitter.Node {
+	if node == nil || node.NamedChildCount() == 0 {
+		return nil
+	}
+	return node.NamedChild(int(node.NamedChildCount()) - 1)
+}
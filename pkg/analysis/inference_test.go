@@ -0,0 +1,326 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// inferLast infers the type of the expression in the last statement of the
+// document, which might be nested in a block.
+func (f *fixture) inferLast(src string) string {
+	doc := f.MainDoc(src)
+	last := doc.Tree().RootNode()
+	for last != nil && last.Type() != "expression_statement" {
+		last = lastNamedChild(last)
+	}
+	require.NotNil(f.t, last)
+	return f.a.InferType(doc, last.NamedChild(0)).String()
+}
+
+func TestInferTypeLiterals(t *testing.T) {
+	for _, tc := range []struct {
+		src, expected string
+	}{
+		{`"a"`, "String"},
+		{`"a" "b"`, "String"},
+		{`1`, "int"},
+		{`1.5`, "float"},
+		{`True`, "bool"},
+		{`None`, "None"},
+		{`[]`, "List"},
+		{`["a", "b"]`, "List[String]"},
+		{`["a", 1]`, "List[String | int]"},
+		{`{"a": 1}`, "Dict[String, int]"},
+		{`(1, "a")`, "Tuple[int, String]"},
+		{`{1, 2}`, "Set[int]"},
+		{`[str(x) for x in range(3)]`, "List[String]"},
+		{`{k: 1 for k in ["a"]}`, "Dict[String, int]"},
+		{`lambda x: x`, "function"},
+		{`not x`, "bool"},
+		{`1 < 2`, "bool"},
+		{`"a" if x else 1`, "String | int"},
+		{`undefined_thing`, "Any"},
+	} {
+		t.Run(tc.src, func(t *testing.T) {
+			f := newFixture(t)
+			f.ParseBuiltins(`
+def range() -> List[int]:
+  pass
+def str(x) -> String:
+  pass
+`)
+			assert.Equal(t, tc.expected, f.inferLast(tc.src))
+		})
+	}
+}
+
+func TestInferTypeOperators(t *testing.T) {
+	for _, tc := range []struct {
+		src, expected string
+	}{
+		{`"a" + "b"`, "String"},
+		{`1 + 2`, "int"},
+		{`1 + 2.0`, "float"},
+		{`1 / 2`, "float"},
+		{`"%s" % x`, "String"},
+		{`"-" * 3`, "String"},
+		{`[1] + ["a"]`, "List[String | int]"},
+		{`[1] * 3`, "List[int]"},
+		{`{"a": 1} | {"b": 2}`, "Dict[String, int]"},
+		{`"a" + 1`, "Any"},
+		{`x + 1`, "Any"},
+		{`x or "default"`, "Any"},
+		{`None or "default"`, "None | String"},
+	} {
+		t.Run(tc.src, func(t *testing.T) {
+			f := newFixture(t)
+			assert.Equal(t, tc.expected, f.inferLast(tc.src))
+		})
+	}
+}
+
+func TestInferTypeFlowSensitive(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, expected string
+	}{
+		{"last assignment wins", `
+x = "a"
+x = 1
+x`, "int"},
+		{"use before reassignment", `
+x = "a"
+y = x
+x = 1
+y`, "String"},
+		{"if without else", `
+x = "a"
+if c:
+  x = 1
+x`, "String | int"},
+		{"if with else", `
+x = None
+if c:
+  x = 1
+elif d:
+  x = 2.0
+else:
+  x = "a"
+x`, "String | float | int"},
+		{"inside branch", `
+x = None
+if c:
+  x = "a"
+  x
+`, "String"},
+		{"loop might not run", `
+x = None
+for i in range(3):
+  x = i
+x`, "None | int"},
+		{"loop variable", `
+for name in ["a", "b"]:
+  name
+`, "String"},
+		{"loop unpacking", `
+for i, name in enumerate(["a"]):
+  name
+`, "String"},
+		{"dict items", `
+for k, v in {"a": 1}.items():
+  v
+`, "int"},
+		{"tuple unpacking", `
+a, b = 1, "x"
+b`, "String"},
+		{"chained assignment", `
+a = b = [1]
+a`, "List[int]"},
+		{"augmented assignment", `
+x = []
+x += ["a"]
+x`, "List"},
+		{"comprehension variable", `
+[n for n in ["a"]]
+`, "List[String]"},
+		{"parameter default", `
+def f(name="x", count=None, *args, **kwargs):
+  name
+`, "String"},
+		{"parameter None default", `
+def f(count=None):
+  count
+`, "Any"},
+		{"parameter annotation", `
+def f(names: List[str]):
+  names
+`, "List[String]"},
+		{"kwargs", `
+def f(**kwargs):
+  kwargs
+`, "Dict[String, Any]"},
+		{"module variable in function", `
+x = "a"
+def f():
+  x
+`, "String"},
+		{"local shadows module variable", `
+x = "a"
+def f():
+  x = 1
+  x
+`, "int"},
+		{"try", `
+x = None
+try:
+  x = 1
+except E:
+  x = "a"
+x`, "None | String | int"},
+		{"function", `
+def f():
+  pass
+f`, "function"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.ParseBuiltins(`
+def range() -> List[int]:
+  pass
+def enumerate(x) -> List[Tuple[int, any]]:
+  pass
+`)
+			assert.Equal(t, tc.expected, f.inferLast(tc.src))
+		})
+	}
+}
+
+func TestInferTypeCalls(t *testing.T) {
+	for _, tc := range []struct {
+		name, src, expected string
+	}{
+		{"builtin return type", `len(x)`, "int"},
+		{"builtin generic return type", `dir(x)`, "List[String]"},
+		{"builtin module function", `os.getcwd()`, "String"},
+		{"method", `"a".upper()`, "String"},
+		{"method chain", `"a b".upper().split()`, "List[String]"},
+		{"list element", `"a b".split()[0]`, "String"},
+		{"list slice", `"a b".split()[1:]`, "List[String]"},
+		{"tuple element", `(1, "a")[1]`, "String"},
+		{"dict value", `{"a": 1}["a"]`, "int"},
+		{"dict get", `{"a": 1}.get("a")`, "None | int"},
+		{"dict get with default", `{"a": 1}.get("a", "x")`, "String | int"},
+		{"dict keys", `{"a": 1}.keys()`, "List[String]"},
+		{"list pop", `["a"].pop()`, "String"},
+		{"sorted", `sorted(["a"])`, "List[String]"},
+		{"struct", `struct(a=1)`, "struct"},
+		{"struct field", `struct(a=1).a`, "int"},
+		{"user function", `
+def f():
+  return "a"
+f()`, "String"},
+		{"user function with multiple returns", `
+def f(x):
+  if x:
+    return "a"
+  return None
+f(1)`, "None | String"},
+		{"user function falling off the end", `
+def f(x):
+  if x:
+    return "a"
+f(1)`, "None | String"},
+		{"user function with fail", `
+def f(x):
+  if x:
+    return "a"
+  fail("no")
+f(1)`, "String"},
+		{"user function without return", `
+def f():
+  pass
+f()`, "None"},
+		{"user function return annotation", `
+def f() -> List[str]:
+  pass
+f()`, "List[String]"},
+		{"recursive function", `
+def f(x):
+  return f(x)
+f(1)`, "Any"},
+		{"recursive function with base case", `
+def f(x):
+  if x:
+    return 1
+  return f(x)
+f(1)`, "int"},
+		{"mutually recursive functions", `
+def f(x):
+  if x:
+    return g(x)
+  return "a"
+def g(x):
+  return f(x)
+g(1)`, "String"},
+		{"unknown function", `g()`, "Any"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.ParseBuiltins(`
+def len(x) -> int:
+  pass
+def dir(x) -> List[String]:
+  pass
+def sorted(x) -> List:
+  pass
+def struct(**kwargs):
+  pass
+def fail(msg):
+  pass
+class Dict:
+  def get(self, key):
+    pass
+  def keys(self) -> List:
+    pass
+class List:
+  def pop(self):
+    pass
+class String:
+  def upper(self) -> String:
+    pass
+  def split(self) -> List[String]:
+    pass
+`)
+			f.builtins.Functions["os.getcwd"] = query.Signature{Name: "getcwd", ReturnType: "str"}
+			assert.Equal(t, tc.expected, f.inferLast(tc.src))
+		})
+	}
+}
+
+func TestInferTypeSelfRecursiveCalls(t *testing.T) {
+	f := newFixture(t)
+	// without caching, inferring each call would infer every call in the
+	// function again, which takes exponential time
+	doc := f.MainDoc(`
+def f():
+    return [f(), f(), f(), f()]
+
+x = f()
+x
+`)
+	done := make(chan Type, 1)
+	go func() {
+		last := lastNamedChild(doc.Tree().RootNode())
+		done <- f.a.InferType(doc, last.NamedChild(0))
+	}()
+	select {
+	case typ := <-done:
+		assert.Equal(t, "List", typ.String())
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Timed out inferring the type of a recursive function")
+	}
+}
@@ -0,0 +1,199 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/docstring"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// hoverMarkupKind picks the format of hover contents from the formats the
+// client supports, in the order of its preference. Markdown is used if the
+// client didn't say.
+func hoverMarkupKind(ctx context.Context) protocol.MarkupKind {
+	caps := ClientCapabilitiesFromContext(ctx)
+	if caps.TextDocument == nil || caps.TextDocument.Hover == nil {
+		return protocol.Markdown
+	}
+	for _, kind := range caps.TextDocument.Hover.ContentFormat {
+		if kind == protocol.Markdown || kind == protocol.PlainText {
+			return kind
+		}
+	}
+	return protocol.Markdown
+}
+
+// markup builds documentation out of sections, either as Markdown or as plain
+// text for clients that can't render Markdown.
+type markup struct {
+	kind     protocol.MarkupKind
+	sections []string
+}
+
+func newMarkup(kind protocol.MarkupKind) *markup {
+	return &markup{kind: kind}
+}
+
+func (m *markup) markdown() bool {
+	return m.kind == protocol.Markdown
+}
+
+// Text adds a paragraph of text. Empty text is ignored.
+func (m *markup) Text(s string) {
+	if s = strings.TrimSpace(s); s != "" {
+		m.sections = append(m.sections, s)
+	}
+}
+
+// Code adds a block of Python code.
+func (m *markup) Code(s string) {
+	if m.markdown() {
+		s = fmt.Sprintf("```python\n%s\n```", s)
+	}
+	m.sections = append(m.sections, s)
+}
+
+// InlineCode formats a name or a path to be used within text.
+func (m *markup) InlineCode(s string) string {
+	if m.markdown() {
+		return "`" + s + "`"
+	}
+	return s
+}
+
+// Section adds a titled section, e.g. "Returns". Empty sections are ignored.
+func (m *markup) Section(title, body string) {
+	if body = strings.TrimSpace(body); body == "" {
+		return
+	}
+	if m.markdown() {
+		m.sections = append(m.sections, fmt.Sprintf("**%s**\n\n%s", title, body))
+	} else {
+		m.sections = append(m.sections, fmt.Sprintf("%s:\n%s", title, indent(body)))
+	}
+}
+
+func (m *markup) Content() protocol.MarkupContent {
+	return protocol.MarkupContent{
+		Kind:  m.kind,
+		Value: strings.Join(m.sections, "\n\n"),
+	}
+}
+
+// Signature adds the full documentation of a function: its signature, the
+// module it belongs to if any, and the sections of its docstring.
+func (m *markup) Signature(sig query.Signature, label string) {
+	module, name := "", sig.Name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		module, name = name[:i], name[i+1:]
+	}
+	m.Code(fmt.Sprintf("def %s%s", name, label))
+	if module != "" {
+		m.Text(fmt.Sprintf("Function of the %s module", m.InlineCode(module)))
+	}
+	m.Documentation(sig.Documentation())
+}
+
+// Documentation adds the sections of the documentation of a function: the
+// description, a table of parameters, the return value and the other
+// sections of the docstring, with examples as code.
+func (m *markup) Documentation(d query.Documentation) {
+	m.Text(d.Description)
+	m.Parameters(d.Params)
+	m.Section("Returns", d.Returns)
+	for _, section := range d.Sections {
+		switch {
+		case len(section.Fields) > 0:
+			lines := make([]string, len(section.Fields))
+			for i, f := range section.Fields {
+				lines[i] = fmt.Sprintf("%s: %s", m.InlineCode(f.Name), f.Desc)
+				if m.markdown() {
+					lines[i] = "- " + lines[i]
+				}
+			}
+			m.Section(section.Title, strings.Join(lines, "\n"))
+		case section.Code && m.markdown():
+			m.Section(section.Title, fmt.Sprintf("```python\n%s\n```", section.Body))
+		default:
+			m.Section(section.Title, section.Body)
+		}
+	}
+}
+
+// Parameter adds the declaration of a parameter, with its type hint and
+// default value, and its description from the docstring of the function. If
+// the parameter has no type hint, the inferred type is shown instead.
+func (m *markup) Parameter(p query.Parameter, t Type, docs docstring.Parsed) {
+	decl := p.Content
+	if p.TypeHint == "" && !isVariadicParam(p) && !t.IsUnknown() && !t.IsAny() {
+		decl = fmt.Sprintf("%s: %s", p.Name, t)
+		if p.DefaultValue != "" {
+			decl += " = " + p.DefaultValue
+		}
+	}
+	m.Code(decl)
+	for _, arg := range docs.Args() {
+		if arg.Name == p.Name {
+			m.Text(arg.Desc)
+		}
+	}
+}
+
+// Parameters adds a table of the parameters of a function, with their type
+// hints, default values and descriptions.
+func (m *markup) Parameters(params []query.ParameterDoc) {
+	if len(params) == 0 {
+		return
+	}
+
+	var sb strings.Builder
+	if m.markdown() {
+		sb.WriteString("| Parameter | Type | Default | Description |\n| --- | --- | --- | --- |")
+		for _, p := range params {
+			sb.WriteString(fmt.Sprintf("\n| %s | %s | %s | %s |",
+				tableCode(p.Name), tableCode(p.TypeHint), tableCode(p.DefaultValue), tableCell(p.Desc)))
+		}
+		m.sections = append(m.sections, fmt.Sprintf("**Parameters**\n\n%s", sb.String()))
+		return
+	}
+	for i, p := range params {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(p.Name)
+		var attrs []string
+		if p.TypeHint != "" {
+			attrs = append(attrs, p.TypeHint)
+		}
+		if p.DefaultValue != "" {
+			attrs = append(attrs, "default "+p.DefaultValue)
+		}
+		if len(attrs) > 0 {
+			sb.WriteString(" (" + strings.Join(attrs, ", ") + ")")
+		}
+		if p.Desc != "" {
+			sb.WriteString(": " + p.Desc)
+		}
+	}
+	m.Section("Parameters", sb.String())
+}
+
+func tableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+func tableCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + tableCell(s) + "`"
+}
+
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(s, "\n", "\n  ")
+}
@@ -0,0 +1,157 @@
+package analysis
+
+import (
+	"embed"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"go.lsp.dev/uri"
+)
+
+//go:embed profiles/*/*/*.py
+var profilesFS embed.FS
+
+// Profile selects the builtins of documents by their file names or language
+// IDs, e.g. to analyze Bazel BUILD files with the functions of the build
+// language instead of the builtins of the Tiltfile API.
+//
+// The documents of a profile get the builtins of the Starlark language (if
+// enabled with WithStarlarkBuiltins) and the builtins of the profile, but
+// not the builtins added with other options.
+type Profile struct {
+	Name string
+	// Patterns match the base name of documents, using the syntax of
+	// filepath.Match, e.g. "*.bzl"
+	Patterns []string
+	// LanguageIDs match the language the editor assigned to the document,
+	// e.g. "bazel"
+	LanguageIDs []string
+	Builtins    *Builtins
+}
+
+func (p Profile) matchesFile(name string) bool {
+	for _, pattern := range p.Patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p Profile) matchesLanguage(languageID string) bool {
+	for _, id := range p.LanguageIDs {
+		if id == languageID {
+			return true
+		}
+	}
+	return false
+}
+
+// WithProfiles adds builtin profiles. When several profiles match a
+// document, the first one added wins. File name patterns take precedence
+// over language IDs, since editors commonly use the same language ID for
+// all Starlark dialects.
+func WithProfiles(profiles ...Profile) AnalyzerOption {
+	return func(analyzer *Analyzer) error {
+		analyzer.profiles = append(analyzer.profiles, profiles...)
+		return nil
+	}
+}
+
+// WithBazelProfiles adds profiles for the BUILD and WORKSPACE files of Bazel
+// and for .bzl extensions, which have different predeclared functions. They
+// only match by file name: editors use the same language ID for both, and
+// often for other Starlark files too.
+func WithBazelProfiles() AnalyzerOption {
+	return func(analyzer *Analyzer) error {
+		build, err := loadProfileBuiltins(analyzer, "profiles/bazel/build")
+		if err != nil {
+			return err
+		}
+		bzl, err := loadProfileBuiltins(analyzer, "profiles/bazel/bzl")
+		if err != nil {
+			return err
+		}
+		return WithProfiles(
+			Profile{
+				Name:     "bazel-build",
+				Patterns: []string{"BUILD", "BUILD.bazel", "*.BUILD", "WORKSPACE", "WORKSPACE.bazel"},
+				Builtins: build,
+			},
+			Profile{
+				Name:     "bazel-bzl",
+				Patterns: []string{"*.bzl"},
+				Builtins: bzl,
+			},
+		)(analyzer)
+	}
+}
+
+func loadProfileBuiltins(analyzer *Analyzer, dir string) (*Builtins, error) {
+	f, err := fs.Sub(profilesFS, dir)
+	if err != nil {
+		return nil, err
+	}
+	builtins, err := LoadBuiltinsFromFS(analyzer.context, f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading builtins for profile %s", dir)
+	}
+	return builtins, nil
+}
+
+// resolveProfiles merges the Starlark builtins into the builtins of each
+// profile. This is done once all options have been applied, so that the
+// order of WithStarlarkBuiltins and WithProfiles doesn't matter.
+func (a *Analyzer) resolveProfiles() {
+	a.profileBuiltins = make([]*Builtins, len(a.profiles))
+	for i, p := range a.profiles {
+		builtins := NewBuiltins()
+		if a.starlarkBuiltins != nil {
+			builtins.Update(a.starlarkBuiltins)
+		}
+		if p.Builtins != nil {
+			builtins.Update(p.Builtins)
+		}
+		a.profileBuiltins[i] = builtins
+	}
+}
+
+// ForDocument returns the analyzer for a document, using the builtins of the
+// profile that matches its file name or language ID. Documents that aren't
+// files, like unsaved buffers, are only matched by language ID. If no profile
+// matches, the analyzer itself is returned.
+func (a *Analyzer) ForDocument(u uri.URI, languageID string) *Analyzer {
+	if len(a.profiles) == 0 {
+		return a
+	}
+	if path, err := uriFilename(u); err == nil {
+		name := filepath.Base(path)
+		for i, p := range a.profiles {
+			if p.matchesFile(name) {
+				return a.withProfile(i)
+			}
+		}
+	}
+	if languageID != "" {
+		for i, p := range a.profiles {
+			if p.matchesLanguage(languageID) {
+				return a.withProfile(i)
+			}
+		}
+	}
+	return a
+}
+
+// Profile returns the name of the profile the analyzer was selected for by
+// ForDocument, or an empty string for the default builtins.
+func (a *Analyzer) Profile() string {
+	return a.profile
+}
+
+func (a *Analyzer) withProfile(i int) *Analyzer {
+	copied := *a
+	copied.builtins = a.profileBuiltins[i]
+	copied.profile = a.profiles[i].Name
+	return &copied
+}
@@ -0,0 +1,156 @@
+# Functions predeclared in Bazel BUILD files.
+
+def glob(include, exclude = [], exclude_directories = 1, allow_empty = True) -> List[String]:
+    """Returns a list of the files in the package that match the patterns.
+
+    Args:
+      include: glob patterns of the files to include
+      exclude: glob patterns of the files to exclude
+      exclude_directories: whether to exclude directories
+      allow_empty: whether it's an error if no files match
+    """
+    pass
+
+def package(**kwargs) -> None:
+    """Declares metadata that applies to every rule in the package.
+
+    Args:
+      default_visibility: the default visibility of the rules in the package
+      default_testonly: the default value of `testonly` for the rules in the package
+      features: features that apply to all rules in the package
+    """
+    pass
+
+def package_name() -> String:
+    """Returns the name of the package being evaluated."""
+    pass
+
+def repository_name() -> String:
+    """Returns the name of the repository the package is in."""
+    pass
+
+def exports_files(srcs, visibility = None, licenses = None) -> None:
+    """Makes files of the package available to other packages.
+
+    Args:
+      srcs: the files to export
+      visibility: the packages the files are visible to
+      licenses: the licenses of the files
+    """
+    pass
+
+def licenses(license_types) -> None:
+    """Declares the licenses of the rules in the package.
+
+    Args:
+      license_types: the license types, e.g. "notice"
+    """
+    pass
+
+def select(x: Dict, no_match_error = "") -> Any:
+    """Selects a value depending on the configuration.
+
+    Args:
+      x: maps labels of config_setting targets to values
+      no_match_error: error to report if no condition matches
+    """
+    pass
+
+def existing_rule(name) -> Dict:
+    """Returns the attributes of a rule instantiated in the package.
+
+    Args:
+      name: the name of the target
+    """
+    pass
+
+def existing_rules() -> Dict:
+    """Returns the attributes of all rules instantiated in the package so far."""
+    pass
+
+def filegroup(name, srcs = [], data = [], output_group = "", **kwargs) -> None:
+    """Gives a convenient name to a collection of targets.
+
+    Args:
+      name: the name of the target
+      srcs: the targets in the group
+      data: files needed at runtime
+      output_group: the output group of the sources to include
+    """
+    pass
+
+def genrule(name, outs, cmd = "", srcs = [], tools = [], **kwargs) -> None:
+    """Generates files using a shell command.
+
+    Args:
+      name: the name of the target
+      outs: the files generated by the command
+      cmd: the command to run
+      srcs: the inputs of the command
+      tools: tools the command uses
+    """
+    pass
+
+def alias(name, actual, **kwargs) -> None:
+    """Declares another name for a target.
+
+    Args:
+      name: the name of the target
+      actual: the target the alias refers to
+    """
+    pass
+
+def config_setting(name, values = {}, define_values = {}, flag_values = {}, constraint_values = [], **kwargs) -> None:
+    """Matches a configuration, for use in select().
+
+    Args:
+      name: the name of the target
+      values: build settings and their expected values
+      define_values: `--define` flags and their expected values
+      flag_values: user-defined flags and their expected values
+      constraint_values: constraint values the platform must have
+    """
+    pass
+
+def test_suite(name, tests = [], tags = [], **kwargs) -> None:
+    """Defines a set of tests.
+
+    Args:
+      name: the name of the target
+      tests: the tests in the suite
+      tags: only include tests with these tags
+    """
+    pass
+
+def sh_binary(name, srcs = [], deps = [], data = [], **kwargs) -> None:
+    """Declares an executable shell script.
+
+    Args:
+      name: the name of the target
+      srcs: the script to run
+      deps: libraries the script uses
+      data: files needed at runtime
+    """
+    pass
+
+def sh_library(name, srcs = [], deps = [], data = [], **kwargs) -> None:
+    """Declares a library of shell scripts.
+
+    Args:
+      name: the name of the target
+      srcs: the scripts of the library
+      deps: other libraries the scripts use
+      data: files needed at runtime
+    """
+    pass
+
+def sh_test(name, srcs = [], deps = [], data = [], **kwargs) -> None:
+    """Declares a test written as a shell script.
+
+    Args:
+      name: the name of the target
+      srcs: the script to run
+      deps: libraries the script uses
+      data: files needed at runtime
+    """
+    pass
@@ -0,0 +1,106 @@
+# Functions predeclared in Bazel .bzl files.
+
+def rule(implementation, attrs = {}, outputs = None, executable = False, test = False, toolchains = [], provides = [], doc = "", **kwargs) -> function:
+    """Creates a new rule that can be called from BUILD files.
+
+    Args:
+      implementation: the function implementing the rule
+      attrs: the attributes of the rule, created with the attr module
+      outputs: predeclared outputs of the rule
+      executable: whether the rule creates an executable
+      test: whether the rule is a test rule
+      toolchains: the toolchains the rule requires
+      provides: the providers the implementation returns
+      doc: description of the rule
+    """
+    pass
+
+def aspect(implementation, attr_aspects = [], attrs = {}, required_providers = [], provides = [], doc = "", **kwargs) -> Any:
+    """Creates a new aspect.
+
+    Args:
+      implementation: the function implementing the aspect
+      attr_aspects: the attributes the aspect propagates along
+      attrs: the attributes of the aspect
+      required_providers: providers targets must have for the aspect to apply
+      provides: the providers the implementation returns
+      doc: description of the aspect
+    """
+    pass
+
+def provider(doc = "", fields = None, init = None) -> function:
+    """Defines a provider symbol.
+
+    Args:
+      doc: description of the provider
+      fields: the fields of the provider, with their descriptions
+      init: callback to preprocess the fields
+    """
+    pass
+
+def repository_rule(implementation, attrs = {}, local = False, environ = [], configure = False, doc = "") -> function:
+    """Creates a new repository rule for WORKSPACE files.
+
+    Args:
+      implementation: the function implementing the rule
+      attrs: the attributes of the rule
+      local: whether the repository is fetched from the local system
+      environ: environment variables the rule depends on
+      configure: whether the rule inspects the system for configuration
+      doc: description of the rule
+    """
+    pass
+
+def depset(direct = None, order = "default", transitive = None) -> Any:
+    """Creates a set that is efficient to merge.
+
+    Args:
+      direct: the direct elements of the set
+      order: the traversal order, e.g. "postorder"
+      transitive: depsets whose elements are added to the set
+    """
+    pass
+
+def select(x: Dict, no_match_error = "") -> Any:
+    """Selects a value depending on the configuration.
+
+    Args:
+      x: maps labels of config_setting targets to values
+      no_match_error: error to report if no condition matches
+    """
+    pass
+
+def struct(**kwargs) -> struct:
+    """Creates an immutable value with the given fields."""
+    pass
+
+def Label(label_string) -> Any:
+    """Creates a label referring to a target.
+
+    Args:
+      label_string: the label, e.g. "//pkg:target"
+    """
+    pass
+
+def DefaultInfo(files = None, runfiles = None, executable = None) -> Any:
+    """The provider for the default outputs of a target.
+
+    Args:
+      files: the default outputs of the target
+      runfiles: files needed when running the target
+      executable: the executable of the target
+    """
+    pass
+
+def OutputGroupInfo(**kwargs) -> Any:
+    """The provider for the output groups of a target."""
+    pass
+
+def RunEnvironmentInfo(environment = {}, inherited_environment = []) -> Any:
+    """The provider for the environment of executable targets.
+
+    Args:
+      environment: environment variables to set
+      inherited_environment: environment variables to inherit from the shell
+    """
+    pass
@@ -0,0 +1,49 @@
+# Constructors for the attributes of rules.
+
+def bool(default = False, doc = "", mandatory = False) -> Any:
+    """Creates a boolean attribute."""
+    pass
+
+def int(default = 0, doc = "", mandatory = False, values = []) -> Any:
+    """Creates an integer attribute."""
+    pass
+
+def int_list(default = [], doc = "", mandatory = False, allow_empty = True) -> Any:
+    """Creates a list of integers attribute."""
+    pass
+
+def string(default = "", doc = "", mandatory = False, values = []) -> Any:
+    """Creates a string attribute."""
+    pass
+
+def string_list(default = [], doc = "", mandatory = False, allow_empty = True) -> Any:
+    """Creates a list of strings attribute."""
+    pass
+
+def string_dict(default = {}, doc = "", mandatory = False, allow_empty = True) -> Any:
+    """Creates a dictionary attribute with string keys and values."""
+    pass
+
+def string_list_dict(default = {}, doc = "", mandatory = False, allow_empty = True) -> Any:
+    """Creates a dictionary attribute with string keys and lists of strings as values."""
+    pass
+
+def label(default = None, doc = "", executable = False, allow_files = None, allow_single_file = None, mandatory = False, providers = [], cfg = None, aspects = []) -> Any:
+    """Creates a label attribute referring to a single target."""
+    pass
+
+def label_list(default = [], doc = "", allow_files = None, allow_empty = True, mandatory = False, providers = [], cfg = None, aspects = []) -> Any:
+    """Creates a list of labels attribute."""
+    pass
+
+def label_keyed_string_dict(default = {}, doc = "", allow_files = None, allow_empty = True, mandatory = False, providers = [], cfg = None, aspects = []) -> Any:
+    """Creates a dictionary attribute with labels as keys and strings as values."""
+    pass
+
+def output(doc = "", mandatory = False) -> Any:
+    """Creates an attribute for a predeclared output file."""
+    pass
+
+def output_list(doc = "", mandatory = False, allow_empty = True) -> Any:
+    """Creates an attribute for a list of predeclared output files."""
+    pass
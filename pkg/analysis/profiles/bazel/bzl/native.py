@@ -0,0 +1,33 @@
+# Functions of BUILD files that are available to macros.
+
+def glob(include, exclude = [], exclude_directories = 1, allow_empty = True) -> List[String]:
+    """Returns a list of the files in the package that match the patterns."""
+    pass
+
+def package_name() -> String:
+    """Returns the name of the package being evaluated."""
+    pass
+
+def repository_name() -> String:
+    """Returns the name of the repository the package is in."""
+    pass
+
+def existing_rule(name) -> Dict:
+    """Returns the attributes of a rule instantiated in the package."""
+    pass
+
+def existing_rules() -> Dict:
+    """Returns the attributes of all rules instantiated in the package so far."""
+    pass
+
+def exports_files(srcs, visibility = None, licenses = None) -> None:
+    """Makes files of the package available to other packages."""
+    pass
+
+def filegroup(name, srcs = [], data = [], output_group = "", **kwargs) -> None:
+    """Gives a convenient name to a collection of targets."""
+    pass
+
+def genrule(name, outs, cmd = "", srcs = [], tools = [], **kwargs) -> None:
+    """Generates files using a shell command."""
+    pass
@@ -0,0 +1,96 @@
+package analysis
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func newProfilesAnalyzer(f *fixture, opts ...AnalyzerOption) *Analyzer {
+	tiltBuiltins := fstest.MapFS{
+		"__init__.py": {Data: []byte("def docker_build(ref: str, context: str) -> None:\n  pass\n")},
+	}
+	opts = append([]AnalyzerOption{WithStarlarkBuiltins(), WithBuiltins(tiltBuiltins)}, opts...)
+	a, err := NewAnalyzer(f.ctx, opts...)
+	require.NoError(f.t, err)
+	return a
+}
+
+func TestBazelProfiles(t *testing.T) {
+	f := newFixture(t)
+	a := newProfilesAnalyzer(f, WithBazelProfiles())
+
+	tests := []struct {
+		name       string
+		profile    string
+		builtins   []string
+		notPresent []string
+	}{
+		{name: "Tiltfile", builtins: []string{"docker_build", "len"}, notPresent: []string{"glob", "rule"}},
+		{name: "BUILD", profile: "bazel-build", builtins: []string{"glob", "select", "len"}, notPresent: []string{"docker_build", "rule"}},
+		{name: "BUILD.bazel", profile: "bazel-build", builtins: []string{"genrule"}},
+		{name: "WORKSPACE", profile: "bazel-build", builtins: []string{"glob"}},
+		{name: "defs.bzl", profile: "bazel-bzl", builtins: []string{"rule", "attr.string", "native.glob", "len"}, notPresent: []string{"docker_build", "glob"}},
+		{name: "lib.star", builtins: []string{"docker_build"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			da := a.ForDocument(uri.File("/workspace/pkg/"+tt.name), "starlark")
+			assert.Equal(t, tt.profile, da.Profile())
+			for _, name := range tt.builtins {
+				_, found := da.builtins.Functions[name]
+				assert.True(t, found, "builtin %s", name)
+			}
+			for _, name := range tt.notPresent {
+				_, found := da.builtins.Functions[name]
+				assert.False(t, found, "builtin %s", name)
+			}
+		})
+	}
+
+	// unsaved buffers have no file name to match
+	assert.Same(t, a, a.ForDocument("untitled:Untitled-1", "starlark"))
+}
+
+func TestProfileLanguageID(t *testing.T) {
+	f := newFixture(t)
+	sky, err := LoadBuiltinsFromSource(f.ctx, []byte("def sky_rule(name):\n  pass\n"), "sky.py")
+	require.NoError(t, err)
+	a := newProfilesAnalyzer(f, WithProfiles(Profile{
+		Name:        "sky",
+		Patterns:    []string{"*.sky"},
+		LanguageIDs: []string{"skylark"},
+		Builtins:    sky,
+	}))
+
+	assert.Equal(t, "sky", a.ForDocument(uri.File("/workspace/defs.sky"), "").Profile())
+	assert.Equal(t, "sky", a.ForDocument(uri.File("/workspace/defs.star"), "skylark").Profile())
+	assert.Equal(t, "", a.ForDocument(uri.File("/workspace/defs.star"), "starlark").Profile())
+	assert.Same(t, a, a.ForDocument(uri.File("/workspace/Tiltfile"), ""))
+
+	// unsaved buffers have no file name
+	assert.Equal(t, "sky", a.ForDocument("untitled:Untitled-1", "skylark").Profile())
+	assert.Same(t, a, a.ForDocument("untitled:Untitled-1", "starlark"))
+}
+
+func TestProfileCompletionAndSignature(t *testing.T) {
+	f := newFixture(t)
+	a := newProfilesAnalyzer(f, WithBazelProfiles())
+
+	doc := f.Document("BUILD", "glo")
+	result := a.ForDocument(doc.URI(), "").Completion(f.ctx, doc, protocol.Position{Character: 3})
+	assertCompletionResult(t, []string{"glob"}, result)
+
+	doc = f.Document("defs.bzl", "rule(")
+	help := a.ForDocument(doc.URI(), "").SignatureHelp(doc, protocol.Position{Character: 5})
+	require.NotNil(t, help)
+	require.Len(t, help.Signatures, 1)
+	assert.Contains(t, help.Signatures[0].Label, "implementation")
+
+	doc = f.Document("Tiltfile", "rule(")
+	assert.Nil(t, a.ForDocument(doc.URI(), "").SignatureHelp(doc, protocol.Position{Character: 5}))
+}
@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// maxCompletionItems limits the size of a completion response. If there are
+// more candidates, the response is marked as incomplete so that the editor
+// asks again as the user keeps typing.
+const maxCompletionItems = 100
+
+// Relevance tiers for completion candidates, from least to most relevant.
+const (
+	tierBuiltin = iota
+	tierLoaded
+	tierModule
+	tierLocal
+	tierTypedMember
+	tierKeywordArg
+)
+
+type completionCandidate struct {
+	query.Symbol
+	score   int
+	tier    int
+	recency int
+}
+
+// matchCandidates returns the symbols that fuzzy match the pattern.
+func matchCandidates(symbols []query.Symbol, pattern string, tierOf func(query.Symbol) int) []completionCandidate {
+	candidates := []completionCandidate{}
+	for _, sym := range symbols {
+		if score, ok := FuzzyMatch(pattern, sym.Name); ok {
+			candidates = append(candidates, completionCandidate{
+				Symbol: sym,
+				score:  score,
+				tier:   tierOf(sym),
+			})
+		}
+	}
+	return candidates
+}
+
+// symbolTiers returns a function that determines where symbols available at
+// the node come from: keyword arguments of the enclosing call, local scopes,
+// the document itself, other documents via load(), or builtins.
+func (a *Analyzer) symbolTiers(doc document.Document, node *sitter.Node) func(query.Symbol) int {
+	locals := make(map[string]bool)
+	if node != nil {
+		for _, sym := range query.SymbolsInScope(doc, node) {
+			locals[sym.Name] = true
+		}
+	}
+	docSymbols := make(map[string]query.Symbol)
+	for _, sym := range doc.Symbols() {
+		docSymbols[sym.Name] = sym
+	}
+
+	return func(sym query.Symbol) int {
+		if strings.HasSuffix(sym.Name, "=") {
+			return tierKeywordArg
+		}
+		if locals[sym.Name] {
+			return tierLocal
+		}
+		if docSym, found := docSymbols[sym.Name]; found {
+			if docSym.Location.URI == doc.URI() {
+				return tierModule
+			}
+			return tierLoaded
+		}
+		return tierBuiltin
+	}
+}
+
+// rankCandidates sorts the candidates from most to least relevant.
+//
+// Candidates from more specific scopes come first: keyword arguments, then
+// locals, then symbols of the module, then loaded symbols, then builtins.
+// Within a scope, better matches are preferred, then recently used items.
+func rankCandidates(candidates []completionCandidate, history *CompletionHistory) {
+	if history != nil {
+		for i := range candidates {
+			candidates[i].recency = history.recency(candidates[i].Name)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := &candidates[i], &candidates[j]
+		if ci.tier != cj.tier {
+			return ci.tier > cj.tier
+		}
+		if ci.score != cj.score {
+			return ci.score > cj.score
+		}
+		if ci.recency != cj.recency {
+			return ci.recency > cj.recency
+		}
+		return ci.Name < cj.Name
+	})
+}
@@ -52,9 +52,14 @@ func (a *Analyzer) checkForTypedMethod(doc document.Document, node *sitter.Node,
 		node = node.Parent()
 	}
 	expr := a.findAttrObjectExpression([]*sitter.Node{node}, afterDot)
-	if t := a.analyzeType(doc, expr); t != "" {
-		if ty, ok := a.builtins.Types[t]; ok {
-			return ty.FindMethod(methodName)
+	if expr == nil {
+		return query.Signature{}, false
+	}
+	for _, alt := range a.InferType(doc, expr).Alternatives() {
+		if ty, ok := a.builtins.Types[alt.Name]; ok {
+			if meth, found := ty.FindMethod(methodName); found {
+				return meth, true
+			}
 		}
 	}
 	return query.Signature{}, false
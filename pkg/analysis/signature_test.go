@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.lsp.dev/protocol"
 )
 
@@ -89,3 +90,13 @@ d.pop()`)
 	assert.Equal(t, "(key)", help.Signatures[0].Label)
 	assert.Equal(t, uint32(0), help.ActiveParameter)
 }
+
+func TestInferredTypeMethodSignatureHelp(t *testing.T) {
+	f := newFixture(t)
+	_ = WithStarlarkBuiltins()(f.a)
+	doc := f.MainDoc(`for name in dir(x):
+  name.endswith()`)
+	help := f.a.SignatureHelp(doc, protocol.Position{Line: 1, Character: 16})
+	require.NotNil(t, help)
+	assert.Equal(t, "(suffix) -> bool", help.Signatures[0].Label)
+}
@@ -0,0 +1,186 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+type structuralSnippet struct {
+	label, detail, body string
+	// moduleOnly snippets are only offered at the top level (no indentation)
+	moduleOnly bool
+	// expression snippets can be used anywhere, not just at the start of a
+	// statement
+	expression bool
+}
+
+var structuralSnippets = []structuralSnippet{
+	{
+		label:  "def",
+		detail: "function definition with docstring",
+		body: `def ${1:name}(${2:arg}):
+    """${3:Description.}
+
+    Args:
+      ${2:arg}: ${4:Description of arg.}
+
+    Returns:
+      ${5:Description of the return value.}
+    """
+    ${0:pass}`,
+	},
+	{
+		label:      "load",
+		detail:     "load statement",
+		body:       `load("${1:path}", "${2:symbol}")$0`,
+		moduleOnly: true,
+	},
+	{
+		label:  "for",
+		detail: "for loop",
+		body: `for ${1:item} in ${2:items}:
+    ${0:pass}`,
+	},
+	{
+		label:  "if",
+		detail: "if statement",
+		body: `if ${1:condition}:
+    ${0:pass}`,
+	},
+	{
+		label:      "listcomp",
+		detail:     "list comprehension",
+		body:       `[${1:item} for ${1:item} in ${2:items}]$0`,
+		expression: true,
+	},
+	{
+		label:      "dictcomp",
+		detail:     "dict comprehension",
+		body:       `{${1:key}: ${2:value} for ${1:key}, ${2:value} in ${3:items}.items()}$0`,
+		expression: true,
+	},
+}
+
+// escapeSnippetText escapes characters that have a special meaning inside of
+// a snippet placeholder.
+func escapeSnippetText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`).Replace(s)
+}
+
+// functionSnippet builds snippet insert text for a call to the function,
+// with a placeholder for each required parameter. Keyword-only parameters
+// are passed by keyword.
+func functionSnippet(name string, sig query.Signature) string {
+	var sb strings.Builder
+	sb.WriteString(escapeSnippetText(name))
+	sb.WriteRune('(')
+	placeholder := 0
+	for _, param := range sig.Params {
+		if param.DefaultValue != "" || strings.HasPrefix(param.Content, "*") || param.Name == "" {
+			continue
+		}
+		if placeholder > 0 {
+			sb.WriteString(", ")
+		}
+		placeholder++
+		name := escapeSnippetText(param.Name)
+		if param.KeywordOnly {
+			sb.WriteString(name + "=")
+		}
+		sb.WriteString(fmt.Sprintf("${%d:%s}", placeholder, name))
+	}
+	sb.WriteString(")$0")
+	return sb.String()
+}
+
+// completionSignature finds the signature of a function symbol offered as a
+// completion candidate, where identifiers are the identifiers of the
+// attribute expression being completed.
+func (a *Analyzer) completionSignature(doc document.Document, node *sitter.Node, identifiers []string, sym query.Symbol) (query.Signature, bool) {
+	switch sym.Kind {
+	case protocol.SymbolKindFunction, protocol.SymbolKindMethod:
+	default:
+		return query.Signature{}, false
+	}
+
+	if len(identifiers) <= 1 {
+		return a.signatureInformation(doc, node, callWithArguments{fnName: sym.Name})
+	}
+
+	fnName := strings.Join(append(append([]string{}, identifiers[:len(identifiers)-1]...), sym.Name), ".")
+	if sig, found := a.builtins.Functions[fnName]; found {
+		return sig, true
+	}
+	sig, found := a.builtins.Methods[sym.Name]
+	return sig, found
+}
+
+// followedByParen checks whether the next non-identifier character after
+// the point is an opening parenthesis, in which case the arguments are
+// already present and should not be inserted again.
+func followedByParen(doc document.Document, pt sitter.Point) bool {
+	line := lineContent(doc, pt.Row)
+	for i := int(pt.Column); i < len(line); i++ {
+		c := line[i]
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			continue
+		}
+		return c == '('
+	}
+	return false
+}
+
+// lineContent returns the content of a line of the document, including the
+// trailing newline, if any.
+func lineContent(doc document.Document, row uint32) string {
+	lines := strings.SplitAfter(string(doc.Input()), "\n")
+	if int(row) >= len(lines) {
+		return ""
+	}
+	return lines[row]
+}
+
+// linePrefix returns the content of the line that the point is on, up to the
+// given column.
+func linePrefix(doc document.Document, row, column uint32) string {
+	line := lineContent(doc, row)
+	if int(column) > len(line) {
+		column = uint32(len(line))
+	}
+	return line[:column]
+}
+
+// structuralSnippetItems returns the snippets that match the prefix being
+// completed. Statement snippets are only offered if the prefix is the first
+// thing on the line.
+func structuralSnippetItems(doc document.Document, pt sitter.Point, identifiers []string) []protocol.CompletionItem {
+	if len(identifiers) != 1 || uint32(len(identifiers[0])) > pt.Column {
+		return nil
+	}
+	prefix := identifiers[0]
+	indent := linePrefix(doc, pt.Row, pt.Column-uint32(len(prefix)))
+	statementStart := strings.TrimSpace(indent) == ""
+
+	items := []protocol.CompletionItem{}
+	for _, s := range structuralSnippets {
+		if !strings.HasPrefix(s.label, prefix) ||
+			(!s.expression && !statementStart) ||
+			(s.moduleOnly && indent != "") {
+			continue
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:            s.label,
+			Detail:           s.detail,
+			Kind:             protocol.CompletionItemKindSnippet,
+			InsertText:       s.body,
+			InsertTextFormat: protocol.InsertTextFormatSnippet,
+		})
+	}
+	return items
+}
@@ -0,0 +1,212 @@
+package analysis
+
+import (
+	"fmt"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// TypeMismatchCode is the diagnostic code of type mismatch warnings.
+const TypeMismatchCode = "type-mismatch"
+
+// coreTypes are the types of values that Starlark has built in. A value of
+// one of these types can never be of another named type.
+var coreTypes = map[string]bool{
+	typeNameNone:     true,
+	typeNameBool:     true,
+	typeNameInt:      true,
+	typeNameFloat:    true,
+	typeNameString:   true,
+	typeNameList:     true,
+	typeNameDict:     true,
+	typeNameSet:      true,
+	typeNameTuple:    true,
+	typeNameFunction: true,
+}
+
+// WithTypeChecking enables diagnostics for function arguments whose type
+// doesn't match the type hint of the parameter.
+func WithTypeChecking(enabled bool) AnalyzerOption {
+	return func(analyzer *Analyzer) error {
+		analyzer.typeChecking = enabled
+		return nil
+	}
+}
+
+// Diagnostics analyzes the document and returns warnings about likely
+// mistakes, in addition to the diagnostics of the document itself (e.g. for
+// load statements).
+//
+// If type checking is enabled, arguments to functions with type hints are
+// checked. Only certain mismatches are reported, e.g. an int literal passed
+// to a `str` parameter, but not a value whose type can't be determined or a
+// parameter hinted as `Any`. Diagnostics with codes disabled by
+// WithDisabledDiagnostics are left out.
+func (a *Analyzer) Diagnostics(doc document.Document) []protocol.Diagnostic {
+	diags := append([]protocol.Diagnostic{}, doc.Diagnostics()...)
+	if a.typeChecking {
+		ti := a.newTypeInference(doc)
+		query.Query(doc.Tree().RootNode(), `(call) @call`, func(q *sitter.Query, match *sitter.QueryMatch) bool {
+			for _, c := range match.Captures {
+				diags = append(diags, a.checkCall(ti, c.Node)...)
+			}
+			return true
+		})
+	}
+	return a.enabledDiagnostics(diags)
+}
+
+// checkCall checks the arguments of a call against the parameter types of
+// the function being called.
+func (a *Analyzer) checkCall(ti *typeInference, call *sitter.Node) []protocol.Diagnostic {
+	doc := ti.doc
+	fn := call.ChildByFieldName("function")
+	fnName := doc.Content(fn)
+	args := call.ChildByFieldName("arguments")
+	if args == nil || args.Type() != query.NodeTypeArgList {
+		return nil
+	}
+	sig, found := a.calledSignature(doc, call, fn, args)
+	if !found {
+		return nil
+	}
+
+	var diags []protocol.Diagnostic
+	for _, arg := range matchArguments(doc, sig, args) {
+		if arg.param.TypeHint == "" {
+			continue
+		}
+		expected := a.builtins.ResolveType(arg.param.TypeHintExpr())
+		actual := ti.exprType(arg.value)
+		if !a.certainMismatch(actual, expected) {
+			continue
+		}
+		diags = append(diags, protocol.Diagnostic{
+			Range:    query.NodeRange(arg.value),
+			Severity: protocol.DiagnosticSeverityWarning,
+			Code:     TypeMismatchCode,
+			Message: fmt.Sprintf("argument '%s' of %s() should be %s, not %s",
+				arg.param.Name, fnName, expected, actual),
+		})
+	}
+	return diags
+}
+
+// calledSignature finds the signature of the function being called. Unlike
+// for signature help, methods are only considered if the type of the object
+// is known, so that a method of another type with the same name isn't used.
+func (a *Analyzer) calledSignature(doc document.Document, call, fn, args *sitter.Node) (query.Signature, bool) {
+	fnName := doc.Content(fn)
+	if fn.Type() != query.NodeTypeAttribute {
+		return a.signatureInformation(doc, call, callWithArguments{fnName: fnName, argsNode: args})
+	}
+	if sig, found := a.builtins.Functions[fnName]; found {
+		return sig, true
+	}
+	method := doc.Content(fn.ChildByFieldName("attribute"))
+	for _, alt := range a.InferType(doc, fn.ChildByFieldName("object")).Alternatives() {
+		if class, found := a.builtins.Types[alt.Name]; found {
+			if sig, found := class.FindMethod(method); found {
+				return sig, true
+			}
+		}
+	}
+	return query.Signature{}, false
+}
+
+type matchedArgument struct {
+	param query.Parameter
+	value *sitter.Node
+}
+
+// matchArguments pairs the arguments of a call with the parameters they are
+// passed to. Arguments passed to `*args` or `**kwargs` and arguments after
+// an unpacked `*list` aren't matched.
+func matchArguments(doc document.Document, sig query.Signature, args *sitter.Node) []matchedArgument {
+	var matched []matchedArgument
+	positional := 0
+	for i := 0; i < int(args.NamedChildCount()); i++ {
+		arg := args.NamedChild(i)
+		switch arg.Type() {
+		case query.NodeTypeKeywordArgument:
+			name := doc.Content(arg.ChildByFieldName(query.FieldName))
+			for _, param := range sig.Params {
+				if param.Name == name && !isVariadicParam(param) {
+					matched = append(matched, matchedArgument{param: param, value: arg.ChildByFieldName("value")})
+				}
+			}
+		case "list_splat":
+			positional = len(sig.Params)
+		case "dictionary_splat", query.NodeTypeComment:
+		default:
+			if positional < len(sig.Params) && !isVariadicParam(sig.Params[positional]) {
+				matched = append(matched, matchedArgument{param: sig.Params[positional], value: arg})
+				positional++
+			} else {
+				positional = len(sig.Params)
+			}
+		}
+	}
+	return matched
+}
+
+func isVariadicParam(param query.Parameter) bool {
+	return len(param.Content) > 0 && param.Content[0] == '*'
+}
+
+// certainMismatch reports whether a value of the actual type can't possibly
+// be of the expected type, i.e. none of the alternatives of the actual type
+// are compatible with any of the alternatives of the expected type.
+func (a *Analyzer) certainMismatch(actual, expected Type) bool {
+	if actual.IsUnknown() || actual.IsAny() || expected.IsUnknown() || expected.IsAny() {
+		return false
+	}
+	for _, act := range actual.Alternatives() {
+		for _, exp := range expected.Alternatives() {
+			if a.compatible(act, exp) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compatible reports whether a value of the actual type could be passed
+// where the expected type is required. Neither type is a union.
+func (a *Analyzer) compatible(actual, expected Type) bool {
+	if actual.IsAny() || expected.IsAny() {
+		return true
+	}
+	if actual.Name == expected.Name {
+		if len(actual.Args) == 0 || len(actual.Args) != len(expected.Args) {
+			return true
+		}
+		for i := range actual.Args {
+			if a.certainMismatch(actual.Args[i], expected.Args[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case expected.Name == typeNameFloat && actual.Name == typeNameInt:
+		return true
+	case expected.Name == typeNameInt && actual.Name == typeNameBool:
+		return true
+	case coreTypes[actual.Name] && coreTypes[expected.Name]:
+		return false
+	}
+
+	// A core value can't be of a type defined in the builtins, e.g. a
+	// `Blob`, but anything else might be: the actual type could be a custom
+	// type or the expected type might not be known at all.
+	if _, isClass := a.builtins.Types[expected.Name]; isClass && coreTypes[actual.Name] {
+		return false
+	}
+	return true
+}
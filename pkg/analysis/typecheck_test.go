@@ -0,0 +1,157 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+)
+
+const typeCheckBuiltins = `
+def k8s_yaml(yaml: Union[str, List[str], Blob], allow_duplicates: bool = False) -> None:
+  pass
+
+def docker_build(ref: str, context: str = ".", *, build_args: Dict[str, str] = {}, **kwargs) -> None:
+  pass
+
+def local(command: Union[str, List[str]], quiet: bool = False) -> Blob:
+  pass
+
+def listdir(paths: List[str]):
+  pass
+
+def anything(x: Any, y: object, z: SomeUndefinedType):
+  pass
+
+def timeout(seconds: float):
+  pass
+
+def echo(*args: str):
+  pass
+
+class Blob:
+  def split(self) -> List[String]:
+    pass
+
+class String:
+  def replace(self, old: str, new: str) -> String:
+    pass
+`
+
+func typeCheckMessages(t *testing.T, src string) []string {
+	t.Helper()
+	f := newFixture(t)
+	f.ParseBuiltins(typeCheckBuiltins)
+	require.NoError(t, WithTypeChecking(true)(f.a))
+
+	doc := f.MainDoc(src)
+	messages := []string{}
+	for _, d := range f.a.Diagnostics(doc) {
+		require.Equal(t, protocol.DiagnosticSeverityWarning, d.Severity)
+		require.Equal(t, TypeMismatchCode, d.Code)
+		messages = append(messages, contentByRange(doc, d.Range)+": "+d.Message)
+	}
+	return messages
+}
+
+func TestTypeCheckMismatches(t *testing.T) {
+	for _, tc := range []struct {
+		name, src string
+		expected  []string
+	}{
+		{"int to str", `docker_build(1)`, []string{
+			"1: argument 'ref' of docker_build() should be String, not int",
+		}},
+		{"keyword argument", `docker_build("img", context=["."])`, []string{
+			`["."]: argument 'context' of docker_build() should be String, not List[String]`,
+		}},
+		{"dict to list", `listdir({"a": "b"})`, []string{
+			`{"a": "b"}: argument 'paths' of listdir() should be List[String], not Dict[String, String]`,
+		}},
+		{"list element type", `listdir([1, 2])`, []string{
+			"[1, 2]: argument 'paths' of listdir() should be List[String], not List[int]",
+		}},
+		{"union", `k8s_yaml(42)`, []string{
+			"42: argument 'yaml' of k8s_yaml() should be Blob | List[String] | String, not int",
+		}},
+		{"inferred variable", `
+x = 1
+if c:
+  x = True
+docker_build(x)`, []string{
+			"x: argument 'ref' of docker_build() should be String, not bool | int",
+		}},
+		{"method", `"a".replace(1, "b")`, []string{
+			"1: argument 'old' of \"a\".replace() should be String, not int",
+		}},
+		{"variadic", `echo("a", 1)`, []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, typeCheckMessages(t, tc.src))
+		})
+	}
+}
+
+func TestTypeCheckNoFalsePositives(t *testing.T) {
+	for _, tc := range []struct {
+		name, src string
+	}{
+		{"matching", `docker_build("img", context=".", build_args={"a": "b"})`},
+		{"union alternative", `k8s_yaml(["a.yaml"])`},
+		{"custom type", `k8s_yaml(local("kustomize build"))`},
+		{"unknown variable", `docker_build(ref)`},
+		{"unknown function result", `docker_build(get_ref())`},
+		{"possibly matching union", `
+x = None
+if c:
+  x = "img"
+docker_build(x)`},
+		{"parameter of unknown type", `
+def f(ref):
+  docker_build(ref)`},
+		{"Any", `anything(1, 2, 3)`},
+		{"int to float", `timeout(5)`},
+		{"bool to bool", `k8s_yaml("a.yaml", allow_duplicates=True)`},
+		{"empty list", `listdir([])`},
+		{"unpacked arguments", `docker_build(*args)`},
+		{"kwargs", `docker_build("img", platform=1)`},
+		{"method of unknown object", `x.replace(1, 2)`},
+		{"no type hints", `print(1)`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Empty(t, typeCheckMessages(t, tc.src))
+		})
+	}
+}
+
+func TestTypeCheckDisabled(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(typeCheckBuiltins)
+
+	doc := f.MainDoc(`docker_build(1)`)
+	assert.Empty(t, f.a.Diagnostics(doc))
+}
+
+func TestDisabledDiagnostics(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(typeCheckBuiltins)
+	require.NoError(t, WithTypeChecking(true)(f.a))
+
+	doc := f.MainDoc("load('missing.star', 'x')\nlocal(1)\n")
+	codes := func() []interface{} {
+		var codes []interface{}
+		for _, d := range f.a.Diagnostics(doc) {
+			codes = append(codes, d.Code)
+		}
+		return codes
+	}
+	assert.ElementsMatch(t, []interface{}{document.LoadFailedCode, TypeMismatchCode}, codes())
+
+	require.NoError(t, WithDisabledDiagnostics(TypeMismatchCode)(f.a))
+	assert.Equal(t, []interface{}{document.LoadFailedCode}, codes())
+	// the diagnostics of the document itself are left alone
+	assert.Len(t, doc.Diagnostics(), 1)
+}
@@ -0,0 +1,284 @@
+package analysis
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// Names of the types that have special meaning during type inference. Other
+// types are referred to by the name of their class in the builtins, e.g.
+// `String`, `List` or `Dict`.
+const (
+	typeNameAny      = "Any"
+	typeNameNone     = "None"
+	typeNameBool     = "bool"
+	typeNameInt      = "int"
+	typeNameFloat    = "float"
+	typeNameString   = "String"
+	typeNameList     = "List"
+	typeNameDict     = "Dict"
+	typeNameSet      = "Set"
+	typeNameTuple    = "Tuple"
+	typeNameFunction = "function"
+	typeNameStruct   = "struct"
+)
+
+// maxUnionSize is the number of alternatives a union type can have before it
+// is widened to Any.
+const maxUnionSize = 4
+
+// Type is the inferred type of a Starlark value.
+//
+// Types form a lattice: the zero value means that nothing is known about the
+// value (e.g. the code is unreachable or hasn't been analyzed), `Any` means
+// that the value could be of any type, and in between are named types with
+// optional type arguments (`List[String]`) and unions of those.
+type Type struct {
+	Name string
+	Args []Type
+	// Union holds the alternatives of a union type, in which case Name is
+	// empty. The alternatives are never unions themselves.
+	Union []Type
+}
+
+var (
+	AnyType    = NamedType(typeNameAny)
+	NoneType   = NamedType(typeNameNone)
+	BoolType   = NamedType(typeNameBool)
+	IntType    = NamedType(typeNameInt)
+	FloatType  = NamedType(typeNameFloat)
+	StringType = NamedType(typeNameString)
+)
+
+// NamedType creates a type from a name and optional type arguments.
+func NamedType(name string, args ...Type) Type {
+	for _, arg := range args {
+		if !arg.IsUnknown() {
+			return Type{Name: normalizeTypeName(name), Args: args}
+		}
+	}
+	return Type{Name: normalizeTypeName(name)}
+}
+
+// UnionType joins the types into a single type.
+func UnionType(types ...Type) Type {
+	var result Type
+	for _, t := range types {
+		result = result.Join(t)
+	}
+	return result
+}
+
+// normalizeTypeName maps the different spellings of builtin types, as used in
+// type hints and docstrings, to the names used in the builtins.
+func normalizeTypeName(name string) string {
+	switch strings.ToLower(name) {
+	case "any", "object":
+		return typeNameAny
+	case "none", "nonetype":
+		return typeNameNone
+	case "bool":
+		return typeNameBool
+	case "int":
+		return typeNameInt
+	case "float":
+		return typeNameFloat
+	case "str", "string":
+		return typeNameString
+	case "list", "sequence", "iterable":
+		return typeNameList
+	case "dict", "mapping":
+		return typeNameDict
+	case "set":
+		return typeNameSet
+	case "tuple":
+		return typeNameTuple
+	case "function", "callable":
+		return typeNameFunction
+	case "struct":
+		return typeNameStruct
+	}
+	return name
+}
+
+// IsUnknown reports whether nothing is known about the type.
+func (t Type) IsUnknown() bool {
+	return t.Name == "" && len(t.Union) == 0
+}
+
+// IsAny reports whether the type could be anything.
+func (t Type) IsAny() bool {
+	return t.Name == typeNameAny
+}
+
+// IsUnion reports whether the type has multiple alternatives.
+func (t Type) IsUnion() bool {
+	return len(t.Union) > 0
+}
+
+// Alternatives returns the alternatives of a union type, or the type itself
+// for any other known type.
+func (t Type) Alternatives() []Type {
+	if t.IsUnion() {
+		return t.Union
+	}
+	if t.IsUnknown() {
+		return nil
+	}
+	return []Type{t}
+}
+
+// Arg returns the i-th type argument, or Any if the type has no such
+// argument.
+func (t Type) Arg(i int) Type {
+	if i < len(t.Args) && !t.Args[i].IsUnknown() {
+		return t.Args[i]
+	}
+	return AnyType
+}
+
+// Equal reports whether the types are the same, regardless of the order of
+// union alternatives.
+func (t Type) Equal(other Type) bool {
+	return t.String() == other.String()
+}
+
+// Join returns the least upper bound of the two types, i.e. the type of a
+// value that could be of either type.
+func (t Type) Join(other Type) Type {
+	switch {
+	case t.IsUnknown():
+		return other
+	case other.IsUnknown():
+		return t
+	case t.IsAny() || other.IsAny():
+		return AnyType
+	}
+
+	alternatives := append([]Type{}, t.Alternatives()...)
+	for _, o := range other.Alternatives() {
+		merged := false
+		for i, alt := range alternatives {
+			if alt.Name == o.Name {
+				alternatives[i] = joinArgs(alt, o)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			alternatives = append(alternatives, o)
+		}
+	}
+
+	if len(alternatives) == 1 {
+		return alternatives[0]
+	}
+	if len(alternatives) > maxUnionSize {
+		return AnyType
+	}
+	sort.Slice(alternatives, func(i, j int) bool {
+		return alternatives[i].String() < alternatives[j].String()
+	})
+	return Type{Union: alternatives}
+}
+
+// joinArgs joins two types with the same name by joining their type
+// arguments.
+func joinArgs(t, other Type) Type {
+	if len(t.Args) == 0 || len(t.Args) != len(other.Args) {
+		return Type{Name: t.Name}
+	}
+	args := make([]Type, len(t.Args))
+	for i := range t.Args {
+		args[i] = t.Args[i].Join(other.Args[i])
+	}
+	return Type{Name: t.Name, Args: args}
+}
+
+// Without returns the type without the alternatives with the given name,
+// e.g. to remove None from an optional type.
+func (t Type) Without(name string) Type {
+	var result Type
+	for _, alt := range t.Alternatives() {
+		if alt.Name != name {
+			result = result.Join(alt)
+		}
+	}
+	return result
+}
+
+// Has reports whether the type is or could be of the type with the given
+// name.
+func (t Type) Has(name string) bool {
+	for _, alt := range t.Alternatives() {
+		if alt.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the type the same way as types are written in the builtins,
+// e.g. `List[String]`, or `int | None` for unions.
+func (t Type) String() string {
+	if t.IsUnion() {
+		alternatives := make([]string, len(t.Union))
+		for i, alt := range t.Union {
+			alternatives[i] = alt.String()
+		}
+		return strings.Join(alternatives, " | ")
+	}
+	if len(t.Args) == 0 {
+		return t.Name
+	}
+	args := make([]string, len(t.Args))
+	for i, arg := range t.Args {
+		if arg.IsUnknown() {
+			args[i] = typeNameAny
+		} else {
+			args[i] = arg.String()
+		}
+	}
+	return t.Name + "[" + strings.Join(args, ", ") + "]"
+}
+
+// ResolveType converts a type expression from a type annotation to a Type,
+// resolving the names of builtin types to the corresponding classes in the
+// builtins, e.g. `str` to `String`.
+func (b *Builtins) ResolveType(expr query.TypeExpr) Type {
+	switch {
+	case expr.IsZero():
+		return Type{}
+	case len(expr.Union) > 0:
+		var t Type
+		for _, alt := range expr.Union {
+			t = t.Join(b.ResolveType(alt))
+		}
+		return t
+	case expr.IsCallable():
+		return NamedType(typeNameFunction)
+	}
+
+	args := make([]Type, len(expr.Args))
+	for i, arg := range expr.Args {
+		args[i] = b.ResolveType(arg)
+	}
+	return NamedType(b.resolveTypeName(expr.Name), args...)
+}
+
+func (b *Builtins) resolveTypeName(name string) string {
+	if _, found := b.Types[name]; found {
+		return name
+	}
+	if normalized := normalizeTypeName(name); normalized != name {
+		return normalized
+	}
+	for typeName := range b.Types {
+		if strings.EqualFold(typeName, name) {
+			return typeName
+		}
+	}
+	return name
+}
@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+func TestTypeJoin(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		types    []Type
+		expected string
+	}{
+		{"unknown", []Type{{}, {}}, ""},
+		{"unknown is identity", []Type{{}, StringType}, "String"},
+		{"same", []Type{StringType, StringType}, "String"},
+		{"union", []Type{StringType, IntType}, "String | int"},
+		{"union is sorted", []Type{IntType, StringType, NoneType}, "None | String | int"},
+		{"any absorbs", []Type{StringType, AnyType}, "Any"},
+		{"args joined", []Type{NamedType("List", StringType), NamedType("List", IntType)}, "List[String | int]"},
+		{"args dropped", []Type{NamedType("List", StringType), NamedType("List")}, "List"},
+		{"widened to any", []Type{StringType, IntType, NoneType, BoolType, FloatType}, "Any"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, UnionType(tc.types...).String())
+		})
+	}
+}
+
+func TestResolveType(t *testing.T) {
+	f := newFixture(t)
+	f.ParseBuiltins(`
+class String:
+  def upper(self) -> String:
+    pass
+class Blob:
+  def text(self) -> String:
+    pass
+`)
+	for _, tc := range []struct {
+		s, expected string
+	}{
+		{"", ""},
+		{"str", "String"},
+		{"List[String]", "List[String]"},
+		{"List[Tuple[int, any]]", "List[Tuple[int, Any]]"},
+		{"dict[str, list]", "Dict[String, List]"},
+		{"Optional[str]", "None | String"},
+		{"Union[int, None, str]", "None | String | int"},
+		{"Callable[[str], bool]", "function"},
+		{"blob", "Blob"},
+		{"k8s_object", "k8s_object"},
+	} {
+		t.Run(tc.s, func(t *testing.T) {
+			expr, err := query.ParseTypeExpr(tc.s)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, f.builtins.ResolveType(expr).String())
+		})
+	}
+}
+
+func TestTypeWithout(t *testing.T) {
+	assert.Equal(t, "String", UnionType(StringType, NoneType).Without("None").String())
+	assert.True(t, NoneType.Without("None").IsUnknown())
+}
@@ -0,0 +1,241 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// BuiltinsProblem is a mistake in a builtins stub file that degrades the
+// analysis of documents using the builtins.
+type BuiltinsProblem struct {
+	// Path is the path of the stub file.
+	Path string
+	// Line is the line of the problem, starting at 1, or 0 if the problem
+	// concerns the whole file.
+	Line    int
+	Message string
+}
+
+func (p BuiltinsProblem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Message)
+}
+
+// BuiltinsReport is the result of validating builtins stubs.
+type BuiltinsReport struct {
+	// Problems are ordered by path and line.
+	Problems []BuiltinsProblem
+	// Builtins are the builtins loaded from the stubs, as documents are
+	// analyzed with them.
+	Builtins *Builtins
+}
+
+// ValidateBuiltins loads the builtins from a stub file or directory like
+// LoadBuiltins, and reports the problems of the stubs: syntax errors,
+// functions and methods without docstring, docstrings that document
+// parameters that don't exist, type hints of types that can't be resolved,
+// and modules defined both by a file and by a directory.
+func ValidateBuiltins(ctx context.Context, root string) (*BuiltinsReport, error) {
+	builtins, err := LoadBuiltins(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	starlark, err := LoadBuiltinsFromSource(ctx, StarlarkBuiltins, "builtins.py")
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading builtins from builtins.py")
+	}
+	known := NewBuiltins()
+	known.Update(starlark)
+	known.Update(builtins)
+	v := builtinsValidator{known: known}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "statting %s", root)
+	}
+	if info.IsDir() {
+		fsys := os.DirFS(root)
+		err = fs.WalkDir(fsys, ".", func(p string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				return v.checkModules(fsys, root, p)
+			}
+			if !strings.HasSuffix(p, ".py") {
+				return nil
+			}
+			contents, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return errors.Wrapf(err, "reading %s", p)
+			}
+			return v.checkFile(ctx, filepath.Join(root, filepath.FromSlash(p)), contents)
+		})
+	} else {
+		var contents []byte
+		if contents, err = os.ReadFile(root); err == nil {
+			err = v.checkFile(ctx, root, contents)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		a, b := v.problems[i], v.problems[j]
+		return a.Path < b.Path || a.Path == b.Path && a.Line < b.Line
+	})
+	return &BuiltinsReport{Problems: v.problems, Builtins: builtins}, nil
+}
+
+type builtinsValidator struct {
+	// known are the builtins that type hints can refer to.
+	known    *Builtins
+	problems []BuiltinsProblem
+}
+
+func (v *builtinsValidator) report(path string, line int, format string, args ...interface{}) {
+	v.problems = append(v.problems, BuiltinsProblem{Path: path, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkModules reports the modules of a directory that are defined both by
+// a file and by a directory with an __init__.py file, which are merged.
+func (v *builtinsValidator) checkModules(fsys fs.FS, root, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		file := path.Join(dir, entry.Name()+".py")
+		init := path.Join(dir, entry.Name(), "__init__.py")
+		if _, err := fs.Stat(fsys, file); err != nil {
+			continue
+		}
+		if _, err := fs.Stat(fsys, init); err != nil {
+			continue
+		}
+		v.report(filepath.Join(root, filepath.FromSlash(file)), 0,
+			"module %s is also defined by %s", moduleName(file), filepath.Join(root, filepath.FromSlash(init)))
+	}
+	return nil
+}
+
+// moduleName returns the dotted name of the module of a stub file.
+func moduleName(p string) string {
+	p = strings.TrimSuffix(strings.TrimSuffix(p, ".py"), "/__init__")
+	return strings.ReplaceAll(p, "/", ".")
+}
+
+func (v *builtinsValidator) checkFile(ctx context.Context, path string, contents []byte) error {
+	tree, err := query.Parse(ctx, contents)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse %q", path)
+	}
+	doc := document.NewDocument(uri.File(path), contents, tree)
+	defer doc.Close()
+
+	if root := tree.RootNode(); root.HasError() {
+		v.report(path, int(query.FirstSyntaxError(root).StartPoint().Row)+1, "syntax error")
+	}
+
+	names := make([]string, 0, len(doc.Functions()))
+	for name := range doc.Functions() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		v.checkFunction(path, name, doc.Functions()[name])
+	}
+	for _, t := range query.Types(doc, tree.RootNode()) {
+		for _, method := range t.Methods {
+			v.checkFunction(path, t.Name+"."+method.Name, method)
+		}
+	}
+	return nil
+}
+
+func (v *builtinsValidator) checkFunction(path, name string, fn query.Signature) {
+	line := int(fn.Range.Start.Line) + 1
+	if strings.TrimSpace(fn.Docs.Description) == "" {
+		v.report(path, line, "%s() has no docstring", name)
+	}
+
+	params := make(map[string]bool)
+	for _, p := range fn.Params {
+		params[strings.TrimLeft(p.Name, "*")] = true
+		v.checkTypeHint(path, line, p.TypeHint, "type of parameter '%s' of %s()", p.Name, name)
+	}
+	for _, arg := range fn.Docs.Args() {
+		if !params[strings.TrimLeft(arg.Name, "*")] {
+			v.report(path, line, "docstring of %s() documents '%s', which isn't a parameter", name, arg.Name)
+		}
+	}
+	v.checkTypeHint(path, line, fn.ReturnType, "return type of %s()", name)
+}
+
+// checkTypeHint reports a type hint that can't be parsed or that refers to
+// types that aren't builtins.
+func (v *builtinsValidator) checkTypeHint(path string, line int, hint string, format string, args ...interface{}) {
+	if hint == "" {
+		return
+	}
+	what := fmt.Sprintf(format, args...)
+	expr, err := query.ParseTypeExpr(hint)
+	if err != nil {
+		v.report(path, line, "%s can't be parsed: %v", what, err)
+		return
+	}
+	for _, name := range typeExprNames(expr) {
+		if !v.known.isKnownType(name) {
+			v.report(path, line, "%s refers to unknown type '%s'", what, name)
+		}
+	}
+}
+
+// typeExprNames returns the names of the types a type expression refers to.
+func typeExprNames(expr query.TypeExpr) []string {
+	var names []string
+	if expr.Name != "" && !expr.IsCallable() {
+		names = append(names, expr.Name)
+	}
+	for _, list := range [][]query.TypeExpr{expr.Args, expr.Union, expr.Params} {
+		for _, e := range list {
+			names = append(names, typeExprNames(e)...)
+		}
+	}
+	if expr.Result != nil {
+		names = append(names, typeExprNames(*expr.Result)...)
+	}
+	return names
+}
+
+// isKnownType reports whether a type name of a type hint resolves to a
+// class of the builtins or to a type with special meaning.
+func (b *Builtins) isKnownType(name string) bool {
+	resolved := b.resolveTypeName(name)
+	if _, found := b.Types[resolved]; found {
+		return true
+	}
+	switch resolved {
+	case typeNameAny, typeNameFunction, typeNameStruct:
+		return true
+	}
+	return coreTypes[resolved]
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+func TestValidateBuiltins(t *testing.T) {
+	f := newFixture(t)
+	dir := f.Dir("api")
+	f.File("api/__init__.py", `def local_resource(name: str, deps: List[str] = []) -> Resource:
+    """Runs a command.
+
+    Args:
+      name: Name of the resource.
+      cmd: Command to run.
+    """
+    pass
+
+def undocumented(*args):
+    pass
+
+class Resource:
+    def labels(self) -> Optional[Labels]:
+        """Returns the labels."""
+        pass
+`)
+	f.Dir("api/os")
+	f.File("api/os.py", `def getcwd() -> str:
+    """Returns the working directory."""
+    pass
+`)
+	f.File("api/os/__init__.py", `def getenv(key: str) -> str | None:
+    """Returns an environment variable."""
+    pass
+
+def broken(:
+    pass
+`)
+
+	report, err := ValidateBuiltins(f.ctx, dir)
+	require.NoError(t, err)
+	problems := make([]string, len(report.Problems))
+	for i, p := range report.Problems {
+		rel, err := filepath.Rel(dir, p.Path)
+		require.NoError(t, err)
+		p.Path = filepath.ToSlash(rel)
+		problems[i] = p.String()
+	}
+	assert.Equal(t, []string{
+		"__init__.py:1: docstring of local_resource() documents 'cmd', which isn't a parameter",
+		"__init__.py:10: undocumented() has no docstring",
+		"__init__.py:14: return type of Resource.labels() refers to unknown type 'Labels'",
+		"os.py: module os is also defined by " + filepath.Join(dir, "os", "__init__.py"),
+		"os/__init__.py:5: syntax error",
+		"os/__init__.py:5: broken() has no docstring",
+	}, problems)
+
+	modules := report.Builtins.Modules()
+	require.Len(t, modules, 2)
+	assert.Equal(t, "", modules[0].Name)
+	assert.Equal(t, []string{"local_resource", "undocumented"}, signatureNames(modules[0].Functions))
+	assert.Equal(t, "os", modules[1].Name)
+	assert.Equal(t, []string{"broken", "getcwd", "getenv"}, signatureNames(modules[1].Functions))
+}
+
+func TestValidateStarlarkBuiltins(t *testing.T) {
+	f := newFixture(t)
+
+	report, err := ValidateBuiltins(f.ctx, "builtins.py")
+	require.NoError(t, err)
+	assert.Empty(t, report.Problems)
+}
+
+func signatureNames(sigs []query.Signature) []string {
+	names := make([]string, len(sigs))
+	for i, sig := range sigs {
+		names[i] = sig.Name
+	}
+	return names
+}
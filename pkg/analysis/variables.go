@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// binding is the statement that gave a variable its value: an assignment,
+// an augmented assignment or a for loop. For function parameters, it's the
+// function definition instead.
+type binding struct {
+	stmt *sitter.Node
+	fn   *sitter.Node
+}
+
+// findBinding finds the statement that last bound the variable with the
+// given name before the node, searching the enclosing blocks from the
+// innermost to the module. Unlike type inference, this doesn't consider
+// control flow: the assignment that comes last in the source wins.
+func findBinding(doc document.Document, node *sitter.Node, name string) (binding, bool) {
+	if b, ok := targetBinding(node); ok {
+		return b, true
+	}
+
+	for cur, parent := node, node.Parent(); parent != nil; cur, parent = parent, parent.Parent() {
+		switch parent.Type() {
+		case query.NodeTypeModule, query.NodeTypeBlock:
+			stmts := precedingStatements(parent, cur)
+			for i := len(stmts) - 1; i >= 0; i-- {
+				if stmt := lastBindingIn(doc, stmts[i], name); stmt != nil {
+					return binding{stmt: stmt}, true
+				}
+			}
+		case query.NodeTypeForStatement:
+			if body := parent.ChildByFieldName(query.FieldBody); body != nil && body.Equal(cur) && binds(doc, parent.ChildByFieldName("left"), name) {
+				return binding{stmt: parent}, true
+			}
+		case query.NodeTypeFunctionDef:
+			if body := parent.ChildByFieldName(query.FieldBody); body != nil && body.Equal(cur) {
+				if _, found := functionParameter(doc, parent, name); found {
+					return binding{fn: parent}, true
+				}
+			}
+		case "lambda", "list_comprehension", "set_comprehension", "dictionary_comprehension", "generator_expression":
+			// variables bound within expressions aren't supported
+			return binding{}, false
+		}
+	}
+	return binding{}, false
+}
+
+// targetBinding finds the binding of a node that is itself the target of an
+// assignment or loop, or the name of a parameter.
+func targetBinding(node *sitter.Node) (binding, bool) {
+	for n := node.Parent(); n != nil; n = n.Parent() {
+		switch n.Type() {
+		case "pattern_list", "tuple_pattern", "list_pattern", "list_splat_pattern", "dictionary_splat_pattern":
+			continue
+		case "default_parameter", "typed_default_parameter", "typed_parameter":
+			// only the name of the parameter, not its default value or type
+			id := n.ChildByFieldName(query.FieldName)
+			if id == nil {
+				id = n.NamedChild(0)
+			}
+			if id != nil && nodeContains(id, node) {
+				continue
+			}
+		case query.NodeTypeAssignment, "augmented_assignment", query.NodeTypeForStatement:
+			if left := n.ChildByFieldName("left"); left != nil && nodeContains(left, node) {
+				return binding{stmt: n}, true
+			}
+		case query.NodeTypeParameters:
+			if fn := n.Parent(); fn != nil && fn.Type() == query.NodeTypeFunctionDef {
+				return binding{fn: fn}, true
+			}
+		}
+		return binding{}, false
+	}
+	return binding{}, false
+}
+
+// lastBindingIn finds the last statement within the statement (including
+// itself) that binds the name, without looking into nested functions.
+func lastBindingIn(doc document.Document, stmt *sitter.Node, name string) *sitter.Node {
+	switch stmt.Type() {
+	case query.NodeTypeFunctionDef, "decorated_definition", "class_definition", "lambda":
+		return nil
+	case query.NodeTypeAssignment:
+		for assign := stmt; assign != nil && assign.Type() == query.NodeTypeAssignment; assign = assign.ChildByFieldName("right") {
+			if binds(doc, assign.ChildByFieldName("left"), name) {
+				return stmt
+			}
+		}
+		return nil
+	case "augmented_assignment":
+		if binds(doc, stmt.ChildByFieldName("left"), name) {
+			return stmt
+		}
+		return nil
+	}
+
+	children := namedChildren(stmt)
+	for i := len(children) - 1; i >= 0; i-- {
+		if b := lastBindingIn(doc, children[i], name); b != nil {
+			return b
+		}
+	}
+	if stmt.Type() == query.NodeTypeForStatement && binds(doc, stmt.ChildByFieldName("left"), name) {
+		return stmt
+	}
+	return nil
+}
+
+// functionParameter finds the parameter of the function definition with the
+// given name.
+func functionParameter(doc document.Document, fn *sitter.Node, name string) (query.Parameter, bool) {
+	for _, p := range query.ExtractSignature(doc, fn).Params {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return query.Parameter{}, false
+}
+
+// bindingSource returns the source of the statement that bound a variable
+// for display, e.g. `x = 1` or `for x in items:`, shortened to at most a few
+// lines.
+func bindingSource(doc document.Document, stmt *sitter.Node) string {
+	if right := stmt.ChildByFieldName("right"); stmt.Type() == query.NodeTypeForStatement && right != nil {
+		header := sitter.Range{StartByte: stmt.StartByte(), EndByte: right.EndByte()}
+		return doc.ContentRange(header) + ":"
+	}
+
+	lines := strings.Split(doc.Content(stmt), "\n")
+	if len(lines) > maxBindingSourceLines {
+		lines = append(lines[:maxBindingSourceLines], "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// maxBindingSourceLines limits the lines of an assignment shown on hover,
+// e.g. for large dict literals.
+const maxBindingSourceLines = 5
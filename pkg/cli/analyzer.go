@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/spf13/pflag"
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/server"
+)
+
+// analyzerFlags are the flags shared by the commands that analyze documents,
+// along with the builtins and document manager options the commands were
+// created with.
+type analyzerFlags struct {
+	builtinFSProvider BuiltinFSProvider
+	managerOpts       []document.ManagerOpt
+
+	builtinPaths   []string
+	typeCheck      bool
+	extensionsDir  string
+	extensionRepos map[string]string
+}
+
+// newAnalyzerFlags creates the flags for analyzing documents. If
+// builtinFSProvider is nil, builtins are read from --builtin-paths.
+func newAnalyzerFlags(builtinFSProvider BuiltinFSProvider, managerOpts []document.ManagerOpt) *analyzerFlags {
+	return &analyzerFlags{
+		builtinFSProvider: builtinFSProvider,
+		managerOpts:       managerOpts,
+	}
+}
+
+func (f *analyzerFlags) register(flags *pflag.FlagSet) {
+	if f.builtinFSProvider == nil {
+		flags.StringArrayVar(&f.builtinPaths, "builtin-paths", nil,
+			"Paths to files and directories to parse and treat as additional language builtins")
+	}
+	flags.BoolVar(&f.typeCheck, "type-check", false,
+		"Warn about function arguments that don't match the type hints of the parameters")
+	flags.StringVar(&f.extensionsDir, "extensions-dir", "",
+		"Directory with the downloaded Tilt extension repositories (default ~/.tilt-dev/tilt_modules)")
+	flags.StringToStringVar(&f.extensionRepos, "extension-repo", nil,
+		"Tilt extension repositories as name=URL; file: URLs are used in place")
+}
+
+func (f *analyzerFlags) builtinsOption() analysis.AnalyzerOption {
+	if f.builtinFSProvider != nil {
+		return analysis.WithBuiltins(f.builtinFSProvider())
+	}
+	return analysis.WithBuiltinPaths(f.builtinPaths)
+}
+
+// createAnalyzer creates an analyzer with the options given on the command
+// line, extended or overridden by the configuration.
+func (f *analyzerFlags) createAnalyzer(ctx context.Context, cfg *config.Config) (*analysis.Analyzer, error) {
+	opts := []analysis.AnalyzerOption{
+		analysis.WithStarlarkBuiltins(),
+		f.builtinsOption(),
+		analysis.WithTypeChecking(f.typeCheck),
+	}
+	opts = append(opts, cfg.AnalyzerOptions(ctx)...)
+	return analysis.NewAnalyzer(ctx, opts...)
+}
+
+// rootAnalyzerFunc creates the analyzers for workspace folders from their
+// configuration.
+func (f *analyzerFlags) rootAnalyzerFunc() server.RootAnalyzerFunc {
+	return func(ctx context.Context, root uri.URI, cfg *config.Config) (*analysis.Analyzer, error) {
+		return f.createAnalyzer(ctx, cfg)
+	}
+}
+
+// documentManagerOpts returns the options of document managers. Options the
+// command was created with, like a ReadDocumentFunc of Tilt, take
+// precedence over the flags.
+func (f *analyzerFlags) documentManagerOpts() []document.ManagerOpt {
+	extensions := document.NewExtensions(f.extensionsDir, f.extensionRepos)
+	return append([]document.ManagerOpt{document.WithExtensions(extensions)}, f.managerOpts...)
+}
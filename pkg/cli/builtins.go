@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+)
+
+func newBuiltinsCmd(baseCommandName string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "builtins",
+		Short: "Work with builtins stub files",
+	}
+	cmd.AddCommand(newValidateBuiltinsCmd(baseCommandName).Command)
+	return cmd
+}
+
+type validateBuiltinsCmd struct {
+	*cobra.Command
+	format string
+}
+
+func newValidateBuiltinsCmd(baseCommandName string) *validateBuiltinsCmd {
+	cmd := validateBuiltinsCmd{
+		Command: &cobra.Command{
+			Use:   "validate PATH",
+			Short: "Report problems of builtins stub files",
+			Long: `Report problems of a builtins stub file, or of a directory of them as given
+to --builtin-paths, that degrade completion and other features: syntax
+errors, functions and methods without docstring, docstrings that document
+parameters that don't exist, type hints of unknown types, and modules that
+are defined both by a file and by a directory with an __init__.py file.
+
+The modules, types, methods and members of the builtins are summarized as
+documents are analyzed with them. The command exits with status 1 if there
+are problems.`,
+			Example: fmt.Sprintf(`
+%[1]s builtins validate stubs/`, baseCommandName),
+			Args: cobra.ExactArgs(1),
+		},
+	}
+	cmd.Flags().StringVar(&cmd.format, "format", "text", "Output format: text or json")
+
+	cmd.Command.RunE = func(cc *cobra.Command, args []string) error {
+		if cmd.format != "text" && cmd.format != "json" {
+			return fmt.Errorf("unknown format %q", cmd.format)
+		}
+		cc.SilenceUsage = true
+
+		report, err := analysis.ValidateBuiltins(cc.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		summary := newBuiltinsSummary(report)
+		if cmd.format == "json" {
+			out, err := json.MarshalIndent(summary, "", "  ")
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cc.OutOrStdout(), string(out))
+			if err != nil {
+				return err
+			}
+		} else {
+			summary.print(cc.OutOrStdout())
+		}
+
+		if len(report.Problems) > 0 {
+			cc.SilenceErrors = true
+			return exitError{code: 1}
+		}
+		return nil
+	}
+
+	return &cmd
+}
+
+type builtinsSummary struct {
+	Problems []builtinsProblem `json:"problems"`
+	Modules  []moduleSummary   `json:"modules"`
+	Types    []typeSummary     `json:"types"`
+	Methods  int               `json:"methods"`
+	Members  int               `json:"members"`
+}
+
+type builtinsProblem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+type moduleSummary struct {
+	// Name is the dotted name of the module, or empty for the top level.
+	Name      string   `json:"name"`
+	Functions []string `json:"functions"`
+	Variables []string `json:"variables"`
+}
+
+type typeSummary struct {
+	Name    string   `json:"name"`
+	Methods []string `json:"methods"`
+	Fields  []string `json:"fields"`
+}
+
+func newBuiltinsSummary(report *analysis.BuiltinsReport) builtinsSummary {
+	s := builtinsSummary{
+		Problems: []builtinsProblem{},
+		Modules:  []moduleSummary{},
+		Types:    []typeSummary{},
+		Members:  len(report.Builtins.Members),
+	}
+	for _, p := range report.Problems {
+		s.Problems = append(s.Problems, builtinsProblem{File: displayPath(p.Path), Line: p.Line, Message: p.Message})
+	}
+	for _, m := range report.Builtins.Modules() {
+		ms := moduleSummary{Name: m.Name, Functions: []string{}, Variables: []string{}}
+		for _, fn := range m.Functions {
+			ms.Functions = append(ms.Functions, fn.Name)
+		}
+		for _, v := range m.Variables {
+			ms.Variables = append(ms.Variables, v.Name)
+		}
+		s.Modules = append(s.Modules, ms)
+	}
+	for _, t := range report.Builtins.Types {
+		ts := typeSummary{Name: t.Name, Methods: []string{}, Fields: []string{}}
+		for _, m := range t.Methods {
+			ts.Methods = append(ts.Methods, m.Name)
+		}
+		// methods of the same name on different types are counted for each
+		s.Methods += len(t.Methods)
+		for _, f := range t.Fields {
+			ts.Fields = append(ts.Fields, f.Name)
+		}
+		s.Types = append(s.Types, ts)
+	}
+	sort.Slice(s.Types, func(i, j int) bool { return s.Types[i].Name < s.Types[j].Name })
+	return s
+}
+
+// print prints the problems followed by tables of the modules and types.
+func (s builtinsSummary) print(w io.Writer) {
+	for _, p := range s.Problems {
+		if p.Line == 0 {
+			_, _ = fmt.Fprintf(w, "%s: %s\n", p.File, p.Message)
+		} else {
+			_, _ = fmt.Fprintf(w, "%s:%d: %s\n", p.File, p.Line, p.Message)
+		}
+	}
+	if len(s.Problems) > 0 {
+		_, _ = fmt.Fprintln(w)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "MODULE\tFUNCTIONS\tVARIABLES")
+	for _, m := range s.Modules {
+		name := m.Name
+		if name == "" {
+			name = "(top level)"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\n", name, len(m.Functions), len(m.Variables))
+	}
+	_ = tw.Flush()
+
+	if len(s.Types) > 0 {
+		_, _ = fmt.Fprintln(w)
+		_, _ = fmt.Fprintln(tw, "TYPE\tMETHODS\tFIELDS")
+		for _, t := range s.Types {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\n", t.Name, len(t.Methods), len(t.Fields))
+		}
+		_ = tw.Flush()
+	}
+
+	_, _ = fmt.Fprintf(w, "\nproblems: %d, methods: %d, members: %d\n", len(s.Problems), s.Methods, s.Members)
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBuiltins(t *testing.T) {
+	chdirTemp(t, map[string]string{
+		"stubs/__init__.py": "def local_resource(name: str) -> Resource:\n    \"\"\"Runs a command.\"\"\"\n    pass\n\n" +
+			"class Resource:\n    name: str = \"\"\n\n    def labels(self) -> List[str]:\n        \"\"\"Labels.\"\"\"\n        pass\n\n" +
+			"class Job:\n    def labels(self) -> List[str]:\n        \"\"\"Labels.\"\"\"\n        pass\n",
+		"stubs/os/__init__.py": "def getcwd() -> str:\n    pass\n",
+	})
+
+	out, err := runCommand(t, "builtins", "validate", "stubs")
+	assert.Equal(t, exitError{code: 1}, err)
+	assert.Equal(t, `stubs/os/__init__.py:1: getcwd() has no docstring
+
+MODULE       FUNCTIONS  VARIABLES
+(top level)  1          0
+os           1          0
+
+TYPE      METHODS  FIELDS
+Job       1        0
+Resource  1        1
+
+problems: 1, methods: 2, members: 3
+`, out)
+
+	out, err = runCommand(t, "builtins", "validate", "--format=json", "stubs/__init__.py")
+	require.NoError(t, err)
+	var summary builtinsSummary
+	require.NoError(t, json.Unmarshal([]byte(out), &summary))
+	assert.Equal(t, builtinsSummary{
+		Problems: []builtinsProblem{},
+		Modules:  []moduleSummary{{Name: "", Functions: []string{"local_resource"}, Variables: []string{}}},
+		Types: []typeSummary{
+			{Name: "Job", Methods: []string{"labels"}, Fields: []string{}},
+			{Name: "Resource", Methods: []string{"labels"}, Fields: []string{"name"}},
+		},
+		Methods: 2,
+		Members: 3,
+	}, summary)
+}
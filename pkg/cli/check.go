@@ -0,0 +1,321 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+)
+
+type checkCmd struct {
+	*cobra.Command
+	*analyzerFlags
+	format string
+	failOn string
+}
+
+// failOnSeverities are the values of --fail-on, mapped to the least severe
+// diagnostic that makes the check fail.
+var failOnSeverities = map[string]protocol.DiagnosticSeverity{
+	"error":   protocol.DiagnosticSeverityError,
+	"warning": protocol.DiagnosticSeverityWarning,
+	"info":    protocol.DiagnosticSeverityInformation,
+	"hint":    protocol.DiagnosticSeverityHint,
+	"none":    0,
+}
+
+func newCheckCmd(baseCommandName string, builtinFSProvider BuiltinFSProvider, managerOpts ...document.ManagerOpt) *checkCmd {
+	cmd := checkCmd{
+		Command: &cobra.Command{
+			Use:   "check [paths...]",
+			Short: "Report the diagnostics of Starlark files",
+			Long: `Report the diagnostics of Starlark files, as they are shown in the editor.
+
+Paths can be files, directories or glob patterns. Directories are searched
+recursively for Tiltfiles, Bazel files and files with a .star, .starlark,
+.sky or .bzl extension. Without paths, the current directory is checked.
+
+Each file is checked with the configuration file of the nearest directory
+above it that has one.
+
+The command exits with status 1 if there are diagnostics at least as severe
+as --fail-on.`,
+			Example: fmt.Sprintf(`
+# Check all Starlark files below the current directory
+%[1]s check
+
+# Annotate the changes of a pull request in GitHub Actions
+%[1]s check --format=github-actions
+
+# Upload the results to a code scanning dashboard
+%[1]s check --format=sarif --fail-on=none > results.sarif`, baseCommandName),
+		},
+	}
+	cmd.analyzerFlags = newAnalyzerFlags(builtinFSProvider, managerOpts)
+	cmd.analyzerFlags.register(cmd.Flags())
+	cmd.Flags().StringVar(&cmd.format, "format", "text",
+		"Output format: "+strings.Join(reportFormatNames(), ", "))
+	cmd.Flags().StringVar(&cmd.failOn, "fail-on", "error",
+		"Least severe diagnostic that fails the check: error, warning, info, hint or none")
+
+	cmd.Command.RunE = func(cc *cobra.Command, args []string) error {
+		report, found := reportFormats[cmd.format]
+		if !found {
+			return fmt.Errorf("unknown format %q", cmd.format)
+		}
+		threshold, found := failOnSeverities[cmd.failOn]
+		if !found {
+			return fmt.Errorf("unknown severity %q", cmd.failOn)
+		}
+		cc.SilenceUsage = true
+
+		files, err := starlarkFiles(args)
+		if err != nil {
+			return err
+		}
+		results, err := newChecker(cmd.analyzerFlags).check(cc.Context(), files)
+		if err != nil {
+			return err
+		}
+		if err := report(cc.OutOrStdout(), results); err != nil {
+			return err
+		}
+
+		for _, r := range results {
+			for _, d := range r.Diagnostics {
+				if severity(d) <= threshold {
+					cc.SilenceErrors = true
+					return exitError{code: 1}
+				}
+			}
+		}
+		return nil
+	}
+
+	return &cmd
+}
+
+// fileDiagnostics are the diagnostics of a checked file.
+type fileDiagnostics struct {
+	// Path is the path of the file as it's reported, relative to the current
+	// directory if possible.
+	Path        string
+	Diagnostics []protocol.Diagnostic
+}
+
+// checker analyzes files with the analyzer for the configuration that
+// applies to them.
+type checker struct {
+	flags     *analyzerFlags
+	docs      *document.Manager
+	analyzers map[string]*analysis.Analyzer
+}
+
+func newChecker(flags *analyzerFlags) *checker {
+	return &checker{
+		flags:     flags,
+		docs:      document.NewDocumentManager(flags.documentManagerOpts()...),
+		analyzers: make(map[string]*analysis.Analyzer),
+	}
+}
+
+func (c *checker) check(ctx context.Context, files []string) ([]fileDiagnostics, error) {
+	results := make([]fileDiagnostics, 0, len(files))
+	for _, path := range files {
+		diags, err := c.diagnostics(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		results = append(results, fileDiagnostics{Path: displayPath(path), Diagnostics: diags})
+	}
+	return results, nil
+}
+
+func (c *checker) diagnostics(ctx context.Context, path string) ([]protocol.Diagnostic, error) {
+	doc, analyzer, err := c.open(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+	diags := analyzer.Diagnostics(doc)
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Range.Start, diags[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+	return diags, nil
+}
+
+// open reads a file and returns it with the analyzer for it. The document
+// must be closed.
+func (c *checker) open(ctx context.Context, path string) (document.Document, *analysis.Analyzer, error) {
+	analyzer, err := c.analyzer(ctx, filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	u := uri.File(path)
+	doc, err := c.docs.Read(ctx, u)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, analyzer.ForDocument(u, ""), nil
+}
+
+// analyzer returns the analyzer for files in the directory, configured by
+// the nearest configuration file above it.
+func (c *checker) analyzer(ctx context.Context, dir string) (*analysis.Analyzer, error) {
+	root := configRoot(dir)
+	if analyzer, found := c.analyzers[root]; found {
+		return analyzer, nil
+	}
+
+	cfg := &config.Config{}
+	if root != "" {
+		var err error
+		if cfg, err = config.Load(root); err != nil {
+			return nil, err
+		}
+		c.docs.AddRoot(uri.File(root))
+		c.docs.SetLoadPaths(ctx, uri.File(root), cfg.DocumentLoadPaths())
+	}
+	analyzer, err := c.flags.createAnalyzer(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create analyzer: %v", err)
+	}
+	c.analyzers[root] = analyzer
+	return analyzer, nil
+}
+
+// configRoot returns the nearest directory at or above dir with a
+// configuration file, or an empty string if there is none.
+func configRoot(dir string) string {
+	for {
+		if config.Find(dir) != "" {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// starlarkFiles expands the paths given on the command line to the absolute
+// paths of the files to check or format, in order and without duplicates.
+func starlarkFiles(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) error {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		if !seen[abs] {
+			seen[abs] = true
+			files = append(files, abs)
+		}
+		return nil
+	}
+
+	for _, arg := range args {
+		paths := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+			paths = matches
+		}
+
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				if err := add(path); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			var found []string
+			err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					if p != path && strings.HasPrefix(d.Name(), ".") {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if isStarlarkFile(d.Name()) {
+					found = append(found, p)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(found)
+			for _, p := range found {
+				if err := add(p); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// displayPath returns the path relative to the current directory, if it's
+// below it.
+func displayPath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// isStarlarkFile reports whether a file found in a directory is checked.
+func isStarlarkFile(name string) bool {
+	switch name {
+	case "Tiltfile", "BUILD", "BUILD.bazel", "WORKSPACE", "WORKSPACE.bazel":
+		return true
+	}
+	switch filepath.Ext(name) {
+	case ".star", ".starlark", ".sky", ".bzl":
+		return true
+	}
+	return false
+}
+
+// severity returns the severity of the diagnostic, treating a missing one
+// as an error.
+func severity(d protocol.Diagnostic) protocol.DiagnosticSeverity {
+	if d.Severity == 0 {
+		return protocol.DiagnosticSeverityError
+	}
+	return d.Severity
+}
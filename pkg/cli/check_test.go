@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chdirTemp changes to a new temporary directory with the files for the
+// duration of the test.
+func chdirTemp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	dir := t.TempDir()
+	require.NoError(t, os.Chdir(dir))
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+	}
+	return dir
+}
+
+// runCommand runs the command line with the builtins read from
+// "builtins.py", if it exists, and returns its output.
+func runCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := NewRootCmd("starlark-lsp", nil)
+	if _, err := os.Stat("builtins.py"); err == nil {
+		args = append(args, "--builtin-paths=builtins.py")
+	}
+	var out bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	err := cmd.ExecuteContext(context.Background())
+	return out.String(), err
+}
+
+var checkFiles = map[string]string{
+	"Tiltfile": `load("lib.star", "missing")
+load("nowhere.star", "x")
+`,
+	"lib.star": "x = 1\n",
+}
+
+func TestCheckFormats(t *testing.T) {
+	dir := chdirTemp(t, checkFiles)
+	missing := filepath.Join(dir, "nowhere.star")
+
+	out, err := runCommand(t, "check", "--fail-on=none")
+	require.NoError(t, err)
+	assert.Equal(t, `Tiltfile:1:18: warning: symbol 'missing' not found in lib.star (load-symbol-not-found)
+Tiltfile:2:1: error: cannot find "nowhere.star", tried: `+missing+` (load-failed)
+`, out)
+
+	out, err = runCommand(t, "check", "--fail-on=none", "--format=github-actions")
+	require.NoError(t, err)
+	assert.Equal(t, `::warning file=Tiltfile,line=1,col=18,endLine=1,endColumn=27,title=load-symbol-not-found::symbol 'missing' not found in lib.star
+::error file=Tiltfile,line=2,col=1,endLine=2,endColumn=26,title=load-failed::cannot find "nowhere.star", tried: `+missing+`
+`, out)
+
+	out, err = runCommand(t, "check", "--fail-on=none", "--format=json")
+	require.NoError(t, err)
+	var diags []jsonDiagnostic
+	require.NoError(t, json.Unmarshal([]byte(out), &diags))
+	assert.Equal(t, []jsonDiagnostic{
+		{File: "Tiltfile", Line: 1, Column: 18, EndLine: 1, EndColumn: 27, Severity: "warning", Code: "load-symbol-not-found", Message: "symbol 'missing' not found in lib.star"},
+		{File: "Tiltfile", Line: 2, Column: 1, EndLine: 2, EndColumn: 26, Severity: "error", Code: "load-failed", Message: `cannot find "nowhere.star", tried: ` + missing},
+	}, diags)
+
+	out, err = runCommand(t, "check", "--fail-on=none", "--format=sarif")
+	require.NoError(t, err)
+	var log sarifLog
+	require.NoError(t, json.Unmarshal([]byte(out), &log))
+	require.Len(t, log.Runs, 1)
+	assert.Equal(t, []sarifRule{{ID: "load-failed"}, {ID: "load-symbol-not-found"}}, log.Runs[0].Tool.Driver.Rules)
+	require.Len(t, log.Runs[0].Results, 2)
+	assert.Equal(t, "load-failed", log.Runs[0].Results[1].RuleID)
+	assert.Equal(t, "error", log.Runs[0].Results[1].Level)
+	assert.Equal(t, sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: "Tiltfile"},
+		Region:           sarifRegion{StartLine: 2, StartColumn: 1, EndLine: 2, EndColumn: 26},
+	}, log.Runs[0].Results[1].Locations[0].PhysicalLocation)
+
+	out, err = runCommand(t, "check", "--fail-on=none", "--format=checkstyle")
+	require.NoError(t, err)
+	assert.Contains(t, out, `<file name="Tiltfile">`)
+	assert.Contains(t, out, `<error line="1" column="18" severity="warning" message="symbol &#39;missing&#39; not found in lib.star" source="load-symbol-not-found"></error>`)
+	assert.Contains(t, out, `<file name="lib.star"></file>`)
+
+	_, err = runCommand(t, "check", "--format=yaml")
+	assert.EqualError(t, err, `unknown format "yaml"`)
+}
+
+func TestCheckFailOn(t *testing.T) {
+	chdirTemp(t, checkFiles)
+
+	_, err := runCommand(t, "check", "lib.star")
+	assert.NoError(t, err)
+	_, err = runCommand(t, "check")
+	assert.Equal(t, exitError{code: 1}, err)
+	_, err = runCommand(t, "check", "--fail-on=none")
+	assert.NoError(t, err)
+
+	require.NoError(t, os.WriteFile("Tiltfile", []byte(`load("lib.star", "missing")`), 0644))
+	_, err = runCommand(t, "check")
+	assert.NoError(t, err)
+	_, err = runCommand(t, "check", "--fail-on=warning")
+	assert.Equal(t, exitError{code: 1}, err)
+}
+
+func TestCheckConfiguration(t *testing.T) {
+	chdirTemp(t, map[string]string{
+		"builtins.py":                   "def timeout(seconds: float):\n  pass\n",
+		"Tiltfile":                      "timeout('1s')\n",
+		"typed/.starlark-lsp.yaml":      "dialect:\n  typeCheck: true\n",
+		"typed/Tiltfile":                "timeout('1s')\n",
+		"typed/quiet/.starlark-lsp.yml": "dialect:\n  typeCheck: true\ndiagnostics:\n  disabled: [type-mismatch]\n",
+		"typed/quiet/Tiltfile":          "timeout('1s')\n",
+	})
+
+	out, err := runCommand(t, "check", "--fail-on=none")
+	require.NoError(t, err)
+	assert.Equal(t, "typed/Tiltfile:1:9: warning: argument 'seconds' of timeout() should be float, not String (type-mismatch)\n", out)
+}
+
+func TestCheckedFiles(t *testing.T) {
+	dir := chdirTemp(t, map[string]string{
+		"Tiltfile":          "",
+		"README.md":         "",
+		"lib/k8s.star":      "",
+		"lib/rules.bzl":     "",
+		"lib/BUILD.bazel":   "",
+		"lib/notes.txt":     "",
+		".git/hooks.star":   "",
+		"other/Tiltfile.ci": "",
+	})
+
+	files, err := starlarkFiles(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "Tiltfile"),
+		filepath.Join(dir, "lib/BUILD.bazel"),
+		filepath.Join(dir, "lib/k8s.star"),
+		filepath.Join(dir, "lib/rules.bzl"),
+	}, files)
+
+	// explicit files are checked whatever their name, and only once
+	files, err = starlarkFiles([]string{"other/Tiltfile.ci", "lib/*.star", "lib"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "other/Tiltfile.ci"),
+		filepath.Join(dir, "lib/k8s.star"),
+		filepath.Join(dir, "lib/BUILD.bazel"),
+		filepath.Join(dir, "lib/rules.bzl"),
+	}, files)
+
+	_, err = starlarkFiles([]string{"*.py"})
+	assert.EqualError(t, err, `no files match "*.py"`)
+}
@@ -2,9 +2,12 @@ package cli
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"go.lsp.dev/protocol"
@@ -48,6 +51,12 @@ func NewRootCmd(commandName string, builtinFSProvider BuiltinFSProvider, manager
 	}
 
 	cmd.AddCommand(newStartCmd(commandName, builtinFSProvider, managerOpts...).Command)
+	cmd.AddCommand(newCheckCmd(commandName, builtinFSProvider, managerOpts...).Command)
+	cmd.AddCommand(newFormatCmd(commandName).Command)
+	cmd.AddCommand(newDocsCmd(commandName, builtinFSProvider).Command)
+	cmd.AddCommand(newQueryCmd(commandName, builtinFSProvider, managerOpts...).Command)
+	cmd.AddCommand(newBuiltinsCmd(commandName))
+	cmd.AddCommand(newReplayCmd(commandName, builtinFSProvider, managerOpts...).Command)
 
 	return &cmd
 }
@@ -63,6 +72,11 @@ func Execute() {
 
 	err := NewRootCmd("starlark-lsp", nil).ExecuteContext(ctx)
 	if err != nil {
+		var exit exitError
+		if errors.As(err, &exit) {
+			cleanup()
+			os.Exit(exit.code)
+		}
 		if !isCobraError(err) {
 			logger.Error("fatal error", zap.Error(err))
 		}
@@ -70,20 +84,30 @@ func Execute() {
 	}
 }
 
+// setupSignalHandler cancels the context on SIGINT or SIGTERM, which stops
+// the server gracefully, giving the requests being handled a grace period
+// to finish. A second signal exits right away.
 func setupSignalHandler(cancel context.CancelFunc) {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		for sig := range c {
-			if sig == os.Interrupt {
-				// TODO(milas): give open conns a grace period to close gracefully
-				cancel()
-				os.Exit(0)
-			}
-		}
+		<-c
+		cancel()
+		<-c
+		os.Exit(1)
 	}()
 }
 
+// exitError makes a command exit with a status code, without reporting an
+// error, e.g. when a check found problems.
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 func isCobraError(err error) bool {
 	// Cobra doesn't give us a good way to distinguish between Cobra errors
 	// (e.g. invalid command/args) and app errors, so ignore them manually
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/docs"
+)
+
+type docsCmd struct {
+	*cobra.Command
+	builtinFSProvider BuiltinFSProvider
+	builtinPaths      []string
+	starlark          bool
+	out               string
+	title             string
+	html              bool
+}
+
+func newDocsCmd(baseCommandName string, builtinFSProvider BuiltinFSProvider) *docsCmd {
+	cmd := docsCmd{
+		Command: &cobra.Command{
+			Use:   "docs [paths...]",
+			Short: "Generate reference documentation of builtins and modules",
+			Long: `Generate reference documentation of builtins and Starlark modules.
+
+A Markdown page is written for the builtins, for each of their submodules and
+for each Starlark file given as a path, along with an index page that lists
+them. Paths can be files, directories or glob patterns, as for the check
+command. Functions, types and variables whose names start with an underscore
+are left out of the pages of Starlark files.
+
+Names of documented symbols quoted in docstrings, like ` + "`local_resource`" + `, and
+type hints are linked to their documentation.`,
+			Example: fmt.Sprintf(`
+# Document builtin stubs and a library of macros
+%[1]s docs --builtin-paths=stubs --out=docs/api lib/
+
+# Also write HTML pages
+%[1]s docs --builtin-paths=stubs --out=site --html`, baseCommandName),
+		},
+		builtinFSProvider: builtinFSProvider,
+	}
+	if builtinFSProvider == nil {
+		cmd.Flags().StringArrayVar(&cmd.builtinPaths, "builtin-paths", nil,
+			"Paths to files and directories to parse and document as builtins")
+	}
+	cmd.Flags().BoolVar(&cmd.starlark, "starlark", false, "Also document the builtins of the Starlark language")
+	cmd.Flags().StringVarP(&cmd.out, "out", "o", "docs", "Directory to write the pages to")
+	cmd.Flags().StringVar(&cmd.title, "title", "API reference", "Title of the index page")
+	cmd.Flags().BoolVar(&cmd.html, "html", false, "Also write HTML pages")
+
+	cmd.Command.RunE = func(cc *cobra.Command, args []string) error {
+		cc.SilenceUsage = true
+		modules, err := cmd.modules(cc.Context(), args)
+		if err != nil {
+			return err
+		}
+		if len(modules) == 0 {
+			return fmt.Errorf("nothing to document")
+		}
+
+		formats := []docs.Format{docs.Markdown}
+		if cmd.html {
+			formats = append(formats, docs.HTML)
+		}
+		if err := os.MkdirAll(cmd.out, 0755); err != nil {
+			return err
+		}
+		for _, format := range formats {
+			for _, page := range docs.Render(cmd.title, modules, format) {
+				if err := os.WriteFile(filepath.Join(cmd.out, page.Path), page.Content, 0644); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	return &cmd
+}
+
+// modules returns the modules of the builtins, followed by those of the
+// Starlark files.
+func (c *docsCmd) modules(ctx context.Context, args []string) ([]docs.Module, error) {
+	builtins := analysis.NewBuiltins()
+	if c.starlark {
+		b, err := analysis.LoadBuiltinsFromSource(ctx, analysis.StarlarkBuiltins, "builtins.py")
+		if err != nil {
+			return nil, err
+		}
+		builtins.Update(b)
+	}
+	if c.builtinFSProvider != nil {
+		b, err := analysis.LoadBuiltinsFromFS(ctx, c.builtinFSProvider())
+		if err != nil {
+			return nil, err
+		}
+		builtins.Update(b)
+	}
+	for _, path := range c.builtinPaths {
+		b, err := analysis.LoadBuiltins(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		builtins.Update(b)
+	}
+	modules := docs.BuiltinModules("builtins", builtins)
+
+	if len(args) == 0 {
+		return modules, nil
+	}
+	files, err := starlarkFiles(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		m, err := docs.LoadModule(ctx, path, filepath.ToSlash(displayPath(path)))
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+	return modules, nil
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocs(t *testing.T) {
+	chdirTemp(t, map[string]string{
+		"builtins.py":  "def local_resource(name: str):\n    \"\"\"Runs a command.\"\"\"\n    pass\n",
+		"lib/k8s.star": "def deploy(name):\n    \"\"\"Deploys with `local_resource`.\"\"\"\n    pass\n",
+	})
+
+	_, err := runCommand(t, "docs", "--out=out", "--html", "lib")
+	require.NoError(t, err)
+	var pages []string
+	require.NoError(t, filepath.Walk("out", func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			pages = append(pages, filepath.ToSlash(path))
+		}
+		return err
+	}))
+	assert.Equal(t, []string{
+		"out/builtins.html",
+		"out/builtins.md",
+		"out/index.html",
+		"out/index.md",
+		"out/lib.k8s.star.html",
+		"out/lib.k8s.star.md",
+	}, pages)
+
+	content, err := os.ReadFile("out/lib.k8s.star.md")
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Deploys with [`local_resource`](builtins.md#local_resource).")
+}
+
+func TestDocsNothingToDocument(t *testing.T) {
+	chdirTemp(t, nil)
+
+	_, err := runCommand(t, "docs", "--out=out")
+	assert.EqualError(t, err, "nothing to document")
+}
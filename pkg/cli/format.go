@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+	"github.com/tilt-dev/starlark-lsp/pkg/format"
+)
+
+type formatCmd struct {
+	*cobra.Command
+	write bool
+	check bool
+	diff  bool
+}
+
+func newFormatCmd(baseCommandName string) *formatCmd {
+	cmd := formatCmd{
+		Command: &cobra.Command{
+			Use:   "format [--write|--check|--diff] [paths...]",
+			Short: "Format Starlark files",
+			Long: `Format Starlark files the way the editor formats them.
+
+Paths can be files, directories or glob patterns, as for the check command.
+Without paths, the files below the current directory are formatted.
+
+Each file is formatted with the indentation and quotes of the configuration
+file of the nearest directory above it that has one.
+
+By default, the formatted files are printed. With --write, they are written
+back instead. With --check, the files that would change are listed and the
+command exits with status 1 if there are any. With --diff, the changes are
+printed as unified diffs; combined with --check, the command also exits with
+status 1 if there are changes.`,
+			Example: fmt.Sprintf(`
+# Format all Starlark files below the current directory
+%[1]s format --write
+
+# Fail a pre-commit hook if a Tiltfile isn't formatted
+%[1]s format --check Tiltfile
+
+# Review the changes before writing them
+%[1]s format --diff lib/`, baseCommandName),
+		},
+	}
+	cmd.Flags().BoolVarP(&cmd.write, "write", "w", false, "Write the formatted files back")
+	cmd.Flags().BoolVar(&cmd.check, "check", false, "List the files that would change and exit with status 1 if there are any")
+	cmd.Flags().BoolVar(&cmd.diff, "diff", false, "Print the changes as unified diffs")
+
+	cmd.Command.RunE = func(cc *cobra.Command, args []string) error {
+		if cmd.write && (cmd.check || cmd.diff) {
+			return fmt.Errorf("--write can't be combined with --check or --diff")
+		}
+		cc.SilenceUsage = true
+
+		files, err := starlarkFiles(args)
+		if err != nil {
+			return err
+		}
+		f := formatter{options: make(map[string]format.Options)}
+		changed := false
+		for _, path := range files {
+			input, formatted, err := f.format(cc.Context(), path)
+			if err != nil {
+				return fmt.Errorf("%s: %v", displayPath(path), err)
+			}
+			if !cmd.write && !cmd.check && !cmd.diff {
+				if _, err := cc.OutOrStdout().Write(formatted); err != nil {
+					return err
+				}
+				continue
+			}
+			if bytes.Equal(input, formatted) {
+				continue
+			}
+			changed = true
+			if err := cmd.output(cc.OutOrStdout(), path, input, formatted); err != nil {
+				return err
+			}
+		}
+
+		if changed && cmd.check {
+			cc.SilenceErrors = true
+			return exitError{code: 1}
+		}
+		return nil
+	}
+
+	return &cmd
+}
+
+// output writes, diffs or lists a file that is changed by formatting.
+func (c *formatCmd) output(w io.Writer, path string, input, formatted []byte) error {
+	switch {
+	case c.write:
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, formatted, info.Mode())
+	case c.diff:
+		name := filepath.ToSlash(displayPath(path))
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        lines(input),
+			B:        lines(formatted),
+			FromFile: "a/" + name,
+			ToFile:   "b/" + name,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, diff)
+		return err
+	default:
+		_, err := fmt.Fprintln(w, displayPath(path))
+		return err
+	}
+}
+
+// lines splits the contents of a file into lines that keep their newlines,
+// marking a last line without one as diff does.
+func lines(content []byte) []string {
+	result := strings.SplitAfter(string(content), "\n")
+	last := len(result) - 1
+	if result[last] == "" {
+		return result[:last]
+	}
+	result[last] += "\n\\ No newline at end of file\n"
+	return result
+}
+
+// formatter formats files with the options of the configuration that
+// applies to them.
+type formatter struct {
+	// options are the format options by configuration root.
+	options map[string]format.Options
+}
+
+// format returns the contents of the file and its formatted contents.
+func (f *formatter) format(ctx context.Context, path string) ([]byte, []byte, error) {
+	opts, err := f.formatOptions(filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	formatted, err := format.Source(ctx, input, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return input, formatted, nil
+}
+
+// formatOptions returns the options for files in the directory, configured
+// by the nearest configuration file above it.
+func (f *formatter) formatOptions(dir string) (format.Options, error) {
+	root := configRoot(dir)
+	if opts, found := f.options[root]; found {
+		return opts, nil
+	}
+	var opts format.Options
+	if root != "" {
+		cfg, err := config.Load(root)
+		if err != nil {
+			return format.Options{}, err
+		}
+		opts = cfg.FormatOptions()
+	}
+	f.options[root] = opts
+	return opts, nil
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var formatFiles = map[string]string{
+	"Tiltfile":               "load('lib.star','x')\nif x :\n  print( x )\n",
+	"lib.star":               "x = 1\n",
+	"lib/BUILD":              "print(\"double\")\n",
+	"lib/.starlark-lsp.yaml": "format:\n  indentWidth: 2\n  quote: double\n",
+	"lib/rules.bzl":          "def f():\n    return 'single'\n",
+}
+
+func TestFormat(t *testing.T) {
+	chdirTemp(t, formatFiles)
+
+	out, err := runCommand(t, "format", "Tiltfile", "lib/rules.bzl")
+	require.NoError(t, err)
+	assert.Equal(t, "load('lib.star', 'x')\nif x:\n    print(x)\n"+"def f():\n  return \"single\"\n", out)
+
+	out, err = runCommand(t, "format", "--check")
+	assert.Equal(t, exitError{code: 1}, err)
+	assert.Equal(t, "Tiltfile\nlib/rules.bzl\n", out)
+
+	out, err = runCommand(t, "format", "--diff", "Tiltfile")
+	require.NoError(t, err)
+	assert.Equal(t, `--- a/Tiltfile
++++ b/Tiltfile
+@@ -1,3 +1,3 @@
+-load('lib.star','x')
+-if x :
+-  print( x )
++load('lib.star', 'x')
++if x:
++    print(x)
+`, out)
+
+	_, err = runCommand(t, "format", "--check", "--diff", "Tiltfile")
+	assert.Equal(t, exitError{code: 1}, err)
+
+	require.NoError(t, os.WriteFile("lib.star", []byte("x = 1"), 0644))
+	out, err = runCommand(t, "format", "--diff", "lib.star")
+	require.NoError(t, err)
+	assert.Equal(t, `--- a/lib.star
++++ b/lib.star
+@@ -1 +1 @@
+-x = 1
+\ No newline at end of file
++x = 1
+`, out)
+
+	out, err = runCommand(t, "format", "--write")
+	require.NoError(t, err)
+	assert.Empty(t, out)
+	content, err := os.ReadFile("lib/rules.bzl")
+	require.NoError(t, err)
+	assert.Equal(t, "def f():\n  return \"single\"\n", string(content))
+
+	// formatting is idempotent
+	out, err = runCommand(t, "format", "--check", "--diff")
+	require.NoError(t, err)
+	assert.Empty(t, out)
+
+	_, err = runCommand(t, "format", "--write", "--check")
+	assert.EqualError(t, err, "--write can't be combined with --check or --diff")
+}
+
+func TestFormatSyntaxError(t *testing.T) {
+	chdirTemp(t, map[string]string{"Tiltfile": "def f(:\n"})
+
+	_, err := runCommand(t, "format", "--check")
+	assert.EqualError(t, err, "Tiltfile: syntax error at line 1, column 7")
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package cli
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processExists reports whether a process with the pid is running.
+func processExists(pid int) bool {
+	// signal 0 only checks whether the process can be signaled; a process
+	// of another user can't, but exists
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+)
+
+type queryCmd struct {
+	*cobra.Command
+	*analyzerFlags
+	format string
+}
+
+// queryFunc answers a query about a document, with the result to print as
+// JSON and a function that prints it as text.
+type queryFunc func(ctx context.Context, doc document.Document, analyzer *analysis.Analyzer, pos protocol.Position) (interface{}, func(w io.Writer))
+
+func newQueryCmd(baseCommandName string, builtinFSProvider BuiltinFSProvider, managerOpts ...document.ManagerOpt) *queryCmd {
+	cmd := queryCmd{
+		Command: &cobra.Command{
+			Use:   "query",
+			Short: "Query the analysis of a file, as an editor would",
+			Long: `Query the analysis of a file without an editor, e.g. to script it or to
+reproduce an issue of the language server.
+
+Positions are given as FILE:LINE:COL, with lines and columns starting at 1.
+The file is analyzed with the configuration file of the nearest directory
+above it that has one.`,
+			Example: fmt.Sprintf(`
+# Show the documentation of the symbol at line 3, column 5 of the Tiltfile
+%[1]s query hover Tiltfile:3:5
+
+# List the completions at the end of line 10 as JSON
+%[1]s query complete --format=json Tiltfile:10:12`, baseCommandName),
+		},
+	}
+	cmd.analyzerFlags = newAnalyzerFlags(builtinFSProvider, managerOpts)
+	cmd.analyzerFlags.register(cmd.PersistentFlags())
+	cmd.PersistentFlags().StringVar(&cmd.format, "format", "text", "Output format: text or json")
+
+	cmd.AddCommand(
+		cmd.subcommand("hover FILE:LINE:COL", "Show the documentation of the symbol at a position", true, hoverQuery),
+		cmd.subcommand("definition FILE:LINE:COL", "Show where the symbol at a position is defined", true, definitionQuery),
+		cmd.subcommand("complete FILE:LINE:COL", "List the completions at a position", true, completeQuery),
+		cmd.subcommand("symbols FILE", "List the symbols defined in a file", false, symbolsQuery),
+	)
+	return &cmd
+}
+
+// subcommand creates the command of a query, which takes a position if
+// withPosition is set and a file otherwise.
+func (c *queryCmd) subcommand(use string, short string, withPosition bool, query queryFunc) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cc *cobra.Command, args []string) error {
+			if c.format != "text" && c.format != "json" {
+				return fmt.Errorf("unknown format %q", c.format)
+			}
+			path, pos := args[0], protocol.Position{}
+			if withPosition {
+				var err error
+				if path, pos, err = parsePosition(args[0]); err != nil {
+					return err
+				}
+			}
+			cc.SilenceUsage = true
+
+			path, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			doc, analyzer, err := newChecker(c.analyzerFlags).open(cc.Context(), path)
+			if err != nil {
+				return err
+			}
+			defer doc.Close()
+
+			result, printText := query(cc.Context(), doc, analyzer, pos)
+			if c.format == "json" {
+				out, err := json.MarshalIndent(result, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(cc.OutOrStdout(), string(out))
+				return err
+			}
+			printText(cc.OutOrStdout())
+			return nil
+		},
+	}
+}
+
+// parsePosition parses a FILE:LINE:COL argument into the file and the
+// zero-based position.
+func parsePosition(arg string) (string, protocol.Position, error) {
+	invalid := fmt.Errorf("invalid position %q, expected FILE:LINE:COL", arg)
+	rest, col, found := cutLast(arg, ":")
+	if !found {
+		return "", protocol.Position{}, invalid
+	}
+	path, line, found := cutLast(rest, ":")
+	if !found || path == "" {
+		return "", protocol.Position{}, invalid
+	}
+	l, err := strconv.ParseUint(line, 10, 32)
+	if err != nil || l == 0 {
+		return "", protocol.Position{}, invalid
+	}
+	c, err := strconv.ParseUint(col, 10, 32)
+	if err != nil || c == 0 {
+		return "", protocol.Position{}, invalid
+	}
+	return path, protocol.Position{Line: uint32(l - 1), Character: uint32(c - 1)}, nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+func hoverQuery(ctx context.Context, doc document.Document, analyzer *analysis.Analyzer, pos protocol.Position) (interface{}, func(w io.Writer)) {
+	hover := analyzer.Hover(ctx, doc, pos)
+	return hover, func(w io.Writer) {
+		if hover != nil {
+			_, _ = fmt.Fprintln(w, hover.Contents.Value)
+		}
+	}
+}
+
+func definitionQuery(ctx context.Context, doc document.Document, analyzer *analysis.Analyzer, pos protocol.Position) (interface{}, func(w io.Writer)) {
+	locations := analyzer.Definition(ctx, doc, pos)
+	if locations == nil {
+		locations = []protocol.Location{}
+	}
+	return locations, func(w io.Writer) {
+		for _, loc := range locations {
+			_, _ = fmt.Fprintf(w, "%s:%d:%d\n", locationPath(loc.URI), loc.Range.Start.Line+1, loc.Range.Start.Character+1)
+		}
+	}
+}
+
+func completeQuery(ctx context.Context, doc document.Document, analyzer *analysis.Analyzer, pos protocol.Position) (interface{}, func(w io.Writer)) {
+	completions := analyzer.Completion(ctx, doc, pos)
+	return completions, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, item := range completions.Items {
+			kind := ""
+			if item.Kind != 0 {
+				kind = item.Kind.String()
+			}
+			_, _ = fmt.Fprintln(tw, strings.TrimRight(item.Label+"\t"+kind+"\t"+firstLine(item.Detail), "\t"))
+		}
+		_ = tw.Flush()
+	}
+}
+
+func symbolsQuery(ctx context.Context, doc document.Document, analyzer *analysis.Analyzer, pos protocol.Position) (interface{}, func(w io.Writer)) {
+	symbols := make([]protocol.DocumentSymbol, len(doc.Symbols()))
+	for i, sym := range doc.Symbols() {
+		symbols[i] = sym.DocumentSymbol()
+	}
+	return symbols, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		var print func(symbols []protocol.DocumentSymbol, depth int)
+		print = func(symbols []protocol.DocumentSymbol, depth int) {
+			for _, sym := range symbols {
+				_, _ = fmt.Fprintf(tw, "%s%s\t%s\t%d:%d\n", strings.Repeat("  ", depth), sym.Name, sym.Kind,
+					sym.Range.Start.Line+1, sym.Range.Start.Character+1)
+				print(sym.Children, depth+1)
+			}
+		}
+		print(symbols, 0)
+		_ = tw.Flush()
+	}
+}
+
+// locationPath returns the path of a file location as it's reported, or
+// the URI of other locations.
+func locationPath(u protocol.DocumentURI) string {
+	if !strings.HasPrefix(string(u), "file:") {
+		return string(u)
+	}
+	return displayPath(u.Filename())
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/protocol"
+)
+
+var queryFiles = map[string]string{
+	"builtins.py": "def timeout(seconds: float):\n    \"\"\"Sets the timeout.\"\"\"\n    pass\n",
+	"Tiltfile":    "load(\"lib.star\", \"helper\")\nx = helper(1)\ntimeout(x)\ntime\n",
+	"lib.star":    "def helper(n):\n    return n\n",
+}
+
+func TestQueryHover(t *testing.T) {
+	chdirTemp(t, queryFiles)
+
+	out, err := runCommand(t, "query", "hover", "Tiltfile:3:2")
+	require.NoError(t, err)
+	assert.Contains(t, out, "def timeout(seconds: float)")
+	assert.Contains(t, out, "Sets the timeout.")
+
+	out, err = runCommand(t, "query", "hover", "Tiltfile:4:10")
+	require.NoError(t, err)
+	assert.Empty(t, out)
+}
+
+func TestQueryDefinition(t *testing.T) {
+	chdirTemp(t, queryFiles)
+
+	out, err := runCommand(t, "query", "definition", "Tiltfile:2:6")
+	require.NoError(t, err)
+	assert.Equal(t, "lib.star:1:1\n", out)
+
+	out, err = runCommand(t, "query", "definition", "--format=json", "Tiltfile:3:9")
+	require.NoError(t, err)
+	var locations []protocol.Location
+	require.NoError(t, json.Unmarshal([]byte(out), &locations))
+	require.Len(t, locations, 1)
+	assert.Equal(t, protocol.Position{Line: 1, Character: 0}, locations[0].Range.Start)
+}
+
+func TestQueryComplete(t *testing.T) {
+	chdirTemp(t, queryFiles)
+
+	out, err := runCommand(t, "query", "complete", "Tiltfile:4:5")
+	require.NoError(t, err)
+	assert.Equal(t, "timeout  Function  Sets the timeout.\n", out)
+
+	out, err = runCommand(t, "query", "complete", "--format=json", "Tiltfile:4:5")
+	require.NoError(t, err)
+	var completions protocol.CompletionList
+	require.NoError(t, json.Unmarshal([]byte(out), &completions))
+	require.Len(t, completions.Items, 1)
+	assert.Equal(t, "timeout", completions.Items[0].Label)
+}
+
+func TestQuerySymbols(t *testing.T) {
+	chdirTemp(t, queryFiles)
+
+	out, err := runCommand(t, "query", "symbols", "lib.star")
+	require.NoError(t, err)
+	assert.Equal(t, "helper  Function  1:1\n", out)
+}
+
+func TestQueryErrors(t *testing.T) {
+	chdirTemp(t, queryFiles)
+
+	for _, arg := range []string{"Tiltfile", "Tiltfile:1", "Tiltfile:0:1", "Tiltfile:x:1", ":1:1"} {
+		_, err := runCommand(t, "query", "hover", arg)
+		assert.EqualError(t, err, `invalid position "`+arg+`", expected FILE:LINE:COL`)
+	}
+	_, err := runCommand(t, "query", "symbols", "--format=yaml", "Tiltfile")
+	assert.EqualError(t, err, `unknown format "yaml"`)
+	_, err = runCommand(t, "query", "symbols", "missing.star")
+	assert.Error(t, err)
+}
@@ -0,0 +1,417 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/middleware"
+	"github.com/tilt-dev/starlark-lsp/pkg/server"
+)
+
+type replayCmd struct {
+	*cobra.Command
+	*analyzerFlags
+	timeout time.Duration
+}
+
+func newReplayCmd(baseCommandName string, builtinFSProvider BuiltinFSProvider, managerOpts ...document.ManagerOpt) *replayCmd {
+	cmd := replayCmd{
+		Command: &cobra.Command{
+			Use:   "replay TRACE",
+			Short: "Replay a trace of a session and compare the responses",
+			Long: `Replay the messages of the client in a trace recorded with the --trace-file
+option of the start command against a fresh server, and compare the
+responses and notifications of the server with the recorded ones.
+
+Each session of the trace is replayed in turn. The requests and
+notifications of the client are sent in order, each once the response to
+the previous request has been received, and the requests of the server are
+replied to with the recorded replies of the client; the timing of the
+messages isn't reproduced. The notifications of the server, like published
+diagnostics, are compared in the order they were sent once the session
+ends. Messages that differ are shown as diffs of their JSON, and the command
+exits with status 1 if there are any, so that traces can serve as
+regression tests.
+
+The server is configured by the flags as with the start command, which
+should match those of the recorded server.`,
+			Example: fmt.Sprintf(`
+# Record the sessions of an editor, then check that the server still
+# responds the same
+%[1]s start --trace-file=session.trace
+%[1]s replay session.trace`, baseCommandName),
+			Args: cobra.ExactArgs(1),
+		},
+	}
+	cmd.analyzerFlags = newAnalyzerFlags(builtinFSProvider, managerOpts)
+	cmd.analyzerFlags.register(cmd.Flags())
+	cmd.Flags().DurationVar(&cmd.timeout, "timeout", 10*time.Second,
+		"How long to wait for each response of the server")
+
+	cmd.Command.RunE = func(cc *cobra.Command, args []string) error {
+		cc.SilenceUsage = true
+		ctx := cc.Context()
+
+		sessions, err := readSessions(args[0])
+		if err != nil {
+			return err
+		}
+		analyzer, err := cmd.createAnalyzer(ctx, &config.Config{})
+		if err != nil {
+			return fmt.Errorf("failed to create analyzer: %v", err)
+		}
+		serverOpts := []server.ServerOpt{server.WithRootAnalyzerFunc(cmd.rootAnalyzerFunc())}
+
+		responses, notifications, differ := 0, 0, 0
+		for _, session := range sessions {
+			messages, err := cmd.replaySession(ctx, session, analyzer, serverOpts...)
+			if err != nil {
+				return fmt.Errorf("replaying session %d: %v", session[0].Session, err)
+			}
+			for _, r := range messages {
+				if r.notification {
+					notifications++
+				} else {
+					responses++
+				}
+				if bytes.Equal(r.recorded, r.replayed) {
+					continue
+				}
+				differ++
+				if err := r.printDiff(cc.OutOrStdout()); err != nil {
+					return err
+				}
+			}
+		}
+
+		_, err = fmt.Fprintf(cc.OutOrStdout(), "%d responses and %d notifications replayed, %d differ\n", responses, notifications, differ)
+		if err != nil {
+			return err
+		}
+		if differ > 0 {
+			cc.SilenceErrors = true
+			return exitError{code: 1}
+		}
+		return nil
+	}
+
+	return &cmd
+}
+
+// readSessions reads the records of a trace file by session, ordered by
+// session.
+func readSessions(path string) ([][]middleware.TraceRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	records, err := middleware.ReadTrace(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", path, err)
+	}
+
+	bySession := make(map[int][]middleware.TraceRecord)
+	for _, r := range records {
+		bySession[r.Session] = append(bySession[r.Session], r)
+	}
+	sessions := make([][]middleware.TraceRecord, 0, len(bySession))
+	for _, records := range bySession {
+		sessions = append(sessions, records)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i][0].Session < sessions[j][0].Session })
+	return sessions, nil
+}
+
+// replayedMessage is a response of the server to a request of the client,
+// or a notification of the server, as canonical JSON. A notification that
+// wasn't sent by either server is empty.
+type replayedMessage struct {
+	// name tells the message apart in diffs, e.g. "textDocument/hover (id
+	// #2)" or "textDocument/publishDiagnostics (notification 2)"
+	name         string
+	notification bool
+	recorded     []byte
+	replayed     []byte
+}
+
+func (r replayedMessage) printDiff(w io.Writer) error {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        lines(r.recorded),
+		B:        lines(r.replayed),
+		FromFile: "recorded " + r.name,
+		ToFile:   "replayed " + r.name,
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, diff)
+	return err
+}
+
+// replaySession sends the messages of the client of a session to a new
+// server, and returns its responses to the requests that have a recorded
+// response, followed by its notifications. The session ends with the exit
+// notification of the client, or with the trace.
+func (c *replayCmd) replaySession(ctx context.Context, records []middleware.TraceRecord, analyzer *analysis.Analyzer, serverOpts ...server.ServerOpt) ([]replayedMessage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	serverConn, clientConn := net.Pipe()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		_ = launchHandler(ctx, cancel, jsonrpc2.NewStream(serverConn), analyzer, c.documentManagerOpts(), serverOpts...)
+	}()
+	client := newReplayClient(ctx, jsonrpc2.NewStream(clientConn))
+	defer func() {
+		cancel()
+		<-served
+		_ = client.stream.Close()
+	}()
+
+	recorded := make(map[jsonrpc2.ID][]byte)
+	var notifications []*jsonrpc2.Notification
+	for _, r := range records {
+		if r.Direction != middleware.Outgoing {
+			continue
+		}
+		msg, err := jsonrpc2.DecodeMessage(r.Message)
+		if err != nil {
+			return nil, err
+		}
+		switch msg := msg.(type) {
+		case *jsonrpc2.Response:
+			recorded[msg.ID()] = r.Message
+		case *jsonrpc2.Notification:
+			notifications = append(notifications, msg)
+		}
+	}
+
+	var responses []replayedMessage
+	for _, r := range records {
+		if r.Direction != middleware.Incoming {
+			continue
+		}
+		msg, err := jsonrpc2.DecodeMessage(r.Message)
+		if err != nil {
+			return nil, err
+		}
+		if resp, ok := msg.(*jsonrpc2.Response); ok {
+			// the reply to a request of the server, which has to be sent
+			// before the reply can be
+			_, err := client.receive(ctx, c.timeout, func(m jsonrpc2.Message) bool {
+				call, ok := m.(*jsonrpc2.Call)
+				return ok && call.ID() == resp.ID()
+			})
+			if err != nil {
+				return nil, fmt.Errorf("waiting for request %q of the server: %v", resp.ID(), err)
+			}
+		}
+		if _, err := client.stream.Write(ctx, msg); err != nil {
+			return nil, err
+		}
+
+		switch msg := msg.(type) {
+		case *jsonrpc2.Call:
+			reply, err := client.receive(ctx, c.timeout, func(m jsonrpc2.Message) bool {
+				resp, ok := m.(*jsonrpc2.Response)
+				return ok && resp.ID() == msg.ID()
+			})
+			if err != nil {
+				return nil, fmt.Errorf("waiting for the response to %s (id %q): %v", msg.Method(), msg.ID(), err)
+			}
+			want, found := recorded[msg.ID()]
+			if !found {
+				// the trace ends before the response
+				continue
+			}
+			got, err := json.Marshal(reply)
+			if err != nil {
+				return nil, err
+			}
+			r := replayedMessage{name: fmt.Sprintf("%s (id %q)", msg.Method(), msg.ID())}
+			if r.recorded, err = canonicalJSON(want); err != nil {
+				return nil, err
+			}
+			if r.replayed, err = canonicalJSON(got); err != nil {
+				return nil, err
+			}
+			responses = append(responses, r)
+		case *jsonrpc2.Notification:
+			if msg.Method() == protocol.MethodExit {
+				return c.compareNotifications(ctx, client, responses, notifications)
+			}
+		}
+	}
+	return c.compareNotifications(ctx, client, responses, notifications)
+}
+
+// compareNotifications appends the notifications of the server to the
+// messages, paired in order with the recorded ones. Notifications missing
+// from either side are paired with an empty one.
+func (c *replayCmd) compareNotifications(ctx context.Context, client *replayClient, messages []replayedMessage, recorded []*jsonrpc2.Notification) ([]replayedMessage, error) {
+	isNotification := func(m jsonrpc2.Message) bool {
+		_, ok := m.(*jsonrpc2.Notification)
+		return ok
+	}
+	var replayed []jsonrpc2.Message
+	for range recorded {
+		// the server is done once it stops sending or the connection ends
+		msg, err := client.receive(ctx, c.timeout, isNotification)
+		if err != nil {
+			break
+		}
+		replayed = append(replayed, msg)
+	}
+	replayed = append(replayed, client.take(isNotification)...)
+
+	for i := 0; i < len(recorded) || i < len(replayed); i++ {
+		var r replayedMessage
+		r.notification = true
+		var err error
+		if i < len(recorded) {
+			r.name = fmt.Sprintf("%s (notification %d)", recorded[i].Method(), i+1)
+			if r.recorded, err = marshalCanonical(recorded[i]); err != nil {
+				return nil, err
+			}
+		}
+		if i < len(replayed) {
+			if r.name == "" {
+				r.name = fmt.Sprintf("%s (notification %d)", replayed[i].(*jsonrpc2.Notification).Method(), i+1)
+			}
+			if r.replayed, err = marshalCanonical(replayed[i]); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, r)
+	}
+	return messages, nil
+}
+
+// marshalCanonical returns the canonical JSON of a message.
+func marshalCanonical(msg jsonrpc2.Message) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalJSON(data)
+}
+
+// canonicalJSON indents JSON with the keys of objects sorted, so that equal
+// values have equal JSON.
+func canonicalJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// replayClient sends the messages of a replayed client, and receives the
+// messages of the server as they arrive, so that the server is never
+// blocked on sending them.
+type replayClient struct {
+	stream jsonrpc2.Stream
+
+	mu sync.Mutex
+	// received are the messages of the server not received by the client
+	// yet, err the error that ended reading them
+	received []jsonrpc2.Message
+	err      error
+	arrived  chan struct{}
+}
+
+func newReplayClient(ctx context.Context, stream jsonrpc2.Stream) *replayClient {
+	c := &replayClient{stream: stream, arrived: make(chan struct{}, 1)}
+	go func() {
+		for {
+			msg, _, err := stream.Read(ctx)
+			c.mu.Lock()
+			if err != nil {
+				c.err = err
+			} else {
+				c.received = append(c.received, msg)
+			}
+			c.mu.Unlock()
+			select {
+			case c.arrived <- struct{}{}:
+			default:
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return c
+}
+
+// take returns the messages of the server received so far that match,
+// without waiting for more.
+func (c *replayClient) take(match func(jsonrpc2.Message) bool) []jsonrpc2.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var taken, rest []jsonrpc2.Message
+	for _, msg := range c.received {
+		if match(msg) {
+			taken = append(taken, msg)
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+	c.received = rest
+	return taken
+}
+
+// receive returns the first message of the server that matches, waiting at
+// most for the timeout for it to arrive.
+func (c *replayClient) receive(ctx context.Context, timeout time.Duration, match func(jsonrpc2.Message) bool) (jsonrpc2.Message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		for i, msg := range c.received {
+			if match(msg) {
+				c.received = append(c.received[:i], c.received[i+1:]...)
+				c.mu.Unlock()
+				return msg, nil
+			}
+		}
+		err := c.err
+		c.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-c.arrived:
+		case <-timer.C:
+			return nil, fmt.Errorf("timed out after %v", timeout)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
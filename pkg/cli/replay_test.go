@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/middleware"
+)
+
+// recordSession records the trace of a session that opens a document and
+// hovers over a function.
+func recordSession(t *testing.T) []byte {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = protocol.WithLogger(ctx, zaptest.NewLogger(t))
+	analyzer, err := analysis.NewAnalyzer(ctx)
+	require.NoError(t, err)
+
+	var trace bytes.Buffer
+	serverConn, clientConn := net.Pipe()
+	serverCtx, stop := context.WithCancel(withTracer(ctx, middleware.NewTracer(&trace)))
+	served := make(chan error, 1)
+	go func() {
+		served <- launchHandler(serverCtx, stop, jsonrpc2.NewStream(serverConn), analyzer, nil)
+	}()
+
+	conn := jsonrpc2.NewConn(jsonrpc2.NewStream(clientConn))
+	conn.Go(ctx, jsonrpc2.MethodNotFoundHandler)
+	defer func() {
+		_ = conn.Close()
+	}()
+	doc := uri.File("Tiltfile")
+	_, err = conn.Call(ctx, protocol.MethodInitialize, protocol.InitializeParams{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.Notify(ctx, protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: doc, Text: "def foo():\n    \"\"\"Does foo.\"\"\"\n    pass\n"},
+	}))
+	var hover protocol.Hover
+	_, err = conn.Call(ctx, protocol.MethodTextDocumentHover, protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: doc},
+			Position:     protocol.Position{Line: 0, Character: 5},
+		},
+	}, &hover)
+	require.NoError(t, err)
+	require.Contains(t, hover.Contents.Value, "Does foo.")
+	_, err = conn.Call(ctx, protocol.MethodShutdown, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.Notify(ctx, protocol.MethodExit, nil))
+	require.NoError(t, <-served)
+
+	return trace.Bytes()
+}
+
+func TestReplay(t *testing.T) {
+	trace := recordSession(t)
+	chdirTemp(t, map[string]string{"session.trace": string(trace)})
+
+	out, err := runCommand(t, "replay", "session.trace")
+	require.NoError(t, err)
+	assert.Equal(t, "3 responses and 2 notifications replayed, 0 differ\n", out)
+
+	// a recorded response that differs from the replayed one is reported
+	var changed []string
+	for _, line := range strings.SplitAfter(string(trace), "\n") {
+		if strings.Contains(line, `"direction":"out"`) && strings.Contains(line, "Does foo.") {
+			line = strings.ReplaceAll(line, "Does foo.", "Does bar.")
+		}
+		changed = append(changed, line)
+	}
+	require.NoError(t, os.WriteFile("session.trace", []byte(strings.Join(changed, "")), 0644))
+
+	out, err = runCommand(t, "replay", "session.trace")
+	assert.Equal(t, exitError{code: 1}, err)
+	assert.Contains(t, out, "--- recorded textDocument/hover (id #2)\n+++ replayed textDocument/hover (id #2)\n")
+	assert.Contains(t, out, "-      \"value\": \"```python\\ndef foo()\\n```\\n\\nDoes bar.\"\n")
+	assert.Contains(t, out, "+      \"value\": \"```python\\ndef foo()\\n```\\n\\nDoes foo.\"\n")
+	assert.True(t, strings.HasSuffix(out, "3 responses and 2 notifications replayed, 1 differ\n"))
+
+	// so is a notification the recorded server didn't send
+	var dropped []string
+	for _, line := range strings.SplitAfter(string(trace), "\n") {
+		if !strings.Contains(line, protocol.MethodTextDocumentPublishDiagnostics) {
+			dropped = append(dropped, line)
+		}
+	}
+	require.NoError(t, os.WriteFile("session.trace", []byte(strings.Join(dropped, "")), 0644))
+
+	out, err = runCommand(t, "replay", "session.trace")
+	assert.Equal(t, exitError{code: 1}, err)
+	assert.Contains(t, out, "--- recorded textDocument/publishDiagnostics (notification 2)\n+++ replayed textDocument/publishDiagnostics (notification 2)\n")
+	assert.Contains(t, out, "+  \"method\": \"textDocument/publishDiagnostics\",\n")
+	assert.True(t, strings.HasSuffix(out, "3 responses and 2 notifications replayed, 1 differ\n"))
+}
+
+func TestReplayInvalidTrace(t *testing.T) {
+	chdirTemp(t, map[string]string{"session.trace": "not json\n"})
+	_, err := runCommand(t, "replay", "session.trace")
+	assert.EqualError(t, err, "reading session.trace: line 1: invalid character 'o' in literal null (expecting 'u')")
+}
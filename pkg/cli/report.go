@@ -0,0 +1,314 @@
+package cli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+type reportFunc func(w io.Writer, results []fileDiagnostics) error
+
+// reportFormats are the values of the --format flag of the check command.
+var reportFormats = map[string]reportFunc{
+	"text":           reportText,
+	"json":           reportJSON,
+	"sarif":          reportSARIF,
+	"checkstyle":     reportCheckstyle,
+	"github-actions": reportGitHubActions,
+}
+
+func reportFormatNames() []string {
+	names := make([]string, 0, len(reportFormats))
+	for name := range reportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func severityName(d protocol.Diagnostic) string {
+	switch severity(d) {
+	case protocol.DiagnosticSeverityWarning:
+		return "warning"
+	case protocol.DiagnosticSeverityInformation:
+		return "info"
+	case protocol.DiagnosticSeverityHint:
+		return "hint"
+	default:
+		return "error"
+	}
+}
+
+func codeString(d protocol.Diagnostic) string {
+	if d.Code == nil {
+		return ""
+	}
+	return fmt.Sprint(d.Code)
+}
+
+// reportText prints a line per diagnostic, with 1-based positions:
+//
+//	Tiltfile:3:1: error: cannot find "lib.star" (load-failed)
+func reportText(w io.Writer, results []fileDiagnostics) error {
+	for _, r := range results {
+		for _, d := range r.Diagnostics {
+			line := fmt.Sprintf("%s:%d:%d: %s: %s", r.Path, d.Range.Start.Line+1, d.Range.Start.Character+1, severityName(d), d.Message)
+			if code := codeString(d); code != "" {
+				line += fmt.Sprintf(" (%s)", code)
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type jsonDiagnostic struct {
+	File      string `json:"file"`
+	Line      uint32 `json:"line"`
+	Column    uint32 `json:"column"`
+	EndLine   uint32 `json:"endLine"`
+	EndColumn uint32 `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Code      string `json:"code,omitempty"`
+	Message   string `json:"message"`
+}
+
+// reportJSON prints an array of all diagnostics, with 1-based positions.
+func reportJSON(w io.Writer, results []fileDiagnostics) error {
+	diags := []jsonDiagnostic{}
+	for _, r := range results {
+		for _, d := range r.Diagnostics {
+			diags = append(diags, jsonDiagnostic{
+				File:      r.Path,
+				Line:      d.Range.Start.Line + 1,
+				Column:    d.Range.Start.Character + 1,
+				EndLine:   d.Range.End.Line + 1,
+				EndColumn: d.Range.End.Character + 1,
+				Severity:  severityName(d),
+				Code:      codeString(d),
+				Message:   d.Message,
+			})
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diags)
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   uint32 `json:"startLine"`
+	StartColumn uint32 `json:"startColumn"`
+	EndLine     uint32 `json:"endLine"`
+	EndColumn   uint32 `json:"endColumn"`
+}
+
+// reportSARIF prints a SARIF 2.1.0 log, as read by code scanning dashboards.
+// The codes of the diagnostics are the rules.
+func reportSARIF(w io.Writer, results []fileDiagnostics) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "starlark-lsp",
+			InformationURI: "https://github.com/tilt-dev/starlark-lsp",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	rules := make(map[string]bool)
+	for _, r := range results {
+		for _, d := range r.Diagnostics {
+			code := codeString(d)
+			if code != "" && !rules[code] {
+				rules[code] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: code})
+			}
+			level := "note"
+			switch severity(d) {
+			case protocol.DiagnosticSeverityError:
+				level = "error"
+			case protocol.DiagnosticSeverityWarning:
+				level = "warning"
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:  code,
+				Level:   level,
+				Message: sarifMessage{Text: d.Message},
+				Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(r.Path)},
+					Region: sarifRegion{
+						StartLine:   d.Range.Start.Line + 1,
+						StartColumn: d.Range.Start.Character + 1,
+						EndLine:     d.Range.End.Line + 1,
+						EndColumn:   d.Range.End.Character + 1,
+					},
+				}}},
+			})
+		}
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	})
+}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     uint32 `xml:"line,attr"`
+	Column   uint32 `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr,omitempty"`
+}
+
+// reportCheckstyle prints a Checkstyle XML report, with an element for
+// each checked file.
+func reportCheckstyle(w io.Writer, results []fileDiagnostics) error {
+	report := checkstyleReport{Version: "4.3"}
+	for _, r := range results {
+		file := checkstyleFile{Name: r.Path}
+		for _, d := range r.Diagnostics {
+			s := severityName(d)
+			if s == "hint" {
+				s = "info"
+			}
+			file.Errors = append(file.Errors, checkstyleError{
+				Line:     d.Range.Start.Line + 1,
+				Column:   d.Range.Start.Character + 1,
+				Severity: s,
+				Message:  d.Message,
+				Source:   codeString(d),
+			})
+		}
+		report.Files = append(report.Files, file)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// reportGitHubActions prints workflow commands that annotate the files in
+// GitHub Actions:
+//
+//	::error file=Tiltfile,line=3,col=1,endLine=3,endColumn=20,title=load-failed::cannot find "lib.star"
+func reportGitHubActions(w io.Writer, results []fileDiagnostics) error {
+	for _, r := range results {
+		for _, d := range r.Diagnostics {
+			command := "notice"
+			switch severity(d) {
+			case protocol.DiagnosticSeverityError:
+				command = "error"
+			case protocol.DiagnosticSeverityWarning:
+				command = "warning"
+			}
+			props := []string{
+				"file=" + escapeGitHubProperty(filepath.ToSlash(r.Path)),
+				fmt.Sprintf("line=%d", d.Range.Start.Line+1),
+				fmt.Sprintf("col=%d", d.Range.Start.Character+1),
+				fmt.Sprintf("endLine=%d", d.Range.End.Line+1),
+				fmt.Sprintf("endColumn=%d", d.Range.End.Character+1),
+			}
+			if code := codeString(d); code != "" {
+				props = append(props, "title="+escapeGitHubProperty(code))
+			}
+			_, err := fmt.Fprintf(w, "::%s %s::%s\n", command, strings.Join(props, ","), escapeGitHubData(d.Message))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	gitHubDataEscaper     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	gitHubPropertyEscaper = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
+
+func escapeGitHubData(s string) string {
+	return gitHubDataEscaper.Replace(s)
+}
+
+func escapeGitHubProperty(s string) string {
+	return gitHubPropertyEscaper.Replace(s)
+}
@@ -9,7 +9,9 @@ import (
 	"io/fs"
 	"net"
 	"os"
+	"sync"
 	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
 	"go.lsp.dev/jsonrpc2"
@@ -17,13 +19,23 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
 	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/middleware"
 	"github.com/tilt-dev/starlark-lsp/pkg/server"
 )
 
 type startCmd struct {
 	*cobra.Command
-	address string
+	*analyzerFlags
+	address         string
+	listen          string
+	pipe            string
+	websocket       string
+	allowedOrigins  []string
+	clientProcessID int
+	maxSessions     int
+	traceFile       string
 }
 
 var exampleTemplate = template.Must(template.New("example").Parse(`
@@ -32,6 +44,15 @@ var exampleTemplate = template.Must(template.New("example").Parse(`
 
 # Listen on all interfaces on port 8765
 {{.BaseCommandName}} start --address=":8765"
+
+# Listen on a Unix domain socket, and exit when the editor with pid 4242 exits
+{{.BaseCommandName}} start --listen unix:///tmp/starlark-lsp.sock --clientProcessId=4242
+
+# Serve browser-based editors of https://ide.example.com over WebSocket
+{{.BaseCommandName}} start --websocket=":8766" --allowed-origins=https://ide.example.com
+
+# Load Tilt extensions from a local checkout of the extension repository
+{{.BaseCommandName}} start --extension-repo default=file:///src/tilt-extensions
 {{if .HasBuiltinPathsParam}}
 # Provide type-stub style files to parse and treat as additional language
 # built-ins. If path is a directory, treat files and directories inside
@@ -47,9 +68,6 @@ type exampleTemplateParams struct {
 type BuiltinAnalyzerOptionProvider = func() analysis.AnalyzerOption
 type BuiltinFSProvider = func() fs.FS
 
-var builtinAnalyzerOption BuiltinAnalyzerOptionProvider = nil
-var providedManagerOptions []document.ManagerOpt
-
 // creates a new startCmd
 // params:
 //   commandName: what to call the base command in examples (e.g., "starlark-lsp", "tilt lsp")
@@ -66,25 +84,37 @@ By default, the server will run in stdio mode: requests should be written to
 stdin and responses will be written to stdout. (All logging is _always_ done
 to stderr.)
 
-For socket mode, pass the --address option.
+For socket mode, pass the --address option, or the --listen option with a
+unix:///path/to/sock or tcp://host:port address. Each client that connects
+gets a session of its own, and clients are served at the same time. A Unix
+socket file left over by a server that no longer runs is replaced.
+
+With the --pipe option, the server connects to a Unix socket file created by
+the client instead, and serves that client only, as the language client of
+VS Code does on Linux and macOS. Windows named pipes aren't supported.
+
+For WebSocket mode, pass the --websocket option with the address to accept
+HTTP requests on, to be upgraded to WebSocket connections over which each
+JSON-RPC message is sent as a text message, as browser-based editors do.
+Browsers are only allowed to connect from the origins given to the
+--allowed-origins option, or from the address of the server if none is.
+
+With the --clientProcessId option, the server exits when the process with
+that id, usually the editor that started it, exits.
+
+With the --trace-file option, the JSON-RPC messages of the sessions are
+recorded to a file, as a JSON object per line, to be replayed with the
+replay command.
+
+On SIGINT or SIGTERM, the server stops after giving the requests being
+handled a grace period to finish. In stdio mode, it exits with status 1 if
+the client exits without shutting the server down first.
 `,
 		},
 	}
 
-	if builtinFSProvider == nil {
-		var builtinDefPaths []string
-		cmd.Flags().StringArrayVar(&builtinDefPaths, "builtin-paths", nil,
-			"Paths to files and directories to parse and treat as additional language builtins")
-		builtinAnalyzerOption = func() analysis.AnalyzerOption {
-			return analysis.WithBuiltinPaths(builtinDefPaths)
-		}
-	} else {
-		builtinAnalyzerOption = func() analysis.AnalyzerOption {
-			return analysis.WithBuiltins(builtinFSProvider())
-		}
-	}
-
-	providedManagerOptions = managerOpts
+	cmd.analyzerFlags = newAnalyzerFlags(builtinFSProvider, managerOpts)
+	cmd.analyzerFlags.register(cmd.Flags())
 
 	var example bytes.Buffer
 	p := exampleTemplateParams{
@@ -99,29 +129,102 @@ For socket mode, pass the --address option.
 
 	cmd.Command.RunE = func(cc *cobra.Command, args []string) error {
 		ctx := cc.Context()
+		transports := 0
+		for _, flag := range []string{cmd.address, cmd.listen, cmd.pipe, cmd.websocket} {
+			if flag != "" {
+				transports++
+			}
+		}
+		if transports > 1 {
+			return fmt.Errorf("only one of --address, --listen, --pipe and --websocket can be given")
+		}
+		if len(cmd.allowedOrigins) > 0 && cmd.websocket == "" {
+			return fmt.Errorf("--allowed-origins can only be given with --websocket")
+		}
+		network, address := "tcp4", cmd.address
+		if cmd.listen != "" {
+			var err error
+			if network, address, err = listenAddress(cmd.listen); err != nil {
+				return err
+			}
+		}
+		cc.SilenceUsage = true
+
+		if cmd.traceFile != "" {
+			f, err := os.Create(cmd.traceFile)
+			if err != nil {
+				return err
+			}
+			tracer := middleware.NewTracer(f)
+			defer func() {
+				if err := tracer.Err(); err != nil {
+					protocol.LoggerFromContext(ctx).Warn("failed to record trace", zap.Error(err))
+				}
+				_ = f.Close()
+			}()
+			ctx = withTracer(ctx, tracer)
+		}
 
-		analyzer, err := createAnalyzer(ctx)
+		if cmd.clientProcessID != 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(ctx)
+			defer cancel()
+			go watchClientProcess(ctx, cmd.clientProcessID, clientProcessInterval, cancel)
+		}
+
+		// documents outside of the workspace folders aren't configured by a
+		// configuration file
+		analyzer, err := cmd.createAnalyzer(ctx, &config.Config{})
 		if err != nil {
 			return fmt.Errorf("failed to create analyzer: %v", err)
 		}
-		if cmd.address != "" {
-			err = runSocketServer(ctx, cmd.address, analyzer)
-		} else {
-			err = runStdioServer(ctx, analyzer)
+		serverOpts := []server.ServerOpt{server.WithRootAnalyzerFunc(cmd.rootAnalyzerFunc())}
+		switch {
+		case cmd.pipe != "":
+			err = runPipeServer(ctx, cmd.pipe, analyzer, cmd.documentManagerOpts(), serverOpts...)
+		case cmd.websocket != "":
+			err = runWebSocketServer(ctx, cmd.websocket, cmd.allowedOrigins, cmd.maxSessions, analyzer, cmd.documentManagerOpts, serverOpts...)
+		case address != "":
+			err = runSocketServer(ctx, network, address, cmd.maxSessions, analyzer, cmd.documentManagerOpts, serverOpts...)
+		default:
+			err = runStdioServer(ctx, analyzer, cmd.documentManagerOpts(), serverOpts...)
 		}
 		if err == context.Canceled {
 			err = nil
 		}
+		var exit exitError
+		if errors.As(err, &exit) {
+			// the client exited without shutting down the server first
+			cc.SilenceErrors = true
+		}
 		return err
 	}
 
 	cmd.Flags().StringVar(&cmd.address, "address", "",
 		"Address (hostname:port) to listen on")
+	cmd.Flags().StringVar(&cmd.listen, "listen", "",
+		"Address to listen on, as unix:///path/to/sock or tcp://hostname:port")
+	cmd.Flags().StringVar(&cmd.pipe, "pipe", "",
+		"Path of a Unix socket file created by the client to connect to")
+	cmd.Flags().IntVar(&cmd.clientProcessID, "clientProcessId", 0,
+		"Process id of the client, to exit when it exits")
+	cmd.Flags().StringVar(&cmd.websocket, "websocket", "",
+		"Address (hostname:port) to accept WebSocket connections on")
+	cmd.Flags().StringSliceVar(&cmd.allowedOrigins, "allowed-origins", nil,
+		"Origins that browsers are allowed to open WebSocket connections from, or * for any")
+	cmd.Flags().IntVar(&cmd.maxSessions, "max-sessions", 0,
+		"Maximum number of clients served at the same time in socket and WebSocket mode, or 0 for no limit")
+	cmd.Flags().StringVar(&cmd.traceFile, "trace-file", "",
+		"File to record the JSON-RPC messages of the sessions to, for the replay command")
 
 	return &cmd
 }
 
-func runStdioServer(ctx context.Context, analyzer *analysis.Analyzer) error {
+// shutdownGracePeriod is how long the requests still being handled when a
+// session ends are waited for.
+const shutdownGracePeriod = 5 * time.Second
+
+func runStdioServer(ctx context.Context, analyzer *analysis.Analyzer, managerOpts []document.ManagerOpt, serverOpts ...server.ServerOpt) error {
 	ctx, cancel := context.WithCancel(ctx)
 	logger := protocol.LoggerFromContext(ctx)
 	logger.Debug("running in stdio mode")
@@ -133,88 +236,184 @@ func runStdioServer(ctx context.Context, analyzer *analysis.Analyzer) error {
 		os.Stdout,
 	}
 
-	return launchHandler(ctx, cancel, stdio, analyzer)
+	return launchHandler(ctx, cancel, jsonrpc2.NewStream(stdio), analyzer, managerOpts, serverOpts...)
 }
 
-func runSocketServer(ctx context.Context, addr string, analyzer *analysis.Analyzer) error {
-	ctx, cancel := context.WithCancel(ctx)
+// runSocketServer listens on an address of a network, "tcp4", "tcp" or
+// "unix", and serves the clients that connect to it. The socket file of a
+// Unix socket is removed when the server stops.
+func runSocketServer(ctx context.Context, network string, addr string, maxSessions int, analyzer *analysis.Analyzer, managerOpts func() []document.ManagerOpt, serverOpts ...server.ServerOpt) error {
+	if network == "unix" {
+		if err := removeStaleSocket(addr); err != nil {
+			return err
+		}
+	}
 	var lc net.ListenConfig
-	listener, err := lc.Listen(ctx, "tcp4", addr)
+	listener, err := lc.Listen(ctx, network, addr)
 	if err != nil {
-		cancel()
 		return err
 	}
-	defer func() {
-		_ = listener.Close()
-	}()
 
 	logger := protocol.LoggerFromContext(ctx).
 		With(zap.String("local_addr", listener.Addr().String()))
 	ctx = protocol.WithLogger(ctx, logger)
 	logger.Debug("running in socket mode")
 
-	for {
+	return serveSessions(ctx, listener, maxSessions, analyzer, managerOpts, serverOpts...)
+}
+
+// serveSessions runs a session of the language server for each connection
+// the listener accepts, concurrently, until the context is canceled. Each
+// session has its own document manager and server, and ends when its
+// connection is closed or its client exits, without affecting the others.
+// The analyzer isn't modified by sessions, so they share it. If maxSessions
+// is positive, connections beyond that many sessions are closed right away.
+func serveSessions(ctx context.Context, listener net.Listener, maxSessions int, analyzer *analysis.Analyzer, managerOpts func() []document.ManagerOpt, serverOpts ...server.ServerOpt) error {
+	logger := protocol.LoggerFromContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		// unblock Accept when the context is canceled
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer func() {
+		close(done)
+		wg.Wait()
+	}()
+	var sessions chan struct{}
+	if maxSessions > 0 {
+		sessions = make(chan struct{}, maxSessions)
+	}
+
+	for id := 1; ; id++ {
 		conn, err := listener.Accept()
 		if err != nil {
 			if ctx.Err() != nil {
-				cancel()
 				return nil
 			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
 			logger.Warn("failed to accept connection", zap.Error(err))
+			continue
 		}
-		logger.Debug("accepted connection",
-			zap.String("remote_addr", conn.RemoteAddr().String()))
-
-		err = launchHandler(ctx, cancel, conn, analyzer)
-		if err != nil {
-			cancel()
-			return err
+		remoteAddr := zap.String("remote_addr", conn.RemoteAddr().String())
+		if sessions != nil {
+			select {
+			case sessions <- struct{}{}:
+			default:
+				logger.Warn("rejected connection, too many sessions", remoteAddr, zap.Int("max_sessions", maxSessions))
+				_ = conn.Close()
+				continue
+			}
 		}
+
+		sessionLogger := logger.With(zap.Int("session", id), remoteAddr)
+		sessionLogger.Debug("accepted connection")
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			if sessions != nil {
+				defer func() { <-sessions }()
+			}
+			runSession(protocol.WithLogger(ctx, sessionLogger), jsonrpc2.NewStream(conn), analyzer, managerOpts(), serverOpts...)
+		}(conn)
 	}
 }
 
-func initializeConn(conn io.ReadWriteCloser, logger *zap.Logger) (jsonrpc2.Conn, protocol.Client) {
-	stream := jsonrpc2.NewStream(conn)
+// runSession serves a client on a stream until it disconnects or exits, or
+// the context is canceled.
+func runSession(ctx context.Context, stream jsonrpc2.Stream, analyzer *analysis.Analyzer, managerOpts []document.ManagerOpt, serverOpts ...server.ServerOpt) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	logger := protocol.LoggerFromContext(ctx)
+	err := launchHandler(ctx, cancel, stream, analyzer, managerOpts, serverOpts...)
+	var exit exitError
+	if errors.As(err, &exit) {
+		logger.Debug("session ended without shutdown")
+		return
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Warn("session failed", zap.Error(err))
+		return
+	}
+	logger.Debug("session ended")
+}
+
+func initializeConn(stream jsonrpc2.Stream, logger *zap.Logger) (jsonrpc2.Conn, protocol.Client) {
 	jsonConn := jsonrpc2.NewConn(stream)
 	notifier := protocol.ClientDispatcher(jsonConn, logger.Named("notify"))
 
 	return jsonConn, notifier
 }
 
-func createHandler(cancel context.CancelFunc, notifier protocol.Client, analyzer *analysis.Analyzer) jsonrpc2.Handler {
-	docManager := document.NewDocumentManager(providedManagerOptions...)
-	s := server.NewServer(cancel, notifier, docManager, analyzer)
+func createHandler(cancel context.CancelFunc, notifier protocol.Client, analyzer *analysis.Analyzer, managerOpts []document.ManagerOpt, serverOpts ...server.ServerOpt) (*server.Server, jsonrpc2.Handler) {
+	docManager := document.NewDocumentManager(managerOpts...)
+	s := server.NewServer(cancel, notifier, docManager, analyzer, serverOpts...)
 	h := s.Handler(server.StandardMiddleware...)
-	return h
+	return s, h
 }
 
-func launchHandler(ctx context.Context, cancel context.CancelFunc, conn io.ReadWriteCloser, analyzer *analysis.Analyzer) error {
+// launchHandler serves the client on the stream until the context is
+// canceled, which the exit notification of the client does, or the client
+// disconnects. The requests still being handled then get a grace period to
+// finish. If the client exited without shutting down the server first, an
+// exitError with status code 1 is returned.
+func launchHandler(ctx context.Context, cancel context.CancelFunc, stream jsonrpc2.Stream, analyzer *analysis.Analyzer, managerOpts []document.ManagerOpt, serverOpts ...server.ServerOpt) error {
 	logger := protocol.LoggerFromContext(ctx)
-	jsonConn, notifier := initializeConn(conn, logger)
-	h := createHandler(cancel, notifier, analyzer)
+	if tracer := tracerFromContext(ctx); tracer != nil {
+		stream = tracer.Stream(stream)
+	}
+	jsonConn, notifier := initializeConn(stream, logger)
+	s, h := createHandler(cancel, notifier, analyzer, managerOpts, serverOpts...)
 	jsonConn.Go(ctx, h)
 
+	var err error
 	select {
 	case <-ctx.Done():
-		_ = jsonConn.Close()
-		return ctx.Err()
+		err = ctx.Err()
 	case <-jsonConn.Done():
 		if ctx.Err() == nil {
 			if errors.Unwrap(jsonConn.Err()) != io.EOF {
 				// only propagate connection error if context is still valid
-				return jsonConn.Err()
+				err = jsonConn.Err()
 			}
 		}
 	}
 
-	return nil
-}
+	_ = jsonConn.Close()
+	graceCtx, cancelGrace := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	defer cancelGrace()
+	if closeErr := s.Close(graceCtx); closeErr != nil {
+		logger.Warn("requests were still being handled on exit", zap.Error(closeErr))
+	}
 
-func createAnalyzer(ctx context.Context) (*analysis.Analyzer, error) {
-	opts := []analysis.AnalyzerOption{
-		analysis.WithStarlarkBuiltins(),
-		builtinAnalyzerOption(),
+	if code, exited := s.ExitCode(); exited {
+		if code != 0 {
+			return exitError{code: code}
+		}
+		return nil
 	}
+	return err
+}
+
+type tracerKey struct{}
+
+// withTracer records the messages of the sessions served with the context.
+func withTracer(ctx context.Context, tracer *middleware.Tracer) context.Context {
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
 
-	return analysis.NewAnalyzer(ctx, opts...)
+func tracerFromContext(ctx context.Context) *middleware.Tracer {
+	tracer, _ := ctx.Value(tracerKey{}).(*middleware.Tracer)
+	return tracer
 }
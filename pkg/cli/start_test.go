@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+)
+
+// dialSession connects to the server and initializes a session.
+func dialSession(ctx context.Context, addr net.Addr) (jsonrpc2.Conn, error) {
+	netConn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		return nil, err
+	}
+	conn := jsonrpc2.NewConn(jsonrpc2.NewStream(netConn))
+	conn.Go(ctx, jsonrpc2.MethodNotFoundHandler)
+	var result protocol.InitializeResult
+	if _, err := conn.Call(ctx, protocol.MethodInitialize, protocol.InitializeParams{}, &result); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func TestServeSessions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = protocol.WithLogger(ctx, zaptest.NewLogger(t))
+	analyzer, err := analysis.NewAnalyzer(ctx)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr()
+
+	serveCtx, stop := context.WithCancel(ctx)
+	served := make(chan error, 1)
+	go func() {
+		served <- serveSessions(serveCtx, listener, 2, analyzer, func() []document.ManagerOpt { return nil })
+	}()
+
+	// two clients are served at the same time
+	first, err := dialSession(ctx, addr)
+	require.NoError(t, err)
+	second, err := dialSession(ctx, addr)
+	require.NoError(t, err)
+
+	// a third one is rejected
+	rejected, err := net.Dial(addr.Network(), addr.String())
+	require.NoError(t, err)
+	_ = rejected.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = rejected.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+	_ = rejected.Close()
+
+	// the first client exiting ends only its session
+	require.NoError(t, first.Notify(ctx, protocol.MethodExit, nil))
+	select {
+	case <-first.Done():
+	case <-ctx.Done():
+		t.Fatal("session didn't end after exit")
+	}
+	_, err = second.Call(ctx, protocol.MethodShutdown, nil, nil)
+	assert.NoError(t, err)
+
+	// which makes room for another client
+	var third jsonrpc2.Conn
+	require.Eventually(t, func() bool {
+		third, err = dialSession(ctx, addr)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// stopping the server ends all sessions
+	stop()
+	assert.NoError(t, <-served)
+	for _, conn := range []jsonrpc2.Conn{second, third} {
+		select {
+		case <-conn.Done():
+		case <-ctx.Done():
+			t.Fatal("session didn't end when the server stopped")
+		}
+	}
+}
+
+func TestListenAddress(t *testing.T) {
+	tests := []struct {
+		listen, network, address, err string
+	}{
+		{listen: "unix:///tmp/lsp.sock", network: "unix", address: "/tmp/lsp.sock"},
+		{listen: "unix://lsp.sock", network: "unix", address: "lsp.sock"},
+		{listen: "tcp://127.0.0.1:8765", network: "tcp", address: "127.0.0.1:8765"},
+		{listen: "/tmp/lsp.sock", err: `invalid address "/tmp/lsp.sock", expected unix:///path/to/sock or tcp://host:port`},
+		{listen: "unix://", err: `invalid address "unix://", expected unix:///path/to/sock or tcp://host:port`},
+		{listen: "udp://:8765", err: `unsupported network "udp" of address "udp://:8765", expected unix or tcp`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.listen, func(t *testing.T) {
+			network, address, err := listenAddress(tt.listen)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.network, network)
+			assert.Equal(t, tt.address, address)
+		})
+	}
+}
+
+func TestRunSocketServerUnix(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = protocol.WithLogger(ctx, zaptest.NewLogger(t))
+	analyzer, err := analysis.NewAnalyzer(ctx)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "lsp.sock")
+	addr := &net.UnixAddr{Name: path, Net: "unix"}
+	managerOpts := func() []document.ManagerOpt { return nil }
+
+	// leave a socket file behind, as a server that crashed would
+	stale, err := net.ListenUnix("unix", addr)
+	require.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+	require.FileExists(t, path)
+
+	serveCtx, stop := context.WithCancel(ctx)
+	served := make(chan error, 1)
+	go func() {
+		served <- runSocketServer(serveCtx, "unix", path, 0, analyzer, managerOpts)
+	}()
+
+	var conn jsonrpc2.Conn
+	require.Eventually(t, func() bool {
+		conn, err = dialSession(ctx, addr)
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+	_, err = conn.Call(ctx, protocol.MethodShutdown, nil, nil)
+	assert.NoError(t, err)
+
+	// another server can't listen on the socket while it's in use
+	err = runSocketServer(ctx, "unix", path, 0, analyzer, managerOpts)
+	assert.EqualError(t, err, path+" is in use by another server")
+
+	stop()
+	assert.NoError(t, <-served)
+	assert.NoFileExists(t, path)
+}
+
+func TestRunPipeServer(t *testing.T) {
+	for _, shutdown := range []bool{true, false} {
+		t.Run(fmt.Sprintf("shutdown=%v", shutdown), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ctx = protocol.WithLogger(ctx, zaptest.NewLogger(t))
+			analyzer, err := analysis.NewAnalyzer(ctx)
+			require.NoError(t, err)
+
+			// the client creates the pipe and the server connects to it
+			path := filepath.Join(t.TempDir(), "lsp.sock")
+			listener, err := net.Listen("unix", path)
+			require.NoError(t, err)
+			defer func() {
+				_ = listener.Close()
+			}()
+			served := make(chan error, 1)
+			go func() {
+				served <- runPipeServer(ctx, path, analyzer, nil)
+			}()
+
+			netConn, err := listener.Accept()
+			require.NoError(t, err)
+			conn := jsonrpc2.NewConn(jsonrpc2.NewStream(netConn))
+			conn.Go(ctx, jsonrpc2.MethodNotFoundHandler)
+			defer func() {
+				_ = conn.Close()
+			}()
+			var result protocol.InitializeResult
+			_, err = conn.Call(ctx, protocol.MethodInitialize, protocol.InitializeParams{}, &result)
+			require.NoError(t, err)
+
+			if shutdown {
+				_, err = conn.Call(ctx, protocol.MethodShutdown, nil, nil)
+				require.NoError(t, err)
+			}
+			require.NoError(t, conn.Notify(ctx, protocol.MethodExit, nil))
+			if shutdown {
+				assert.NoError(t, <-served)
+			} else {
+				// exiting without shutting down is an error
+				assert.Equal(t, exitError{code: 1}, <-served)
+			}
+		})
+	}
+}
+
+func TestRunPipeServerNoPipe(t *testing.T) {
+	ctx := protocol.WithLogger(context.Background(), zaptest.NewLogger(t))
+	err := runPipeServer(ctx, filepath.Join(t.TempDir(), "missing.sock"), nil, nil)
+	assert.Error(t, err)
+}
+
+func TestWatchClientProcess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = protocol.WithLogger(ctx, zaptest.NewLogger(t))
+
+	// a running client isn't reported
+	watchCtx, stop := context.WithCancel(ctx)
+	watched := make(chan struct{})
+	go func() {
+		defer close(watched)
+		watchClientProcess(watchCtx, os.Getpid(), 10*time.Millisecond, func() {
+			t.Error("running process reported as exited")
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	stop()
+	<-watched
+
+	// one that exited is
+	client := exec.Command(os.Args[0], "-test.run=^$")
+	require.NoError(t, client.Run())
+	exited := make(chan struct{})
+	go watchClientProcess(ctx, client.Process.Pid, 10*time.Millisecond, func() { close(exited) })
+	select {
+	case <-exited:
+	case <-ctx.Done():
+		t.Fatal("exited process wasn't reported")
+	}
+}
+
+func TestStartTransportFlags(t *testing.T) {
+	chdirTemp(t, nil)
+	_, err := runCommand(t, "start", "--address=:0", "--pipe=lsp.sock")
+	assert.EqualError(t, err, "only one of --address, --listen, --pipe and --websocket can be given")
+	_, err = runCommand(t, "start", "--allowed-origins=*")
+	assert.EqualError(t, err, "--allowed-origins can only be given with --websocket")
+	_, err = runCommand(t, "start", "--listen=lsp.sock")
+	assert.EqualError(t, err, `invalid address "lsp.sock", expected unix:///path/to/sock or tcp://host:port`)
+}
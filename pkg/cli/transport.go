@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.uber.org/zap"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/server"
+)
+
+// clientProcessInterval is how often the client process is checked for
+// having exited.
+const clientProcessInterval = 3 * time.Second
+
+// listenAddress parses a --listen URL, unix:///path/to/sock or
+// tcp://host:port, into the network and address to listen on.
+func listenAddress(listen string) (network string, address string, err error) {
+	scheme, address, found := strings.Cut(listen, "://")
+	if !found || address == "" {
+		return "", "", fmt.Errorf("invalid address %q, expected unix:///path/to/sock or tcp://host:port", listen)
+	}
+	switch scheme {
+	case "unix", "tcp":
+		return scheme, address, nil
+	}
+	return "", "", fmt.Errorf("unsupported network %q of address %q, expected unix or tcp", scheme, listen)
+}
+
+// removeStaleSocket removes the socket file at path if it's left over from
+// a server that no longer runs, so it can be listened on again. Listening
+// on a Unix socket that another server is listening on is an error.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Mode().Type() != fs.ModeSocket {
+		return fmt.Errorf("%s exists and isn't a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s is in use by another server", path)
+	}
+	return os.Remove(path)
+}
+
+// runPipeServer serves a single client over a pipe that the client listens
+// on, as with the --pipe argument of the language server protocol: the
+// client creates the pipe and the server connects to it. Only Unix socket
+// files are supported, not the named pipes clients create on Windows.
+func runPipeServer(ctx context.Context, name string, analyzer *analysis.Analyzer, managerOpts []document.ManagerOpt, serverOpts ...server.ServerOpt) error {
+	if strings.HasPrefix(name, `\\.\pipe\`) {
+		return fmt.Errorf("named pipes aren't supported, use stdio or a socket instead")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	logger := protocol.LoggerFromContext(ctx).With(zap.String("pipe", name))
+	ctx = protocol.WithLogger(ctx, logger)
+	logger.Debug("running in pipe mode")
+
+	return launchHandler(ctx, cancel, jsonrpc2.NewStream(conn), analyzer, managerOpts, serverOpts...)
+}
+
+// watchClientProcess checks at every interval whether the process of the
+// client is still running, and calls exited once it isn't, so that the
+// server doesn't outlive an editor that died without telling it to exit.
+// It returns when the context is canceled.
+func watchClientProcess(ctx context.Context, pid int, interval time.Duration, exited func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !processExists(pid) {
+				protocol.LoggerFromContext(ctx).Info("client process exited", zap.Int("pid", pid))
+				exited()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.uber.org/zap"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/server"
+)
+
+// webSocketCloseTimeout is how long to wait for sending the close message
+// of a WebSocket connection.
+const webSocketCloseTimeout = time.Second
+
+// runWebSocketServer listens on an address for HTTP requests, and serves a
+// session of the language server on each request upgraded to a WebSocket
+// connection.
+func runWebSocketServer(ctx context.Context, addr string, allowedOrigins []string, maxSessions int, analyzer *analysis.Analyzer, managerOpts func() []document.ManagerOpt, serverOpts ...server.ServerOpt) error {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	logger := protocol.LoggerFromContext(ctx).
+		With(zap.String("local_addr", listener.Addr().String()))
+	ctx = protocol.WithLogger(ctx, logger)
+	logger.Debug("running in websocket mode")
+
+	ws := newWebSocketServer(ctx, allowedOrigins, maxSessions, analyzer, managerOpts, serverOpts...)
+	srv := &http.Server{Handler: ws}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = srv.Close()
+	}()
+
+	err = srv.Serve(listener)
+	close(done)
+	ws.wait()
+	if ctx.Err() != nil && errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// webSocketServer is an HTTP handler that upgrades requests to WebSocket
+// connections and serves a session of the language server on each, until
+// the client disconnects or exits, or the context of the server is
+// canceled. Sessions are concurrent, as in socket mode.
+type webSocketServer struct {
+	ctx         context.Context
+	upgrader    websocket.Upgrader
+	analyzer    *analysis.Analyzer
+	managerOpts func() []document.ManagerOpt
+	serverOpts  []server.ServerOpt
+	maxSessions int
+	// sessions holds a value per running session if their number is
+	// limited, and is nil otherwise.
+	sessions chan struct{}
+	// mu guards closing, so that no session is added to wg once wait has
+	// started waiting for them.
+	mu      sync.Mutex
+	closing bool
+	wg      sync.WaitGroup
+	lastID  int64
+}
+
+// newWebSocketServer creates a webSocketServer whose sessions end when the
+// context is canceled. Requests from browsers are only accepted from the
+// allowed origins, like "https://ide.example.com" or "*" for any origin, or
+// from the origin of the server itself if no origin is allowed.
+func newWebSocketServer(ctx context.Context, allowedOrigins []string, maxSessions int, analyzer *analysis.Analyzer, managerOpts func() []document.ManagerOpt, serverOpts ...server.ServerOpt) *webSocketServer {
+	s := &webSocketServer{
+		ctx:         ctx,
+		upgrader:    websocket.Upgrader{CheckOrigin: checkOrigin(allowedOrigins)},
+		analyzer:    analyzer,
+		managerOpts: managerOpts,
+		serverOpts:  serverOpts,
+		maxSessions: maxSessions,
+	}
+	if maxSessions > 0 {
+		s.sessions = make(chan struct{}, maxSessions)
+	}
+	return s
+}
+
+func (s *webSocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := protocol.LoggerFromContext(s.ctx)
+	remoteAddr := zap.String("remote_addr", r.RemoteAddr)
+	if !s.add() {
+		logger.Debug("rejected connection, server is stopping", remoteAddr)
+		http.Error(w, "server is stopping", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.wg.Done()
+	if s.sessions != nil {
+		select {
+		case s.sessions <- struct{}{}:
+			defer func() { <-s.sessions }()
+		default:
+			logger.Warn("rejected connection, too many sessions", remoteAddr, zap.Int("max_sessions", s.maxSessions))
+			http.Error(w, "too many sessions", http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has replied with an error
+		logger.Debug("failed to upgrade connection", remoteAddr,
+			zap.String("origin", r.Header.Get("Origin")), zap.Error(err))
+		return
+	}
+
+	sessionLogger := logger.With(zap.Int64("session", atomic.AddInt64(&s.lastID, 1)), remoteAddr)
+	sessionLogger.Debug("accepted connection")
+	runSession(protocol.WithLogger(s.ctx, sessionLogger), webSocketStream{conn}, s.analyzer, s.managerOpts(), s.serverOpts...)
+}
+
+// add adds a session to wait for, unless the server is stopping.
+func (s *webSocketServer) add() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing || s.ctx.Err() != nil {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+// wait refuses new sessions and waits for the running ones to end.
+func (s *webSocketServer) wait() {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// checkOrigin returns the function that checks the origin of a WebSocket
+// request, or nil for the default check that the origin is the host of the
+// server if no origin is allowed. Requests without origin don't come from
+// browsers and are always accepted.
+func checkOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	if len(allowedOrigins) == 0 {
+		return nil
+	}
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// webSocketStream is a jsonrpc2.Stream that sends each JSON-RPC message as
+// a text message of a WebSocket connection, without the headers of the
+// stdio and socket modes, as browser-based language clients expect.
+type webSocketStream struct {
+	conn *websocket.Conn
+}
+
+func (s webSocketStream) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	default:
+	}
+
+	_, data, err := s.conn.ReadMessage()
+	if err != nil {
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			// the client disconnected like at the end of a stream
+			err = io.EOF
+		}
+		return nil, 0, fmt.Errorf("reading message: %w", err)
+	}
+	msg, err := jsonrpc2.DecodeMessage(data)
+	return msg, int64(len(data)), err
+}
+
+func (s webSocketStream) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling message: %w", err)
+	}
+	if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return 0, fmt.Errorf("writing message: %w", err)
+	}
+	return int64(len(data)), nil
+}
+
+// Close closes the connection, after telling the client if it's still
+// connected.
+func (s webSocketStream) Close() error {
+	_ = s.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(webSocketCloseTimeout))
+	return s.conn.Close()
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+)
+
+// startWebSocketServer serves sessions over WebSocket until the test ends,
+// and returns the URL to connect to.
+func startWebSocketServer(t *testing.T, ctx context.Context, allowedOrigins []string, maxSessions int) string {
+	t.Helper()
+	analyzer, err := analysis.NewAnalyzer(ctx)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(ctx)
+	ws := newWebSocketServer(ctx, allowedOrigins, maxSessions, analyzer, func() []document.ManagerOpt { return nil })
+	srv := httptest.NewServer(ws)
+	t.Cleanup(func() {
+		cancel()
+		ws.wait()
+		srv.Close()
+	})
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+// dialWebSocket connects to the server from an origin, if not empty.
+func dialWebSocket(ctx context.Context, url string, origin string) (jsonrpc2.Conn, *http.Response, error) {
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	wsConn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		return nil, resp, err
+	}
+	conn := jsonrpc2.NewConn(webSocketStream{wsConn})
+	conn.Go(ctx, jsonrpc2.MethodNotFoundHandler)
+	return conn, resp, nil
+}
+
+func TestWebSocketServer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = protocol.WithLogger(ctx, zaptest.NewLogger(t))
+	url := startWebSocketServer(t, ctx, nil, 1)
+
+	conn, _, err := dialWebSocket(ctx, url, "")
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	var result protocol.InitializeResult
+	_, err = conn.Call(ctx, protocol.MethodInitialize, protocol.InitializeParams{}, &result)
+	require.NoError(t, err)
+	assert.NotNil(t, result.Capabilities.HoverProvider)
+
+	// a second client is rejected while the first one is served
+	_, resp, err := dialWebSocket(ctx, url, "")
+	require.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// exiting ends the session and makes room for another client
+	_, err = conn.Call(ctx, protocol.MethodShutdown, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.Notify(ctx, protocol.MethodExit, nil))
+	select {
+	case <-conn.Done():
+	case <-ctx.Done():
+		t.Fatal("session didn't end after exit")
+	}
+	require.Eventually(t, func() bool {
+		other, _, err := dialWebSocket(ctx, url, "")
+		if err != nil {
+			return false
+		}
+		_ = other.Close()
+		return true
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocketServerOrigins(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = protocol.WithLogger(ctx, zaptest.NewLogger(t))
+
+	tests := []struct {
+		name           string
+		allowedOrigins []string
+		origin         string
+		allowed        bool
+	}{
+		{name: "no origin", origin: "", allowed: true},
+		{name: "other origin by default", origin: "https://ide.example.com", allowed: false},
+		{name: "allowed origin", allowedOrigins: []string{"https://ide.example.com"}, origin: "https://ide.example.com", allowed: true},
+		{name: "allowed origin with slash", allowedOrigins: []string{"https://ide.example.com/"}, origin: "https://ide.example.com", allowed: true},
+		{name: "other origin", allowedOrigins: []string{"https://ide.example.com"}, origin: "https://evil.example.com", allowed: false},
+		{name: "any origin", allowedOrigins: []string{"*"}, origin: "https://evil.example.com", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := startWebSocketServer(t, ctx, tt.allowedOrigins, 0)
+			conn, resp, err := dialWebSocket(ctx, url, tt.origin)
+			if !tt.allowed {
+				require.Error(t, err)
+				assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+				return
+			}
+			require.NoError(t, err)
+			_ = conn.Close()
+		})
+	}
+}
+
+func TestWebSocketServerStop(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = protocol.WithLogger(ctx, zaptest.NewLogger(t))
+	analyzer, err := analysis.NewAnalyzer(ctx)
+	require.NoError(t, err)
+
+	serveCtx, stop := context.WithCancel(ctx)
+	ws := newWebSocketServer(serveCtx, nil, 0, analyzer, func() []document.ManagerOpt { return nil })
+	srv := httptest.NewServer(ws)
+	defer srv.Close()
+
+	conn, _, err := dialWebSocket(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	require.NoError(t, err)
+	var result protocol.InitializeResult
+	_, err = conn.Call(ctx, protocol.MethodInitialize, protocol.InitializeParams{}, &result)
+	require.NoError(t, err)
+
+	// stopping the server closes the connections of the sessions
+	stop()
+	ws.wait()
+	select {
+	case <-conn.Done():
+	case <-ctx.Done():
+		t.Fatal("session didn't end when the server stopped")
+	}
+
+	// connections after the server stopped are refused
+	_, resp, err := dialWebSocket(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"context"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/format"
+)
+
+// AnalyzerOptions returns the options for an analyzer with the builtins and
+// dialect of the configuration. They are meant to be applied after the
+// options given on the command line, which they extend or override.
+func (c *Config) AnalyzerOptions(ctx context.Context) []analysis.AnalyzerOption {
+	opts := []analysis.AnalyzerOption{analysis.WithBuiltinPaths(c.BuiltinPaths)}
+	if len(c.Profiles) > 0 {
+		opts = append(opts, c.profilesOption(ctx))
+	}
+	if c.BazelEnabled() {
+		opts = append(opts, analysis.WithBazelProfiles())
+	}
+	if c.Dialect.TypeCheck != nil {
+		opts = append(opts, analysis.WithTypeChecking(*c.Dialect.TypeCheck))
+	}
+	if len(c.Dialect.StructConstructors) > 0 {
+		opts = append(opts, analysis.WithStructConstructors(c.Dialect.StructConstructors...))
+	}
+	if len(c.Diagnostics.Disabled) > 0 {
+		opts = append(opts, analysis.WithDisabledDiagnostics(c.Diagnostics.Disabled...))
+	}
+	return opts
+}
+
+func (c *Config) profilesOption(ctx context.Context) analysis.AnalyzerOption {
+	return func(a *analysis.Analyzer) error {
+		profiles := make([]analysis.Profile, len(c.Profiles))
+		for i, p := range c.Profiles {
+			builtins := analysis.NewBuiltins()
+			for _, path := range p.BuiltinPaths {
+				b, err := analysis.LoadBuiltins(ctx, path)
+				if err != nil {
+					return err
+				}
+				builtins.Update(b)
+			}
+			profiles[i] = analysis.Profile{
+				Name:        p.Name,
+				Patterns:    p.Files,
+				LanguageIDs: p.LanguageIDs,
+				Builtins:    builtins,
+			}
+		}
+		return analysis.WithProfiles(profiles...)(a)
+	}
+}
+
+// DocumentLoadPaths returns the load aliases and search paths for the
+// document manager.
+func (c *Config) DocumentLoadPaths() document.LoadPaths {
+	return document.LoadPaths{
+		Aliases:     c.Load.Aliases,
+		SearchPaths: c.Load.SearchPaths,
+	}
+}
+
+// FormatOptions returns the style of formatted code.
+func (c *Config) FormatOptions() format.Options {
+	return format.Options{
+		IndentWidth: c.Format.IndentWidth,
+		Quote:       c.Format.Quote,
+	}
+}
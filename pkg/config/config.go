@@ -0,0 +1,262 @@
+// Package config loads project settings from a configuration file at the
+// root of a workspace folder, and from the settings of the editor, which
+// override the keys of the file.
+//
+// A configuration file looks like this:
+//
+//	builtinPaths:
+//	  - stubs/api.py
+//	profiles:
+//	  - name: rules
+//	    files: ["*.rules"]
+//	    builtinPaths: [stubs/rules]
+//	dialect:
+//	  bazel: false
+//	  typeCheck: true
+//	diagnostics:
+//	  disabled: [load-symbol-not-found]
+//	load:
+//	  aliases:
+//	    "@lib": lib
+//	  searchPaths: [third_party]
+//	format:
+//	  indentWidth: 2
+//	  quote: single
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Section is the section of the editor settings with the keys of the
+// configuration file, e.g. `"starlark": {"builtinPaths": [...]}`.
+const Section = "starlark"
+
+// FileNames are the names of configuration files, in the order they are
+// looked up.
+var FileNames = []string{".starlark-lsp.yaml", ".starlark-lsp.yml"}
+
+type Config struct {
+	// BuiltinPaths are files and directories to load builtins from, in
+	// addition to the builtins given on the command line.
+	BuiltinPaths []string `yaml:"builtinPaths" json:"builtinPaths"`
+	// Profiles select builtins by file name or language, see
+	// analysis.Profile.
+	Profiles    []Profile   `yaml:"profiles" json:"profiles"`
+	Dialect     Dialect     `yaml:"dialect" json:"dialect"`
+	Diagnostics Diagnostics `yaml:"diagnostics" json:"diagnostics"`
+	Load        LoadPaths   `yaml:"load" json:"load"`
+	Format      Format      `yaml:"format" json:"format"`
+}
+
+type Profile struct {
+	Name string `yaml:"name" json:"name"`
+	// Files are patterns matching the base names of files, e.g. "*.star".
+	Files        []string `yaml:"files" json:"files"`
+	LanguageIDs  []string `yaml:"languageIds" json:"languageIds"`
+	BuiltinPaths []string `yaml:"builtinPaths" json:"builtinPaths"`
+}
+
+// Dialect configures the flavor of Starlark being analyzed.
+type Dialect struct {
+	// Bazel enables the builtin profiles for Bazel files. It's enabled
+	// unless set to false.
+	Bazel *bool `yaml:"bazel" json:"bazel"`
+	// TypeCheck enables type mismatch diagnostics, overriding --type-check.
+	TypeCheck *bool `yaml:"typeCheck" json:"typeCheck"`
+	// StructConstructors are functions creating values whose fields are
+	// given as keyword arguments, in addition to `struct`.
+	StructConstructors []string `yaml:"structConstructors" json:"structConstructors"`
+}
+
+type Diagnostics struct {
+	// Disabled are the codes of diagnostics that aren't reported, e.g.
+	// "type-mismatch".
+	Disabled []string `yaml:"disabled" json:"disabled"`
+}
+
+// LoadPaths configures how the paths of load statements are resolved.
+type LoadPaths struct {
+	// Aliases map prefixes of load paths to directories, e.g. "@lib" to
+	// "third_party/lib".
+	Aliases map[string]string `yaml:"aliases" json:"aliases"`
+	// SearchPaths are directories where load paths that aren't found
+	// relative to the loading file are looked up.
+	SearchPaths []string `yaml:"searchPaths" json:"searchPaths"`
+}
+
+// Format configures the style of formatted code.
+type Format struct {
+	// IndentWidth is the number of spaces per indentation level, 4 if unset.
+	// The editor's tab size isn't used, so that the editor and `format`
+	// agree.
+	IndentWidth int `yaml:"indentWidth" json:"indentWidth"`
+	// Quote is the preferred quote of strings, "double" or "single".
+	Quote string `yaml:"quote" json:"quote"`
+}
+
+// Find returns the path of the configuration file in the directory, or an
+// empty string if there is none.
+func Find(dir string) string {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// Load reads the configuration file in the directory. If there is none, an
+// empty configuration is returned.
+func Load(dir string) (*Config, error) {
+	path := Find(dir)
+	if path == "" {
+		return &Config{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// Parse parses a configuration file. Relative paths are resolved from dir.
+// Unknown keys are an error, to catch typos.
+func Parse(data []byte, dir string) (*Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	c.resolvePaths(dir)
+	return &c, nil
+}
+
+// FromSettings converts the settings of the editor, the value of the
+// Section, to a configuration. Relative paths are resolved from dir.
+// Settings that are null are an empty configuration.
+func FromSettings(settings interface{}, dir string) (*Config, error) {
+	var c Config
+	if settings == nil {
+		return &c, nil
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	c.resolvePaths(dir)
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	switch c.Format.Quote {
+	case "", "double", "single":
+	default:
+		return fmt.Errorf("format.quote must be \"double\" or \"single\", not %q", c.Format.Quote)
+	}
+	if c.Format.IndentWidth < 0 {
+		return fmt.Errorf("format.indentWidth must not be negative")
+	}
+	for i, p := range c.Profiles {
+		for _, pattern := range p.Files {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("profiles[%d].files: invalid pattern %q", i, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolvePaths(dir string) {
+	resolve := func(paths []string) {
+		for i, path := range paths {
+			if !filepath.IsAbs(path) {
+				paths[i] = filepath.Join(dir, path)
+			}
+		}
+	}
+	resolve(c.BuiltinPaths)
+	for _, p := range c.Profiles {
+		resolve(p.BuiltinPaths)
+	}
+	resolve(c.Load.SearchPaths)
+	for prefix, path := range c.Load.Aliases {
+		if !filepath.IsAbs(path) {
+			c.Load.Aliases[prefix] = filepath.Join(dir, path)
+		}
+	}
+}
+
+// Merge returns the configuration with the keys that are set in other
+// replacing its own. Load aliases are merged by prefix.
+func (c *Config) Merge(other *Config) *Config {
+	merged := *c
+	if other == nil {
+		return &merged
+	}
+	if other.BuiltinPaths != nil {
+		merged.BuiltinPaths = other.BuiltinPaths
+	}
+	if other.Profiles != nil {
+		merged.Profiles = other.Profiles
+	}
+	if other.Dialect.Bazel != nil {
+		merged.Dialect.Bazel = other.Dialect.Bazel
+	}
+	if other.Dialect.TypeCheck != nil {
+		merged.Dialect.TypeCheck = other.Dialect.TypeCheck
+	}
+	if other.Dialect.StructConstructors != nil {
+		merged.Dialect.StructConstructors = other.Dialect.StructConstructors
+	}
+	if other.Diagnostics.Disabled != nil {
+		merged.Diagnostics.Disabled = other.Diagnostics.Disabled
+	}
+	if len(other.Load.Aliases) > 0 {
+		merged.Load.Aliases = make(map[string]string)
+		for prefix, path := range c.Load.Aliases {
+			merged.Load.Aliases[prefix] = path
+		}
+		for prefix, path := range other.Load.Aliases {
+			merged.Load.Aliases[prefix] = path
+		}
+	}
+	if other.Load.SearchPaths != nil {
+		merged.Load.SearchPaths = other.Load.SearchPaths
+	}
+	if other.Format.IndentWidth != 0 {
+		merged.Format.IndentWidth = other.Format.IndentWidth
+	}
+	if other.Format.Quote != "" {
+		merged.Format.Quote = other.Format.Quote
+	}
+	return &merged
+}
+
+// BazelEnabled reports whether the Bazel profiles are enabled.
+func (c *Config) BazelEnabled() bool {
+	return c.Dialect.Bazel == nil || *c.Dialect.Bazel
+}
@@ -0,0 +1,139 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+builtinPaths:
+  - stubs/api.py
+  - /opt/stubs
+profiles:
+  - name: rules
+    files: ["*.rules"]
+    languageIds: [rules]
+    builtinPaths: [stubs/rules]
+dialect:
+  bazel: false
+  typeCheck: true
+  structConstructors: [provider]
+diagnostics:
+  disabled: [load-symbol-not-found]
+load:
+  aliases:
+    "@lib": lib
+  searchPaths: [third_party]
+format:
+  indentWidth: 2
+  quote: single
+`
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Parse([]byte(testConfig), dir)
+	require.NoError(t, err)
+
+	f, tr := false, true
+	assert.Equal(t, &Config{
+		BuiltinPaths: []string{filepath.Join(dir, "stubs/api.py"), "/opt/stubs"},
+		Profiles: []Profile{{
+			Name:         "rules",
+			Files:        []string{"*.rules"},
+			LanguageIDs:  []string{"rules"},
+			BuiltinPaths: []string{filepath.Join(dir, "stubs/rules")},
+		}},
+		Dialect:     Dialect{Bazel: &f, TypeCheck: &tr, StructConstructors: []string{"provider"}},
+		Diagnostics: Diagnostics{Disabled: []string{"load-symbol-not-found"}},
+		Load: LoadPaths{
+			Aliases:     map[string]string{"@lib": filepath.Join(dir, "lib")},
+			SearchPaths: []string{filepath.Join(dir, "third_party")},
+		},
+		Format: Format{IndentWidth: 2, Quote: "single"},
+	}, c)
+	assert.False(t, c.BazelEnabled())
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":     "builtinPath: [a.py]",
+		"invalid quote":   "format:\n  quote: backtick",
+		"invalid pattern": "profiles:\n  - files: ['[']",
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(src), t.TempDir())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, &Config{}, c)
+	assert.True(t, c.BazelEnabled())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".starlark-lsp.yml"), []byte("diagnostics:\n  disabled: [type-mismatch]\n"), 0644))
+	c, err = Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"type-mismatch"}, c.Diagnostics.Disabled)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".starlark-lsp.yaml"), []byte("format: [\n"), 0644))
+	_, err = Load(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ".starlark-lsp.yaml")
+}
+
+func TestFromSettings(t *testing.T) {
+	dir := t.TempDir()
+	settings := map[string]interface{}{
+		"builtinPaths": []interface{}{"stubs"},
+		"dialect":      map[string]interface{}{"typeCheck": true},
+	}
+	c, err := FromSettings(settings, dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "stubs")}, c.BuiltinPaths)
+	require.NotNil(t, c.Dialect.TypeCheck)
+	assert.True(t, *c.Dialect.TypeCheck)
+
+	c, err = FromSettings(nil, dir)
+	require.NoError(t, err)
+	assert.Equal(t, &Config{}, c)
+
+	_, err = FromSettings(map[string]interface{}{"format": map[string]interface{}{"quote": "none"}}, dir)
+	assert.Error(t, err)
+}
+
+func TestMerge(t *testing.T) {
+	tr := true
+	file := &Config{
+		BuiltinPaths: []string{"/stubs"},
+		Diagnostics:  Diagnostics{Disabled: []string{"type-mismatch"}},
+		Load:         LoadPaths{Aliases: map[string]string{"@lib": "/lib", "@ext": "/ext"}},
+		Format:       Format{IndentWidth: 2, Quote: "single"},
+	}
+	settings := &Config{
+		Dialect:     Dialect{TypeCheck: &tr},
+		Diagnostics: Diagnostics{Disabled: []string{}},
+		Load:        LoadPaths{Aliases: map[string]string{"@lib": "/vendor/lib"}},
+		Format:      Format{Quote: "double"},
+	}
+
+	merged := file.Merge(settings)
+	assert.Equal(t, &Config{
+		BuiltinPaths: []string{"/stubs"},
+		Dialect:      Dialect{TypeCheck: &tr},
+		Diagnostics:  Diagnostics{Disabled: []string{}},
+		Load:         LoadPaths{Aliases: map[string]string{"@lib": "/vendor/lib", "@ext": "/ext"}},
+		Format:       Format{IndentWidth: 2, Quote: "double"},
+	}, merged)
+	// the configurations being merged are left alone
+	assert.Equal(t, "/lib", file.Load.Aliases["@lib"])
+	assert.Equal(t, file, file.Merge(nil))
+}
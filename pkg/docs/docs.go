@@ -0,0 +1,115 @@
+// Package docs generates reference documentation of Starlark builtins and
+// modules, with a page per module and links between the symbols they
+// document.
+package docs
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/docstring"
+	"github.com/tilt-dev/starlark-lsp/pkg/document"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// Module is the documented contents of a module.
+type Module struct {
+	// Name is the name of the module, e.g. "os" for builtins of the os
+	// module or the path of a Starlark file.
+	Name string
+	// Global is whether the symbols of the module can be referred to from
+	// other modules, like builtins, by their name prefixed by Prefix.
+	Global bool
+	Prefix string
+
+	Docs      docstring.Parsed
+	Functions []query.Signature
+	Types     []query.Type
+	Variables []query.Symbol
+}
+
+func (m *Module) isEmpty() bool {
+	return m.Docs.Description == "" && len(m.Functions) == 0 && len(m.Types) == 0 && len(m.Variables) == 0
+}
+
+func (m *Module) sort() {
+	sort.Slice(m.Functions, func(i, j int) bool { return m.Functions[i].Name < m.Functions[j].Name })
+	sort.Slice(m.Types, func(i, j int) bool { return m.Types[i].Name < m.Types[j].Name })
+	sort.SliceStable(m.Variables, func(i, j int) bool { return m.Variables[i].Name < m.Variables[j].Name })
+}
+
+// BuiltinModules splits builtins into modules. The top-level functions,
+// variables and types of the builtins make up the module with the given
+// name, and those of each submodule, like `os.path`, a module of their own.
+// Modules are ordered by name, after the top-level module.
+func BuiltinModules(name string, b *analysis.Builtins) []Module {
+	var result []Module
+	for _, bm := range b.Modules() {
+		m := Module{
+			Name:      bm.Name,
+			Global:    true,
+			Functions: bm.Functions,
+			Variables: bm.Variables,
+		}
+		if bm.Name == "" {
+			m.Name = name
+			for _, t := range b.Types {
+				m.Types = append(m.Types, t)
+			}
+		} else {
+			m.Prefix = bm.Name + "."
+		}
+		if m.isEmpty() {
+			continue
+		}
+		m.sort()
+		result = append(result, m)
+	}
+	return result
+}
+
+// LoadModule reads the public functions, variables and types of a Starlark
+// file, and the docstring at its top.
+func LoadModule(ctx context.Context, path string, name string) (Module, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Module{}, errors.Wrapf(err, "reading %s", path)
+	}
+	tree, err := query.Parse(ctx, contents)
+	if err != nil {
+		return Module{}, errors.Wrapf(err, "failed to parse %q", path)
+	}
+	doc := document.NewDocument(uri.File(path), contents, tree)
+	defer doc.Close()
+
+	m := Module{Name: name, Docs: query.ModuleDocstring(doc)}
+	for _, fn := range doc.Functions() {
+		if isPublic(fn.Name) {
+			m.Functions = append(m.Functions, fn)
+		}
+	}
+	for _, sym := range doc.Symbols() {
+		if sym.Kind != protocol.SymbolKindFunction && isPublic(sym.Name) {
+			m.Variables = append(m.Variables, sym)
+		}
+	}
+	for _, t := range query.Types(doc, tree.RootNode()) {
+		if isPublic(t.Name) {
+			m.Types = append(m.Types, t)
+		}
+	}
+	m.sort()
+	return m, nil
+}
+
+// isPublic reports whether a symbol can be loaded from other modules.
+func isPublic(name string) bool {
+	return !strings.HasPrefix(name, "_")
+}
@@ -0,0 +1,66 @@
+package docs
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestRender(t *testing.T) {
+	ctx := context.Background()
+	builtins, err := analysis.LoadBuiltins(ctx, "testdata/builtins")
+	require.NoError(t, err)
+	modules := BuiltinModules("builtins", builtins)
+	lib, err := LoadModule(ctx, "testdata/lib.star", "lib/lib.star")
+	require.NoError(t, err)
+	modules = append(modules, lib)
+
+	for _, format := range []Format{Markdown, HTML} {
+		t.Run(string(format), func(t *testing.T) {
+			var sb strings.Builder
+			for _, p := range Render("API reference", modules, format) {
+				sb.WriteString("=== " + p.Path + " ===\n")
+				sb.Write(p.Content)
+			}
+
+			golden := filepath.Join("testdata", "reference"+format.ext()+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, []byte(sb.String()), 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), sb.String())
+		})
+	}
+}
+
+func TestBuiltinModules(t *testing.T) {
+	builtins, err := analysis.LoadBuiltins(context.Background(), "testdata/builtins")
+	require.NoError(t, err)
+
+	modules := BuiltinModules("tilt", builtins)
+	require.Len(t, modules, 2)
+	assert.Equal(t, "tilt", modules[0].Name)
+	assert.Equal(t, "", modules[0].Prefix)
+	require.Len(t, modules[0].Functions, 1)
+	assert.Equal(t, "local_resource", modules[0].Functions[0].Name)
+	require.Len(t, modules[0].Types, 1)
+	assert.Equal(t, "Resource", modules[0].Types[0].Name)
+	require.Len(t, modules[0].Variables, 1)
+	assert.Equal(t, "config_mode", modules[0].Variables[0].Name)
+
+	assert.Equal(t, "os", modules[1].Name)
+	assert.Equal(t, "os.", modules[1].Prefix)
+	require.Len(t, modules[1].Functions, 1)
+	assert.Equal(t, "getcwd", modules[1].Functions[0].Name)
+}
@@ -0,0 +1,353 @@
+package docs
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// indexPage is the name of the page that lists the modules.
+const indexPage = "index"
+
+// page is a page of the reference, as blocks that are rendered as Markdown
+// or HTML.
+type page struct {
+	name   string
+	title  string
+	blocks []block
+}
+
+type block interface{}
+
+type heading struct {
+	level int
+	id    string
+	text  string
+}
+
+// caption is the title of a section within the documentation of a symbol,
+// e.g. "Parameters".
+type caption struct {
+	text string
+}
+
+type paragraph struct {
+	spans []span
+}
+
+type codeBlock struct {
+	code string
+}
+
+type list struct {
+	items []listItem
+}
+
+type listItem struct {
+	id    string
+	spans []span
+}
+
+// table is a table with a header row, whose cells are spans.
+type table struct {
+	header []string
+	rows   [][][]span
+}
+
+// span is a part of a paragraph: text, or code that may link to the
+// documentation of a symbol.
+type span struct {
+	text string
+	code bool
+	link *target
+}
+
+// target is the location of the documentation of a symbol.
+type target struct {
+	page   string
+	anchor string
+}
+
+// index maps the names of symbols to their documentation.
+type index struct {
+	// global are the symbols that can be referred to from any module.
+	global map[string]target
+	// local are the symbols of each page.
+	local map[string]map[string]target
+}
+
+func newIndex(modules []Module) *index {
+	ix := &index{global: make(map[string]target), local: make(map[string]map[string]target)}
+	for _, m := range modules {
+		name := pageName(m.Name)
+		local := make(map[string]target)
+		add := func(anchor string) {
+			t := target{page: name, anchor: anchor}
+			local[anchor] = t
+			if m.Global {
+				if _, found := ix.global[m.Prefix+anchor]; !found {
+					ix.global[m.Prefix+anchor] = t
+				}
+			}
+		}
+		for _, fn := range m.Functions {
+			add(fn.Name)
+		}
+		for _, t := range m.Types {
+			add(t.Name)
+			for _, method := range t.Methods {
+				add(t.Name + "." + method.Name)
+			}
+			for _, field := range t.Fields {
+				add(t.Name + "." + field.Name)
+			}
+		}
+		for _, v := range m.Variables {
+			add(v.Name)
+		}
+		ix.local[name] = local
+	}
+	return ix
+}
+
+// resolve returns the documentation of a symbol referred to on a page,
+// looking at the symbols of the page first.
+func (ix *index) resolve(page string, name string) *target {
+	name = strings.TrimSuffix(name, "()")
+	if t, found := ix.local[page][name]; found {
+		return &t
+	}
+	if t, found := ix.global[name]; found {
+		return &t
+	}
+	return nil
+}
+
+// pageName returns the name of the page of a module, without extension.
+func pageName(module string) string {
+	return strings.ReplaceAll(module, "/", ".")
+}
+
+// pages builds the index page and the page of each module.
+func pages(title string, modules []Module) []page {
+	ix := newIndex(modules)
+	index := page{name: indexPage, title: title}
+	index.blocks = append(index.blocks, heading{level: 1, text: title})
+	var items []listItem
+	for _, m := range modules {
+		spans := []span{{text: m.Name, code: true, link: &target{page: pageName(m.Name)}}}
+		if summary := firstLine(m.Docs.Description); summary != "" {
+			spans = append(spans, span{text: ": " + summary})
+		}
+		items = append(items, listItem{spans: spans})
+	}
+	index.blocks = append(index.blocks, list{items: items})
+
+	result := []page{index}
+	for _, m := range modules {
+		b := builder{index: ix, page: page{name: pageName(m.Name), title: m.Name}}
+		b.module(m)
+		result = append(result, b.page)
+	}
+	return result
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(line)
+}
+
+// builder adds the documentation of a module to its page.
+type builder struct {
+	index *index
+	page  page
+}
+
+func (b *builder) add(blocks ...block) {
+	b.page.blocks = append(b.page.blocks, blocks...)
+}
+
+func (b *builder) module(m Module) {
+	b.add(heading{level: 1, text: m.Name})
+	b.docs(query.NewDocumentation(m.Docs, nil, ""))
+
+	if len(m.Functions) > 0 {
+		b.add(heading{level: 2, text: "Functions"})
+		for _, fn := range m.Functions {
+			b.function(3, fn.Name, fn)
+		}
+	}
+
+	if len(m.Types) > 0 {
+		b.add(heading{level: 2, text: "Types"})
+		for _, t := range m.Types {
+			b.add(heading{level: 3, id: t.Name, text: t.Name})
+			if len(t.Fields) > 0 {
+				b.add(caption{text: "Fields"})
+				b.symbols(t.Name+".", t.Fields)
+			}
+			for _, method := range t.Methods {
+				b.function(4, t.Name+"."+method.Name, method)
+			}
+		}
+	}
+
+	if len(m.Variables) > 0 {
+		b.add(heading{level: 2, text: "Variables"})
+		b.symbols("", m.Variables)
+	}
+}
+
+// function adds the signature and the docstring of a function.
+func (b *builder) function(level int, id string, fn query.Signature) {
+	b.add(heading{level: level, id: id, text: id})
+	b.add(codeBlock{code: fn.Name + fn.Label()})
+	b.docs(fn.Documentation())
+}
+
+// docs adds the sections of the documentation of a function or a module:
+// the description, a table of parameters, the return value with its type,
+// and the other sections of the docstring, with examples as code.
+func (b *builder) docs(d query.Documentation) {
+	b.text(d.Description)
+
+	if len(d.Params) > 0 {
+		t := table{header: []string{"Parameter", "Type", "Default", "Description"}}
+		for _, p := range d.Params {
+			row := [][]span{{{text: p.Name, code: true}}, nil, nil, b.inline(p.Desc)}
+			if p.TypeHint != "" {
+				row[1] = []span{b.typeSpan(p.TypeHint)}
+			}
+			if p.DefaultValue != "" {
+				row[2] = []span{{text: p.DefaultValue, code: true}}
+			}
+			t.rows = append(t.rows, row)
+		}
+		b.add(caption{text: "Parameters"}, t)
+	}
+
+	if d.Returns != "" || d.ReturnType != "" {
+		b.add(caption{text: "Returns"})
+		if d.ReturnType != "" {
+			b.add(paragraph{spans: []span{b.typeSpan(d.ReturnType)}})
+		}
+		b.text(d.Returns)
+	}
+
+	for _, section := range d.Sections {
+		b.add(caption{text: section.Title})
+		switch {
+		case len(section.Fields) > 0:
+			var items []listItem
+			for _, f := range section.Fields {
+				spans := append([]span{{text: f.Name, code: true}, {text: ": "}}, b.inline(f.Desc)...)
+				items = append(items, listItem{spans: spans})
+			}
+			b.add(list{items: items})
+		case section.Code:
+			b.add(codeBlock{code: section.Body})
+		default:
+			b.text(section.Body)
+		}
+	}
+}
+
+// symbols adds a list of variables or fields with their docstrings.
+func (b *builder) symbols(idPrefix string, symbols []query.Symbol) {
+	var items []listItem
+	for _, sym := range symbols {
+		spans := []span{{text: sym.Name, code: true}}
+		if detail := strings.Join(strings.Fields(sym.Detail), " "); detail != "" {
+			spans = append(spans, span{text: ": "})
+			spans = append(spans, b.inline(detail)...)
+		}
+		items = append(items, listItem{id: idPrefix + sym.Name, spans: spans})
+	}
+	b.add(list{items: items})
+}
+
+// typeSpan returns a type hint, linked to the documentation of the type if
+// it names one.
+func (b *builder) typeSpan(hint string) span {
+	return span{text: hint, code: true, link: b.index.resolve(b.page.name, hint)}
+}
+
+var inlineCode = regexp.MustCompile("`([^`]+)`")
+
+// inline splits text into spans, linking code that names a documented
+// symbol.
+func (b *builder) inline(text string) []span {
+	var spans []span
+	last := 0
+	for _, loc := range inlineCode.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] > last {
+			spans = append(spans, span{text: text[last:loc[0]]})
+		}
+		code := text[loc[2]:loc[3]]
+		spans = append(spans, span{text: code, code: true, link: b.index.resolve(b.page.name, code)})
+		last = loc[1]
+	}
+	if last < len(text) {
+		spans = append(spans, span{text: text[last:]})
+	}
+	return spans
+}
+
+// text adds free-form text of a docstring: paragraphs, and code that is
+// indented or fenced.
+func (b *builder) text(s string) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		j := i + 1
+		switch {
+		case strings.TrimSpace(line) == "":
+		case strings.HasPrefix(strings.TrimSpace(line), "```"):
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j]), "```") {
+				j++
+			}
+			b.add(codeBlock{code: deindent(lines[i+1 : j])})
+			j++
+		case isIndented(line):
+			for j < len(lines) && (isIndented(lines[j]) ||
+				strings.TrimSpace(lines[j]) == "" && j+1 < len(lines) && isIndented(lines[j+1])) {
+				j++
+			}
+			b.add(codeBlock{code: deindent(lines[i:j])})
+		default:
+			for j < len(lines) && strings.TrimSpace(lines[j]) != "" && !isIndented(lines[j]) &&
+				!strings.HasPrefix(lines[j], "```") {
+				j++
+			}
+			b.add(paragraph{spans: b.inline(strings.Join(lines[i:j], "\n"))})
+		}
+		i = j
+	}
+}
+
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
+
+// deindent removes the indentation the lines have in common.
+func deindent(lines []string) string {
+	common := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if common < 0 || n < common {
+			common = n
+		}
+	}
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= common && common > 0 {
+			line = line[common:]
+		}
+		result[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Trim(strings.Join(result, "\n"), "\n")
+}
@@ -0,0 +1,204 @@
+package docs
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Format is the format of rendered pages.
+type Format string
+
+const (
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+)
+
+func (f Format) ext() string {
+	if f == HTML {
+		return ".html"
+	}
+	return ".md"
+}
+
+// Page is a rendered page of the reference.
+type Page struct {
+	// Path is the file name of the page, e.g. "os.md".
+	Path    string
+	Content []byte
+}
+
+// Render renders an index page with the given title that lists the
+// modules, and a page for each module.
+func Render(title string, modules []Module, format Format) []Page {
+	var result []Page
+	for _, p := range pages(title, modules) {
+		r := renderer{format: format, page: p.name}
+		r.render(p)
+		result = append(result, Page{Path: p.name + format.ext(), Content: []byte(r.out.String())})
+	}
+	return result
+}
+
+type renderer struct {
+	format Format
+	page   string
+	out    strings.Builder
+}
+
+func (r *renderer) printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(&r.out, format, args...)
+}
+
+func (r *renderer) render(p page) {
+	if r.format == HTML {
+		r.printf("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(p.title))
+	}
+	for i, b := range p.blocks {
+		if r.format == Markdown && i > 0 {
+			r.printf("\n")
+		}
+		switch b := b.(type) {
+		case heading:
+			r.heading(b)
+		case caption:
+			r.caption(b)
+		case paragraph:
+			r.paragraph(b)
+		case codeBlock:
+			r.codeBlock(b)
+		case list:
+			r.list(b)
+		case table:
+			r.table(b)
+		}
+	}
+	if r.format == HTML {
+		r.printf("</body>\n</html>\n")
+	}
+}
+
+func (r *renderer) heading(h heading) {
+	if r.format == HTML {
+		id := ""
+		if h.id != "" {
+			id = fmt.Sprintf(" id=\"%s\"", html.EscapeString(h.id))
+		}
+		r.printf("<h%d%s>%s</h%d>\n", h.level, id, html.EscapeString(h.text), h.level)
+		return
+	}
+	if h.id != "" {
+		r.printf("<a id=\"%s\"></a>\n", h.id)
+	}
+	r.printf("%s %s\n", strings.Repeat("#", h.level), h.text)
+}
+
+func (r *renderer) caption(c caption) {
+	if r.format == HTML {
+		r.printf("<p><strong>%s</strong></p>\n", html.EscapeString(c.text))
+		return
+	}
+	r.printf("**%s**\n", c.text)
+}
+
+func (r *renderer) paragraph(p paragraph) {
+	if r.format == HTML {
+		r.printf("<p>%s</p>\n", r.spans(p.spans))
+		return
+	}
+	r.printf("%s\n", r.spans(p.spans))
+}
+
+func (r *renderer) codeBlock(c codeBlock) {
+	if r.format == HTML {
+		r.printf("<pre><code>%s</code></pre>\n", html.EscapeString(c.code))
+		return
+	}
+	r.printf("```python\n%s\n```\n", c.code)
+}
+
+func (r *renderer) list(l list) {
+	if r.format == HTML {
+		r.printf("<ul>\n")
+		for _, item := range l.items {
+			id := ""
+			if item.id != "" {
+				id = fmt.Sprintf(" id=\"%s\"", html.EscapeString(item.id))
+			}
+			r.printf("<li%s>%s</li>\n", id, r.spans(item.spans))
+		}
+		r.printf("</ul>\n")
+		return
+	}
+	for _, item := range l.items {
+		anchor := ""
+		if item.id != "" {
+			anchor = fmt.Sprintf("<a id=\"%s\"></a>", item.id)
+		}
+		r.printf("- %s%s\n", anchor, r.spans(item.spans))
+	}
+}
+
+func (r *renderer) table(t table) {
+	if r.format == HTML {
+		r.printf("<table>\n<thead>\n<tr>")
+		for _, h := range t.header {
+			r.printf("<th>%s</th>", html.EscapeString(h))
+		}
+		r.printf("</tr>\n</thead>\n<tbody>\n")
+		for _, row := range t.rows {
+			r.printf("<tr>")
+			for _, cell := range row {
+				r.printf("<td>%s</td>", r.spans(cell))
+			}
+			r.printf("</tr>\n")
+		}
+		r.printf("</tbody>\n</table>\n")
+		return
+	}
+	r.printf("| %s |\n|%s\n", strings.Join(t.header, " | "), strings.Repeat(" --- |", len(t.header)))
+	for _, row := range t.rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = strings.ReplaceAll(strings.ReplaceAll(r.spans(cell), "|", `\|`), "\n", " ")
+		}
+		r.printf("| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+func (r *renderer) spans(spans []span) string {
+	var sb strings.Builder
+	for _, s := range spans {
+		text := s.text
+		switch {
+		case r.format == HTML && s.code:
+			text = "<code>" + html.EscapeString(text) + "</code>"
+		case r.format == HTML:
+			text = html.EscapeString(text)
+		case s.code:
+			text = "`" + text + "`"
+		}
+		if s.link != nil {
+			href := r.href(*s.link)
+			if r.format == HTML {
+				text = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(href), text)
+			} else {
+				text = fmt.Sprintf("[%s](%s)", text, href)
+			}
+		}
+		sb.WriteString(text)
+	}
+	return sb.String()
+}
+
+// href returns the link to a target from the page being rendered.
+func (r *renderer) href(t target) string {
+	href := ""
+	if t.page != r.page {
+		href = t.page + r.format.ext()
+	}
+	if t.anchor != "" {
+		href += "#" + t.anchor
+	}
+	return href
+}
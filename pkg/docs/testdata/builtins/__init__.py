@@ -0,0 +1,32 @@
+def local_resource(name: str, cmd: str = "", *, deps: list = []) -> Resource:
+    """Runs `cmd` on the host.
+
+    The working directory is the directory of the Tiltfile, see `os.getcwd()`.
+
+        local_resource("build", cmd="make")
+
+    Args:
+      name: Name of the resource.
+      cmd: Command to run.
+      deps: Files that trigger an update of the resource.
+
+    Returns:
+      The resource, see `Resource.labels`.
+
+    Note:
+      Commands run with `sh -c`.
+    """
+    pass
+
+
+class Resource:
+    name: str = ""
+    """The name of the resource."""
+
+    def labels(self, labels: list) -> None:
+        """Adds labels to the resource."""
+        pass
+
+
+config_mode = ""
+"""How the Tiltfile is run, `up` or `ci`."""
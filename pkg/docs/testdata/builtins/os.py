@@ -0,0 +1,3 @@
+def getcwd() -> str:
+    """Returns the current working directory."""
+    pass
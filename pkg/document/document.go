@@ -11,16 +11,38 @@ import (
 	"go.lsp.dev/protocol"
 	"go.lsp.dev/uri"
 
+	"github.com/tilt-dev/starlark-lsp/pkg/docstring"
 	"github.com/tilt-dev/starlark-lsp/pkg/query"
 )
 
+// Codes of the diagnostics for load statements.
+const (
+	// LoadFailedCode is used when the loaded file can't be found or parsed.
+	LoadFailedCode = "load-failed"
+	// LoadSymbolNotFoundCode is used for symbols that the loaded file
+	// doesn't define.
+	LoadSymbolNotFoundCode = "load-symbol-not-found"
+	// LoadNotAllowedCode is used for load statements that aren't at the top
+	// level of the module.
+	LoadNotAllowedCode = "load-not-allowed"
+	// LoadInvalidArgumentCode is used for arguments that aren't string
+	// literals.
+	LoadInvalidArgumentCode = "load-invalid-argument"
+	// LoadNoSymbolsCode is used for load statements without symbols.
+	LoadNoSymbolsCode = "load-no-symbols"
+)
+
 type LoadSymbol struct {
 	Alias, Name string
 	Range       protocol.Range
 }
 
 type LoadStatement struct {
-	File        string
+	File string
+	// URI is the resolved location of File, once the load has been followed.
+	URI uri.URI
+	// Docs is the docstring of the loaded module.
+	Docs        docstring.Parsed
 	Symbols     []LoadSymbol
 	Range       protocol.Range
 	Diagnostics []protocol.Diagnostic
@@ -157,15 +179,17 @@ func (d *document) followLoads(ctx context.Context, m *Manager, parseState Docum
 		if load.File == "" {
 			continue
 		}
-		path, err := resolvePath(load.File, d.uri)
+		candidates, err := m.loadCandidates(load.File, d.uri)
 		var dep Document
 		if err == nil {
-			dep, err = m.readAndParse(ctx, path, parseState)
+			d.loads[i].URI = candidates[0]
+			dep, err = m.readLoad(ctx, load.File, candidates, parseState)
 		}
 		if err != nil {
 			diag := protocol.Diagnostic{
 				Range:    load.Range,
 				Severity: protocol.DiagnosticSeverityError,
+				Code:     LoadFailedCode,
 				Message:  err.Error(),
 			}
 
@@ -184,6 +208,8 @@ func (d *document) followLoads(ctx context.Context, m *Manager, parseState Docum
 			}
 			continue
 		}
+		d.loads[i].URI = dep.URI()
+		d.loads[i].Docs = query.ModuleDocstring(dep)
 		if !load.processed {
 			d.processLoad(dep, load, m)
 			d.loads[i].processed = true
@@ -194,7 +220,7 @@ func (d *document) followLoads(ctx context.Context, m *Manager, parseState Docum
 func (d *document) processLoad(dep Document, load LoadStatement, m *Manager) {
 	fns := dep.Functions()
 	symMap := make(map[string]query.Symbol)
-	u, err := m.Resolve(dep.URI())
+	u, err := m.resolveFrom(dep.URI(), d.uri)
 	for _, s := range dep.Symbols() {
 		symMap[s.Name] = s
 	}
@@ -214,6 +240,7 @@ func (d *document) processLoad(dep Document, load LoadStatement, m *Manager) {
 			d.diagnostics = append(d.diagnostics, protocol.Diagnostic{
 				Range:    ls.Range,
 				Severity: protocol.DiagnosticSeverityWarning,
+				Code:     LoadSymbolNotFoundCode,
 				Message:  fmt.Sprintf("symbol '%s' not found in %s", ls.Name, load.File),
 			})
 		}
@@ -249,6 +276,7 @@ func (d *document) parseLoadStatements() {
 			d.diagnostics = append(d.diagnostics, protocol.Diagnostic{
 				Range:    query.NodeRange(n),
 				Severity: protocol.DiagnosticSeverityError,
+				Code:     LoadNotAllowedCode,
 				Message:  fmt.Sprintf("load statement not allowed in %s", withArticle(strings.ReplaceAll(parent.Type(), "_", " "))),
 			})
 		}
@@ -263,6 +291,7 @@ func loadStatement(input []byte, n *sitter.Node) (LoadStatement, []protocol.Diag
 		return protocol.Diagnostic{
 			Range:    query.NodeRange(nn),
 			Severity: protocol.DiagnosticSeverityError,
+			Code:     LoadInvalidArgumentCode,
 			Message:  fmt.Sprintf("load parameter must be a string literal, found '%s'", nn.Content(input)),
 		}
 	}
@@ -310,6 +339,7 @@ func loadStatement(input []byte, n *sitter.Node) (LoadStatement, []protocol.Diag
 		diagnostics = append(diagnostics, protocol.Diagnostic{
 			Range:    query.NodeRange(n),
 			Severity: protocol.DiagnosticSeverityWarning,
+			Code:     LoadNoSymbolsCode,
 			Message:  "load statement did not specify any symbols to import",
 		})
 	}
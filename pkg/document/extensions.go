@@ -0,0 +1,281 @@
+package document
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+const (
+	// ExtensionScheme is the scheme of the load paths of Tilt extensions,
+	// e.g. `load("ext://restart_process", "docker_build_with_restart")`.
+	ExtensionScheme = "ext"
+	// DefaultExtensionRepo is the name of the repository extensions are
+	// loaded from unless they are registered with another one.
+	DefaultExtensionRepo = "default"
+	// DefaultExtensionRepoURL is the URL of the default repository.
+	DefaultExtensionRepoURL = "https://github.com/tilt-dev/tilt-extensions"
+)
+
+type extensionRepo struct {
+	url, loadHost string
+}
+
+type extension struct {
+	repo, path string
+}
+
+// extensionRegistrations are the repositories and extensions registered by
+// a document with `v1alpha1.extension_repo()` and `v1alpha1.extension()`.
+type extensionRegistrations struct {
+	repos      map[string]extensionRepo
+	extensions map[string]extension
+}
+
+// Extensions resolves the ext:// URIs of Tilt extensions to the Tiltfiles in
+// local checkouts of extension repositories, without downloading them.
+//
+// Repositories with file: URLs are used in place, others are looked up in
+// the cache directory Tilt downloads them to, e.g.
+// "~/.tilt-dev/tilt_modules/github.com/tilt-dev/tilt-extensions".
+type Extensions struct {
+	mu       sync.Mutex
+	cacheDir string
+	repos    map[string]extensionRepo
+	// registered are the registrations of each document
+	registered map[uri.URI]extensionRegistrations
+}
+
+// NewExtensions creates a resolver for extensions cached in the directory,
+// or in the directory used by Tilt if it's empty. Repositories map the names
+// of additional repositories to their URLs; they can also replace the
+// default repository, e.g. with a local checkout:
+//
+//	NewExtensions("", map[string]string{"default": "file:///src/tilt-extensions"})
+func NewExtensions(cacheDir string, repos map[string]string) *Extensions {
+	if cacheDir == "" {
+		cacheDir = DefaultExtensionsDir()
+	}
+	e := &Extensions{
+		cacheDir:   cacheDir,
+		repos:      map[string]extensionRepo{DefaultExtensionRepo: {url: DefaultExtensionRepoURL}},
+		registered: make(map[uri.URI]extensionRegistrations),
+	}
+	for name, u := range repos {
+		e.repos[name] = extensionRepo{url: u}
+	}
+	return e
+}
+
+// DefaultExtensionsDir is the directory Tilt downloads extension
+// repositories to.
+func DefaultExtensionsDir() string {
+	dir := os.Getenv("TILT_DEV_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".tilt-dev")
+	}
+	return filepath.Join(dir, "tilt_modules")
+}
+
+// WithExtensions resolves ext:// load paths with the extensions, and
+// registers the repositories and extensions of the documents it parses.
+// File URIs are still read and resolved like by default.
+func WithExtensions(e *Extensions) ManagerOpt {
+	return func(manager *Manager) {
+		manager.extensions = e
+		manager.readDocFunc = e.ReadDocument
+		manager.resolveUriFunc = e.ResolveURI
+	}
+}
+
+// ReadDocument is a ReadDocumentFunc that reads the Tiltfiles of ext:// URIs
+// and otherwise falls back to ReadDocument.
+func (e *Extensions) ReadDocument(u uri.URI) ([]byte, error) {
+	if !isExtensionURI(u) {
+		return ReadDocument(u)
+	}
+	fn, err := e.ResolveURI(u)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(fn)
+}
+
+// ResolveURI is a ResolveURIFunc that resolves ext:// URIs to the path of
+// the extension's Tiltfile and otherwise falls back to ResolveURI.
+//
+// Like Tilt, an extension is looked up in the repository it was registered
+// with, in the repository whose load host is the first element of its name,
+// or in the default repository, in that order.
+func (e *Extensions) ResolveURI(u uri.URI) (string, error) {
+	if !isExtensionURI(u) {
+		return ResolveURI(u)
+	}
+	name := strings.Trim(strings.TrimPrefix(string(u), ExtensionScheme+"://"), "/")
+	if name == "" {
+		return "", fmt.Errorf("missing extension name: %s", u)
+	}
+
+	e.mu.Lock()
+	repos, extensions := e.merged()
+	e.mu.Unlock()
+
+	ext, found := extensions[name]
+	if !found {
+		ext = extension{repo: DefaultExtensionRepo, path: name}
+		if host, rest, ok := strings.Cut(name, "/"); ok {
+			for repoName, repo := range repos {
+				if repo.loadHost == host {
+					ext = extension{repo: repoName, path: rest}
+					break
+				}
+			}
+		}
+	}
+	repo, found := repos[ext.repo]
+	if !found {
+		return "", fmt.Errorf("unknown extension repo %q", ext.repo)
+	}
+	dir, err := e.repoDir(repo.url)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.FromSlash(ext.path), "Tiltfile"), nil
+}
+
+// repoDir is the local directory of the repository with the URL.
+func (e *Extensions) repoDir(repoURL string) (string, error) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid extension repo URL %q: %v", repoURL, err)
+	}
+	if parsed.Scheme == "file" {
+		return filepath.FromSlash(parsed.Path), nil
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("invalid extension repo URL %q", repoURL)
+	}
+	return filepath.Join(e.cacheDir, parsed.Host, filepath.FromSlash(strings.TrimSuffix(parsed.Path, ".git"))), nil
+}
+
+// merged combines the configured repositories with the registrations of all
+// documents. Registrations of documents that sort later take precedence.
+func (e *Extensions) merged() (map[string]extensionRepo, map[string]extension) {
+	repos := make(map[string]extensionRepo, len(e.repos))
+	for name, repo := range e.repos {
+		repos[name] = repo
+	}
+	extensions := make(map[string]extension)
+
+	keys := make([]string, 0, len(e.registered))
+	for u := range e.registered {
+		keys = append(keys, string(u))
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r := e.registered[uri.URI(k)]
+		for name, repo := range r.repos {
+			repos[name] = repo
+		}
+		for name, ext := range r.extensions {
+			extensions[name] = ext
+		}
+	}
+	return repos, extensions
+}
+
+// register records the repositories and extensions registered by the
+// document and reports whether they changed.
+func (e *Extensions) register(doc Document) bool {
+	r := extensionCalls(doc.Input(), doc.Tree())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	existing, found := e.registered[doc.URI()]
+	if len(r.repos) == 0 && len(r.extensions) == 0 {
+		delete(e.registered, doc.URI())
+		return found
+	}
+	e.registered[doc.URI()] = r
+	return !found || !reflect.DeepEqual(existing, r)
+}
+
+// unregister forgets the registrations of the document and reports whether
+// there were any.
+func (e *Extensions) unregister(u uri.URI) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, found := e.registered[u]
+	delete(e.registered, u)
+	return found
+}
+
+func isExtensionURI(u uri.URI) bool {
+	return strings.HasPrefix(string(u), ExtensionScheme+"://")
+}
+
+// extensionCalls finds the calls of `v1alpha1.extension_repo()` and
+// `v1alpha1.extension()` whose arguments are string literals.
+func extensionCalls(input []byte, tree *sitter.Tree) extensionRegistrations {
+	r := extensionRegistrations{
+		repos:      make(map[string]extensionRepo),
+		extensions: make(map[string]extension),
+	}
+	query.Query(tree.RootNode(), `(call) @call`, func(q *sitter.Query, match *sitter.QueryMatch) bool {
+		for _, c := range match.Captures {
+			switch c.Node.ChildByFieldName("function").Content(input) {
+			case "v1alpha1.extension_repo":
+				args := stringArguments(input, c.Node, "name", "url", "ref", "load_host")
+				if args["name"] != "" && args["url"] != "" {
+					r.repos[args["name"]] = extensionRepo{url: args["url"], loadHost: args["load_host"]}
+				}
+			case "v1alpha1.extension":
+				args := stringArguments(input, c.Node, "name", "repo_name", "repo_path")
+				if args["name"] != "" && args["repo_name"] != "" {
+					r.extensions[args["name"]] = extension{repo: args["repo_name"], path: args["repo_path"]}
+				}
+			}
+		}
+		return true
+	})
+	return r
+}
+
+// stringArguments returns the string literal arguments of a call by the
+// names of the parameters, given in order.
+func stringArguments(input []byte, call *sitter.Node, params ...string) map[string]string {
+	args := make(map[string]string)
+	argsNode := call.ChildByFieldName("arguments")
+	if argsNode == nil {
+		return args
+	}
+	for i := 0; i < int(argsNode.NamedChildCount()); i++ {
+		arg := argsNode.NamedChild(i)
+		switch arg.Type() {
+		case query.NodeTypeString:
+			if i < len(params) {
+				args[params[i]] = query.Unquote(input, arg)
+			}
+		case query.NodeTypeKeywordArgument:
+			value := arg.ChildByFieldName("value")
+			if value != nil && value.Type() == query.NodeTypeString {
+				args[arg.ChildByFieldName("name").Content(input)] = query.Unquote(input, value)
+			}
+		}
+	}
+	return args
+}
@@ -0,0 +1,163 @@
+package document
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"go.lsp.dev/uri"
+)
+
+// LoadPaths configures where the paths of load statements are looked up,
+// in addition to relative to the loading file.
+type LoadPaths struct {
+	// Aliases map prefixes of load paths to directories, e.g. "@lib" to
+	// "/repo/third_party/lib", so that `load("@lib/k8s.star", ...)` loads
+	// "/repo/third_party/lib/k8s.star". A prefix can also be a URI scheme
+	// like "ext://". If the rest of the path is a directory, its Tiltfile is
+	// loaded, like Tilt does for extensions.
+	Aliases map[string]string
+	// SearchPaths are directories where relative load paths are looked up,
+	// in order, if they don't exist relative to the loading file.
+	SearchPaths []string
+}
+
+// WithLoadPaths sets the load paths of documents outside of the workspace
+// folders configured with SetLoadPaths.
+func WithLoadPaths(paths LoadPaths) ManagerOpt {
+	return func(manager *Manager) {
+		manager.loadPaths[""] = paths
+	}
+}
+
+// SetLoadPaths sets the load paths of the documents within a workspace
+// folder. If they changed, documents in the folder that were read from disk
+// are dropped and documents that are open in the editor are parsed again,
+// so that their loads are resolved with the new paths.
+func (m *Manager) SetLoadPaths(ctx context.Context, root uri.URI, paths LoadPaths) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	root = canonicalFileURI(root, "")
+	existing, found := m.loadPaths[root]
+	if found && reflect.DeepEqual(existing, paths) || !found && paths.IsEmpty() {
+		return
+	}
+	m.loadPaths[root] = paths
+
+	var written []Document
+	for u, doc := range m.docs {
+		if m.rootOf(u) != root {
+			continue
+		}
+		if m.written[u] {
+			written = append(written, doc)
+		} else {
+			m.removeAndCleanup(u)
+		}
+	}
+	for _, doc := range written {
+		u, input := doc.URI(), doc.Input()
+		m.removeAndCleanup(u)
+		_, _ = m.parse(ctx, u, input, nil)
+	}
+}
+
+// IsEmpty reports whether there are neither aliases nor search paths.
+func (p LoadPaths) IsEmpty() bool {
+	return len(p.Aliases) == 0 && len(p.SearchPaths) == 0
+}
+
+// alias finds the alias with the longest prefix of the path and returns the
+// path it resolves to.
+func (p LoadPaths) alias(path string) (string, bool) {
+	var prefix string
+	for candidate := range p.Aliases {
+		if len(candidate) > len(prefix) && hasAliasPrefix(path, candidate) {
+			prefix = candidate
+		}
+	}
+	if prefix == "" {
+		return "", false
+	}
+	rest := strings.TrimLeft(strings.TrimPrefix(path, prefix), "/")
+	resolved := filepath.Join(p.Aliases[prefix], filepath.FromSlash(rest))
+	if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+		resolved = filepath.Join(resolved, "Tiltfile")
+	}
+	return resolved, true
+}
+
+// hasAliasPrefix reports whether the path starts with the prefix, as a
+// whole path element: "@lib" is a prefix of "@lib/k8s.star" but not of
+// "@library/k8s.star".
+func hasAliasPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	if len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, ":") {
+		return true
+	}
+	return path[len(prefix)] == '/'
+}
+
+// loadPathsFor returns the load paths of the workspace folder that contains
+// the document.
+func (m *Manager) loadPathsFor(u uri.URI) LoadPaths {
+	if paths, found := m.loadPaths[m.rootOf(u)]; found {
+		return paths
+	}
+	return m.loadPaths[""]
+}
+
+// loadCandidates returns the locations a load path may refer to, in the
+// order they are tried. An aliased path only has a single location.
+func (m *Manager) loadCandidates(path string, relativeTo uri.URI) ([]uri.URI, error) {
+	paths := m.loadPathsFor(relativeTo)
+	if resolved, found := paths.alias(path); found {
+		return []uri.URI{uri.File(resolved)}, nil
+	}
+
+	if _, err := filename(relativeTo); err != nil {
+		// paths in documents that aren't files, like extensions, are
+		// relative to the files they resolve to
+		if fn, err := m.resolveUriFunc(relativeTo); err == nil {
+			relativeTo = uri.File(fn)
+		}
+	}
+	u, err := resolvePath(path, relativeTo)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []uri.URI{u}
+	if !strings.Contains(path, "://") && !filepath.IsAbs(path) {
+		for _, dir := range paths.SearchPaths {
+			candidates = append(candidates, uri.File(filepath.Join(dir, filepath.FromSlash(path))))
+		}
+	}
+	return candidates, nil
+}
+
+// readLoad reads and parses the first of the candidates that exists.
+func (m *Manager) readLoad(ctx context.Context, path string, candidates []uri.URI, parseState DocumentMap) (Document, error) {
+	for _, u := range candidates {
+		doc, err := m.readAndParse(ctx, u, parseState)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return doc, err
+		}
+	}
+
+	tried := make([]string, len(candidates))
+	for i, u := range candidates {
+		if fn, err := m.resolveUriFunc(u); err == nil {
+			tried[i] = fn
+		} else {
+			tried[i] = string(u)
+		}
+	}
+	return nil, fmt.Errorf("cannot find %q, tried: %s", path, strings.Join(tried, ", "))
+}
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"go.lsp.dev/protocol"
@@ -21,20 +22,32 @@ type DocumentMap map[uri.URI]Document
 
 // Manager provides simplified file read/write operations for the LSP server.
 type Manager struct {
-	mu             sync.Mutex
-	root           uri.URI
-	docs           DocumentMap
+	mu sync.Mutex
+	// roots are the workspace folders opened in the editor. Relative URIs
+	// are resolved against the one they belong to, see base.
+	roots []uri.URI
+	docs  DocumentMap
+	// written tracks the documents whose contents came from the editor
+	// rather than from disk.
+	written        map[uri.URI]bool
 	newDocFunc     NewDocumentFunc
 	readDocFunc    ReadDocumentFunc
 	resolveUriFunc ResolveURIFunc
+	// loadPaths are the load paths of each workspace folder, and of
+	// documents outside of them under ""
+	loadPaths map[uri.URI]LoadPaths
+	// extensions resolves ext:// URIs, if enabled with WithExtensions
+	extensions *Extensions
 }
 
 func NewDocumentManager(opts ...ManagerOpt) *Manager {
 	m := Manager{
 		docs:           make(DocumentMap),
+		written:        make(map[uri.URI]bool),
 		newDocFunc:     NewDocument,
 		readDocFunc:    ReadDocument,
 		resolveUriFunc: ResolveURI,
+		loadPaths:      make(map[uri.URI]LoadPaths),
 	}
 
 	for _, opt := range opts {
@@ -118,14 +131,136 @@ func canonicalFileURI(u uri.URI, base uri.URI) uri.URI {
 func (m *Manager) Initialize(params *protocol.InitializeParams) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if len(params.WorkspaceFolders) > 0 {
-		m.root = uri.URI(params.WorkspaceFolders[0].URI)
-	} else {
+	m.roots = nil
+	for _, folder := range params.WorkspaceFolders {
+		m.addRoot(uri.URI(folder.URI))
+	}
+	if len(m.roots) == 0 && params.RootURI != "" {
+		m.addRoot(uri.URI(params.RootURI))
+	}
+	if len(m.roots) == 0 {
 		dir, err := os.Getwd()
 		if err == nil {
-			m.root = uri.File(dir)
+			m.addRoot(uri.File(dir))
+		}
+	}
+}
+
+// AddRoot adds a workspace folder.
+func (m *Manager) AddRoot(u uri.URI) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.addRoot(u)
+}
+
+func (m *Manager) addRoot(u uri.URI) {
+	u = canonicalFileURI(u, "")
+	for _, root := range m.roots {
+		if root == u {
+			return
+		}
+	}
+	m.roots = append(m.roots, u)
+}
+
+// RemoveRoot removes a workspace folder. Documents within the folder that
+// were read from disk are dropped, documents that are open in the editor
+// are kept until they are closed.
+func (m *Manager) RemoveRoot(u uri.URI) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u = canonicalFileURI(u, "")
+	for i, root := range m.roots {
+		if root == u {
+			m.roots = append(m.roots[:i:i], m.roots[i+1:]...)
+			break
+		}
+	}
+	for docURI := range m.docs {
+		if inRoot(docURI, u) && !m.written[docURI] {
+			m.removeAndCleanup(docURI)
 		}
 	}
+	delete(m.loadPaths, u)
+}
+
+// Roots returns the workspace folders.
+func (m *Manager) Roots() []uri.URI {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]uri.URI{}, m.roots...)
+}
+
+// Root returns the workspace folder that contains the document, or "" if it
+// isn't in any of them. If folders are nested, the innermost one is used.
+func (m *Manager) Root(u uri.URI) uri.URI {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rootOf(m.canonical(u))
+}
+
+func (m *Manager) rootOf(u uri.URI) uri.URI {
+	var result uri.URI
+	for _, root := range m.roots {
+		if inRoot(u, root) && len(root) > len(result) {
+			result = root
+		}
+	}
+	return result
+}
+
+// base is the folder a relative path is resolved against: the workspace
+// folder of the document it's relative to, if given and in one, or else the
+// first folder the path exists in, falling back to the first folder.
+func (m *Manager) base(path string, relativeTo uri.URI) uri.URI {
+	if len(m.roots) == 0 {
+		return ""
+	}
+	if relativeTo != "" {
+		if root := m.rootOf(canonicalFileURI(relativeTo, "")); root != "" {
+			return root
+		}
+	}
+	for _, root := range m.roots {
+		rootPath, err := filename(root)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(rootPath, path)); err == nil {
+			return root
+		}
+	}
+	return m.roots[0]
+}
+
+// canonical resolves the URI to an absolute file URI without symlinks, so
+// that the same document is always stored under the same key.
+func (m *Manager) canonical(u uri.URI) uri.URI {
+	return m.canonicalFrom(u, "")
+}
+
+// canonicalFrom is like canonical, but resolves a relative URI against the
+// workspace folder of the document it's relative to.
+func (m *Manager) canonicalFrom(u, relativeTo uri.URI) uri.URI {
+	fn, err := filename(u)
+	if err != nil || filepath.IsAbs(fn) {
+		return canonicalFileURI(u, "")
+	}
+	return canonicalFileURI(u, m.base(fn, relativeTo))
+}
+
+// inRoot reports whether the URI is within the folder.
+func inRoot(u, root uri.URI) bool {
+	fn, err := filename(u)
+	if err != nil {
+		return false
+	}
+	rootPath, err := filename(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(rootPath, fn)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 // Read returns the contents of the file for the given URI.
@@ -141,7 +276,7 @@ func (m *Manager) Read(ctx context.Context, u uri.URI) (doc Document, err error)
 		}
 		m.mu.Unlock()
 	}()
-	u = canonicalFileURI(u, m.root)
+	u = m.canonical(u)
 
 	// TODO(siegs): check staleness for files read from disk?
 	var found bool
@@ -160,8 +295,9 @@ func (m *Manager) Read(ctx context.Context, u uri.URI) (doc Document, err error)
 func (m *Manager) Write(ctx context.Context, u uri.URI, input []byte) (diags []protocol.Diagnostic, err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	u = canonicalFileURI(u, m.root)
+	u = m.canonical(u)
 	m.removeAndCleanup(u)
+	m.written[u] = true
 	doc, err := m.parse(ctx, u, input, nil)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse file %q: %v", u, err)
@@ -172,17 +308,46 @@ func (m *Manager) Write(ctx context.Context, u uri.URI, input []byte) (diags []p
 func (m *Manager) Remove(u uri.URI) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	u = canonicalFileURI(u, m.root)
+	u = m.canonical(u)
 	m.removeAndCleanup(u)
+	delete(m.written, u)
+	if m.extensions != nil && m.extensions.unregister(u) {
+		m.removeExtensions()
+	}
+}
+
+// Close removes all documents and frees their parse trees, e.g. once the
+// server shuts down.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for u := range m.docs {
+		m.removeAndCleanup(u)
+	}
+	m.written = make(map[uri.URI]bool)
 }
 
 // Resolve the given URI to a file:// URI, or return error if the URI can't be resolved to a file.
+// A relative path it resolves to is relative to the workspace folder it exists in.
 func (m *Manager) Resolve(u uri.URI) (uri.URI, error) {
+	return m.resolveFrom(u, "")
+}
+
+// resolveFrom is like Resolve, but resolves a relative path against the
+// workspace folder of the document that refers to the URI.
+func (m *Manager) resolveFrom(u, relativeTo uri.URI) (uri.URI, error) {
 	f, err := m.resolveUriFunc(u)
 	if err != nil {
 		return "", err
 	}
-	return canonicalFileURI(uri.File(f), m.root), nil
+	if !filepath.IsAbs(f) {
+		if base := m.base(f, relativeTo); base != "" {
+			if basePath, err := filename(base); err == nil {
+				f = filepath.Join(basePath, f)
+			}
+		}
+	}
+	return canonicalFileURI(uri.File(f), ""), nil
 }
 
 func (m *Manager) Keys() []uri.URI {
@@ -197,7 +362,7 @@ func (m *Manager) Keys() []uri.URI {
 
 func (m *Manager) readAndParse(ctx context.Context, u uri.URI, parseState DocumentMap) (doc Document, err error) {
 	var contents []byte
-	u = canonicalFileURI(u, m.root)
+	u = m.canonical(u)
 	if _, found := m.docs[u]; !found {
 		contents, err = m.readDocFunc(u)
 		if err != nil {
@@ -224,6 +389,9 @@ func (m *Manager) parse(ctx context.Context, uri uri.URI, input []byte, parseSta
 		}
 
 		doc = m.newDocFunc(uri, input, tree)
+		if m.extensions != nil && m.extensions.register(doc) {
+			m.removeExtensions()
+		}
 	}
 
 	parseState[uri] = doc
@@ -235,6 +403,16 @@ func (m *Manager) parse(ctx context.Context, uri uri.URI, input []byte, parseSta
 	return doc, err
 }
 
+// removeExtensions drops the extensions read so far, so that they are read
+// again from the repositories they are registered with.
+func (m *Manager) removeExtensions() {
+	for u := range m.docs {
+		if isExtensionURI(u) && !m.written[u] {
+			m.removeAndCleanup(u)
+		}
+	}
+}
+
 // removeAndCleanup removes a Document and frees associated resources.
 func (m *Manager) removeAndCleanup(uri uri.URI) {
 	if existing, ok := m.docs[uri]; ok {
@@ -11,6 +11,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.lsp.dev/protocol"
 	"go.lsp.dev/uri"
 )
 
@@ -197,6 +198,285 @@ func TestReadLoadResolve(t *testing.T) {
 	assert.Equal(t, uri.File(hello), syms[0].Location.URI)
 }
 
+func TestManagerRoots(t *testing.T) {
+	f := newFixture(t)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	for _, dir := range []string{"app", "app/lib", "other"} {
+		require.NoError(t, os.Mkdir(dir, 0755))
+	}
+	app := uri.File(filepath.Join(cwd, "app"))
+	lib := uri.File(filepath.Join(cwd, "app", "lib"))
+	other := uri.File(filepath.Join(cwd, "other"))
+
+	f.m.Initialize(&protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: string(app)}, {URI: string(lib)}},
+	})
+	f.m.AddRoot(other)
+	f.m.AddRoot(app)
+	assert.Equal(t, []uri.URI{app, lib, other}, f.m.Roots())
+
+	assert.Equal(t, app, f.m.Root(uri.File(filepath.Join(cwd, "app", "Tiltfile"))))
+	assert.Equal(t, lib, f.m.Root(uri.File(filepath.Join(cwd, "app", "lib", "util.star"))))
+	assert.Equal(t, other, f.m.Root(uri.File(filepath.Join(cwd, "other", "Tiltfile"))))
+	assert.Equal(t, uri.URI(""), f.m.Root(uri.File(filepath.Join(cwd, "application", "Tiltfile"))))
+}
+
+func TestManagerResolveRelativeToRoot(t *testing.T) {
+	// paths that the resolver returns relative to a workspace folder
+	f := newFixture(t)
+	f.m.resolveUriFunc = func(u uri.URI) (string, error) {
+		return strings.TrimPrefix(string(u), "lib://"), nil
+	}
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	for _, file := range []string{"svc-a/lib/util.star", "svc-b/lib/util.star", "svc-b/lib/only-b.star"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		require.NoError(t, os.WriteFile(file, []byte("x = 1"), 0644))
+	}
+	svcA := uri.File(filepath.Join(cwd, "svc-a"))
+	svcB := uri.File(filepath.Join(cwd, "svc-b"))
+	f.m.Initialize(&protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: string(svcA)}, {URI: string(svcB)}},
+	})
+
+	// against the folder of the document that refers to it
+	u, err := f.m.resolveFrom("lib://lib/util.star", uri.File(filepath.Join(cwd, "svc-b", "Tiltfile")))
+	require.NoError(t, err)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "svc-b", "lib", "util.star")), u)
+	u, err = f.m.resolveFrom("lib://lib/util.star", uri.File(filepath.Join(cwd, "svc-a", "Tiltfile")))
+	require.NoError(t, err)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "svc-a", "lib", "util.star")), u)
+
+	// against the folder it exists in
+	u, err = f.m.Resolve("lib://lib/only-b.star")
+	require.NoError(t, err)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "svc-b", "lib", "only-b.star")), u)
+
+	// loaded symbols are located in the folder of the document loading them
+	f.m.readDocFunc = func(u uri.URI) ([]byte, error) {
+		fn, err := f.m.resolveUriFunc(u)
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(filepath.Join(cwd, "svc-b", fn))
+	}
+	tiltfile := uri.File(filepath.Join(cwd, "svc-b", "Tiltfile"))
+	_, err = f.m.Write(f.ctx, tiltfile, []byte("load('lib://lib/util.star', 'x')"))
+	require.NoError(t, err)
+	doc, err := f.m.Read(f.ctx, tiltfile)
+	require.NoError(t, err)
+	defer doc.Close()
+	require.Len(t, doc.Symbols(), 1)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "svc-b", "lib", "util.star")), doc.Symbols()[0].Location.URI)
+}
+
+func TestManagerRemoveRoot(t *testing.T) {
+	f := newFixture(t)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir("other", 0755))
+	require.NoError(t, os.WriteFile("other/lib.star", []byte("x = 1"), 0644))
+	other := uri.File(filepath.Join(cwd, "other"))
+	f.m.AddRoot(other)
+
+	open := uri.File(filepath.Join(cwd, "other", "Tiltfile"))
+	_, err = f.m.Write(f.ctx, open, []byte("load('lib.star', 'x')"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uri.URI{open, uri.File(filepath.Join(cwd, "other", "lib.star"))}, f.m.Keys())
+
+	f.m.RemoveRoot(other)
+	assert.Empty(t, f.m.Roots())
+	assert.Equal(t, []uri.URI{open}, f.m.Keys())
+}
+
+func TestManagerClose(t *testing.T) {
+	f := newFixture(t)
+	require.NoError(t, os.WriteFile("lib.star", []byte("x = 1"), 0644))
+	_, err := f.m.Write(f.ctx, uri.File("Tiltfile"), []byte("load('lib.star', 'x')"))
+	require.NoError(t, err)
+	assert.Len(t, f.m.Keys(), 2)
+
+	f.m.Close()
+	assert.Empty(t, f.m.Keys())
+
+	// documents are read from disk again
+	doc, err := f.m.Read(f.ctx, uri.File("lib.star"))
+	require.NoError(t, err)
+	defer doc.Close()
+	assert.Equal(t, "x = 1", doc.Content(doc.Tree().RootNode()))
+}
+
+func TestLoadAliases(t *testing.T) {
+	f := newFixture(t)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	for _, dir := range []string{"vendor", "vendor/lib", "vendor/exts", "vendor/exts/restart_process"} {
+		require.NoError(t, os.Mkdir(dir, 0755))
+	}
+	require.NoError(t, os.WriteFile("vendor/lib/k8s.star", []byte("k8s = 1"), 0644))
+	require.NoError(t, os.WriteFile("vendor/exts/restart_process/Tiltfile", []byte("restart = 1"), 0644))
+	WithLoadPaths(LoadPaths{Aliases: map[string]string{
+		"@lib":   filepath.Join(cwd, "vendor", "lib"),
+		"ext://": filepath.Join(cwd, "vendor", "exts"),
+	}})(f.m)
+
+	require.NoError(t, os.WriteFile("Tiltfile", []byte(`
+load("@lib/k8s.star", "k8s")
+load("ext://restart_process", "restart")
+load("@library/k8s.star", "k8s")
+`), 0644))
+	doc, err := f.m.Read(f.ctx, uri.File("Tiltfile"))
+	require.NoError(t, err)
+
+	loads := doc.Loads()
+	require.Len(t, loads, 3)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "vendor", "lib", "k8s.star")), loads[0].URI)
+	assert.Empty(t, loads[0].Diagnostics)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "vendor", "exts", "restart_process", "Tiltfile")), loads[1].URI)
+	assert.Empty(t, loads[1].Diagnostics)
+	// "@lib" isn't a prefix of "@library"
+	require.Len(t, loads[2].Diagnostics, 1)
+	assert.Equal(t, fmt.Sprintf(`cannot find "@library/k8s.star", tried: %s`, filepath.Join(cwd, "@library", "k8s.star")),
+		loads[2].Diagnostics[0].Message)
+	assert.Equal(t, LoadFailedCode, loads[2].Diagnostics[0].Code)
+}
+
+func TestLoadSearchPaths(t *testing.T) {
+	f := newFixture(t)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	for _, dir := range []string{"first", "second", "app"} {
+		require.NoError(t, os.Mkdir(dir, 0755))
+	}
+	require.NoError(t, os.WriteFile("first/a.star", []byte("a = 'first'"), 0644))
+	require.NoError(t, os.WriteFile("second/a.star", []byte("a = 'second'"), 0644))
+	require.NoError(t, os.WriteFile("second/b.star", []byte("b = 'second'"), 0644))
+	require.NoError(t, os.WriteFile("app/b.star", []byte("b = 'app'"), 0644))
+	WithLoadPaths(LoadPaths{SearchPaths: []string{filepath.Join(cwd, "first"), filepath.Join(cwd, "second")}})(f.m)
+
+	require.NoError(t, os.WriteFile("app/Tiltfile", []byte(`
+load("a.star", "a")
+load("b.star", "b")
+load("c.star", "c")
+`), 0644))
+	doc, err := f.m.Read(f.ctx, uri.File("app/Tiltfile"))
+	require.NoError(t, err)
+
+	loads := doc.Loads()
+	require.Len(t, loads, 3)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "first", "a.star")), loads[0].URI)
+	// files next to the loading file take precedence
+	assert.Equal(t, uri.File(filepath.Join(cwd, "app", "b.star")), loads[1].URI)
+	require.Len(t, loads[2].Diagnostics, 1)
+	assert.Equal(t, fmt.Sprintf(`cannot find "c.star", tried: %s, %s, %s`,
+		filepath.Join(cwd, "app", "c.star"), filepath.Join(cwd, "first", "c.star"), filepath.Join(cwd, "second", "c.star")),
+		loads[2].Diagnostics[0].Message)
+}
+
+func TestSetLoadPaths(t *testing.T) {
+	f := newFixture(t)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	for _, dir := range []string{"app", "lib"} {
+		require.NoError(t, os.Mkdir(dir, 0755))
+	}
+	require.NoError(t, os.WriteFile("lib/k8s.star", []byte("k8s = 1"), 0644))
+	app := uri.File(filepath.Join(cwd, "app"))
+	f.m.AddRoot(app)
+
+	tiltfile := uri.File(filepath.Join(cwd, "app", "Tiltfile"))
+	diags, err := f.m.Write(f.ctx, tiltfile, []byte(`load("@lib/k8s.star", "k8s")`))
+	require.NoError(t, err)
+	require.Len(t, diags, 1)
+
+	// open documents are parsed again with the new load paths
+	f.m.SetLoadPaths(f.ctx, app, LoadPaths{Aliases: map[string]string{"@lib": filepath.Join(cwd, "lib")}})
+	doc, err := f.m.Read(f.ctx, tiltfile)
+	require.NoError(t, err)
+	assert.Empty(t, doc.Diagnostics())
+	assert.Len(t, doc.Symbols(), 1)
+
+	// documents outside of the folder are unaffected
+	_, err = f.m.Write(f.ctx, uri.File(filepath.Join(cwd, "Tiltfile")), []byte(`load("@lib/k8s.star", "k8s")`))
+	require.NoError(t, err)
+	doc, err = f.m.Read(f.ctx, uri.File(filepath.Join(cwd, "Tiltfile")))
+	require.NoError(t, err)
+	assert.Len(t, doc.Diagnostics(), 1)
+}
+
+func TestExtensions(t *testing.T) {
+	f := newFixture(t)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	writeExtension := func(dir, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(cwd, dir), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(cwd, dir, "Tiltfile"), []byte(content), 0644))
+	}
+	writeExtension("cache/github.com/tilt-dev/tilt-extensions/restart_process", "load('./helpers.star', 'helper')\ndef docker_build_with_restart():\n  pass\n")
+	require.NoError(t, os.WriteFile("cache/github.com/tilt-dev/tilt-extensions/restart_process/helpers.star", []byte("helper = 1\n"), 0644))
+	writeExtension("local/exts/cancel", "def cancel_button():\n  pass\n")
+	writeExtension("local/exts/nested/ext", "def nested():\n  pass\n")
+	WithExtensions(NewExtensions(filepath.Join(cwd, "cache"), nil))(f.m)
+
+	require.NoError(t, os.WriteFile("Tiltfile", []byte(fmt.Sprintf(`
+v1alpha1.extension_repo(name='local', url='file://%s', load_host='my-host')
+v1alpha1.extension(name='cancel-button', repo_name='local', repo_path='cancel')
+load('ext://restart_process', 'docker_build_with_restart')
+load('ext://cancel-button', 'cancel_button')
+load('ext://my-host/nested/ext', 'nested')
+load('ext://missing', 'missing')
+`, filepath.ToSlash(filepath.Join(cwd, "local", "exts")))), 0644))
+	doc, err := f.m.Read(f.ctx, uri.File("Tiltfile"))
+	require.NoError(t, err)
+
+	loads := doc.Loads()
+	require.Len(t, loads, 4)
+	for _, load := range loads[:3] {
+		assert.Empty(t, load.Diagnostics, load.File)
+	}
+	assert.Equal(t, uri.URI("ext://restart_process"), loads[0].URI)
+	syms := doc.Symbols()
+	require.Len(t, syms, 3)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "cache/github.com/tilt-dev/tilt-extensions/restart_process/Tiltfile")), syms[0].Location.URI)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "local/exts/cancel/Tiltfile")), syms[1].Location.URI)
+	assert.Equal(t, uri.File(filepath.Join(cwd, "local/exts/nested/ext/Tiltfile")), syms[2].Location.URI)
+
+	require.Len(t, loads[3].Diagnostics, 1)
+	assert.Equal(t, fmt.Sprintf(`cannot find "ext://missing", tried: %s`,
+		filepath.Join(cwd, "cache/github.com/tilt-dev/tilt-extensions/missing/Tiltfile")),
+		loads[3].Diagnostics[0].Message)
+}
+
+func TestExtensionRepoChanged(t *testing.T) {
+	f := newFixture(t)
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	for _, repo := range []string{"a", "b"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(repo, "ext"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(repo, "ext", "Tiltfile"), []byte(repo+" = 1\n"), 0644))
+	}
+	WithExtensions(NewExtensions(filepath.Join(cwd, "cache"), map[string]string{"default": "file://" + filepath.ToSlash(filepath.Join(cwd, "a"))}))(f.m)
+
+	tiltfile := uri.File(filepath.Join(cwd, "Tiltfile"))
+	diags, err := f.m.Write(f.ctx, tiltfile, []byte("load('ext://ext', 'a')\n"))
+	require.NoError(t, err)
+	assert.Empty(t, diags)
+
+	// extensions are read again from the repository they are now registered with
+	diags, err = f.m.Write(f.ctx, tiltfile, []byte(fmt.Sprintf(
+		"v1alpha1.extension_repo(name='default', url='file://%s')\nload('ext://ext', 'b')\n", filepath.ToSlash(filepath.Join(cwd, "b")))))
+	require.NoError(t, err)
+	assert.Empty(t, diags)
+
+	// and from the configured one once the registration is gone
+	f.m.Remove(tiltfile)
+	diags, err = f.m.Write(f.ctx, tiltfile, []byte("load('ext://ext', 'b')\n"))
+	require.NoError(t, err)
+	require.Len(t, diags, 1)
+	assert.Equal(t, LoadSymbolNotFoundCode, diags[0].Code)
+}
+
 func TestURIfilename(t *testing.T) {
 	var fn string
 	var err error
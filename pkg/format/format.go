@@ -0,0 +1,362 @@
+// Package format formats Starlark code.
+//
+// The formatter only changes the whitespace between tokens, and the quotes
+// of strings if a preferred quote is configured, so that formatting never
+// changes what code means:
+//
+//   - statements are indented by their nesting level, with a configurable
+//     number of spaces per level
+//   - lines continued within brackets are indented one level more than the
+//     line with the opening bracket, and closing brackets that start a line
+//     line up with that line
+//   - tokens on the same line are separated by a single space, except after
+//     opening brackets, before closing brackets, commas and colons, around
+//     dots and the `=` of keyword arguments, and after unary operators
+//   - comments at the end of a line are preceded by two spaces
+//   - there are at most two consecutive blank lines at the top level and one
+//     elsewhere, no blank lines at the start of the file and no trailing
+//     whitespace, and the file ends with a single newline
+//
+// Formatting is idempotent: formatted code is left alone.
+package format
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+// DefaultIndentWidth is the number of spaces per indentation level if none
+// is configured.
+const DefaultIndentWidth = 4
+
+// Options configure the style of formatted code.
+type Options struct {
+	// IndentWidth is the number of spaces per indentation level, or
+	// DefaultIndentWidth if zero.
+	IndentWidth int
+	// Quote is the preferred quote of strings, "double" or "single". If it's
+	// empty, quotes are left alone.
+	Quote string
+}
+
+// Source parses and formats Starlark code.
+func Source(ctx context.Context, input []byte, opts Options) ([]byte, error) {
+	tree, err := query.Parse(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	return Tree(input, tree, opts)
+}
+
+// Tree formats Starlark code that has already been parsed. Code with syntax
+// errors isn't formatted, since its structure isn't known.
+func Tree(input []byte, tree *sitter.Tree, opts Options) ([]byte, error) {
+	root := tree.RootNode()
+	if root.HasError() {
+		n := query.FirstSyntaxError(root)
+		return nil, fmt.Errorf("syntax error at line %d, column %d", n.StartPoint().Row+1, n.StartPoint().Column+1)
+	}
+	if opts.IndentWidth <= 0 {
+		opts.IndentWidth = DefaultIndentWidth
+	}
+
+	f := formatter{input: input, opts: opts}
+	f.tokens = tokens(root)
+	if err := f.format(); err != nil {
+		return nil, err
+	}
+	return []byte(f.out.String()), nil
+}
+
+// tokens returns the leaves of the tree in order. Strings are tokens of
+// their own, since their contents aren't made of nodes.
+func tokens(root *sitter.Node) []*sitter.Node {
+	var result []*sitter.Node
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		if n.Type() == query.NodeTypeString || n.ChildCount() == 0 {
+			if n.EndByte() > n.StartByte() {
+				result = append(result, n)
+			}
+			return
+		}
+		for i := 0; i < int(n.ChildCount()); i++ {
+			walk(n.Child(i))
+		}
+	}
+	walk(root)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartByte() < result[j].StartByte()
+	})
+	return result
+}
+
+type formatter struct {
+	input  []byte
+	opts   Options
+	tokens []*sitter.Node
+	out    strings.Builder
+
+	// lineIndent is the indentation of the line being written.
+	lineIndent int
+	// brackets are the indentation of the lines continued within each of
+	// the open brackets.
+	brackets []int
+}
+
+func (f *formatter) format() error {
+	var prev *sitter.Node
+	for i, tok := range f.tokens {
+		var gapStart uint32
+		if prev != nil {
+			gapStart = prev.EndByte()
+		}
+		gap := string(f.input[gapStart:tok.StartByte()])
+		// semicolons between statements aren't part of the tree
+		before, after, semicolon := strings.Cut(gap, ";")
+		if semicolon && prev != nil && strings.TrimSpace(before) == "" {
+			f.out.WriteString(";")
+			gap = after
+		} else {
+			semicolon = false
+		}
+		if strings.Trim(gap, " \t\r\n\f\\") != "" {
+			return fmt.Errorf("unexpected %q at line %d", strings.TrimSpace(gap), tok.StartPoint().Row+1)
+		}
+
+		switch {
+		case prev == nil:
+			f.lineIndent = 0
+		case strings.Contains(gap, "\\"):
+			f.continueLine()
+		case strings.Contains(gap, "\n"):
+			f.newLine(i, strings.Count(gap, "\n")-1)
+		case semicolon:
+			f.out.WriteString(" ")
+		default:
+			f.out.WriteString(space(f.input, prev, tok))
+		}
+
+		f.out.WriteString(f.text(tok))
+		switch tok.Type() {
+		case "(", "[", "{":
+			f.brackets = append(f.brackets, f.lineIndent+f.opts.IndentWidth)
+		case ")", "]", "}":
+			if len(f.brackets) > 0 {
+				f.brackets = f.brackets[:len(f.brackets)-1]
+			}
+		}
+		prev = tok
+	}
+
+	if prev != nil {
+		rest := strings.TrimPrefix(strings.TrimSpace(string(f.input[prev.EndByte():])), ";")
+		if rest != "" {
+			return fmt.Errorf("unexpected %q at line %d", strings.TrimSpace(rest), prev.EndPoint().Row+1)
+		}
+		f.out.WriteString("\n")
+	}
+	return nil
+}
+
+// continueLine continues the statement on the next line after a backslash.
+func (f *formatter) continueLine() {
+	indent := f.lineIndent + f.opts.IndentWidth
+	if len(f.brackets) > 0 {
+		indent = f.brackets[len(f.brackets)-1]
+	}
+	f.out.WriteString(" \\\n")
+	f.indent(indent)
+}
+
+// newLine starts the line of the i-th token, after the blank lines.
+func (f *formatter) newLine(i int, blankLines int) {
+	tok := f.tokens[i]
+	var indent int
+	switch {
+	case len(f.brackets) > 0:
+		indent = f.brackets[len(f.brackets)-1]
+		switch tok.Type() {
+		case ")", "]", "}":
+			indent -= f.opts.IndentWidth
+		}
+	case tok.Type() == query.NodeTypeComment:
+		indent = f.commentDepth(i) * f.opts.IndentWidth
+	default:
+		indent = depth(tok) * f.opts.IndentWidth
+	}
+
+	maxBlankLines := 1
+	if len(f.brackets) == 0 && indent == 0 {
+		maxBlankLines = 2
+	}
+	if blankLines > maxBlankLines {
+		blankLines = maxBlankLines
+	}
+	f.out.WriteString(strings.Repeat("\n", blankLines+1))
+	f.indent(indent)
+}
+
+func (f *formatter) indent(indent int) {
+	f.lineIndent = indent
+	f.out.WriteString(strings.Repeat(" ", indent))
+}
+
+// commentDepth returns the nesting level of the comment that starts the
+// line of the i-th token. A comment between a block and a statement that
+// follows the block at a lower level is kept within the block if it was
+// indented more than the statement.
+func (f *formatter) commentDepth(i int) int {
+	var prev, next *sitter.Node
+	for j := i - 1; j >= 0; j-- {
+		if f.tokens[j].Type() != query.NodeTypeComment {
+			prev = f.tokens[j]
+			break
+		}
+	}
+	for j := i + 1; j < len(f.tokens); j++ {
+		if f.tokens[j].Type() != query.NodeTypeComment {
+			next = f.tokens[j]
+			break
+		}
+	}
+
+	nextDepth, nextColumn := 0, uint32(0)
+	if next != nil {
+		nextDepth, nextColumn = depth(next), next.StartPoint().Column
+	}
+	if prev == nil {
+		return nextDepth
+	}
+	prevDepth := depth(prev)
+	if nextDepth >= prevDepth || f.tokens[i].StartPoint().Column <= nextColumn {
+		return nextDepth
+	}
+	return prevDepth
+}
+
+// depth returns the nesting level of the statement of a token.
+func depth(n *sitter.Node) int {
+	d := 0
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.Type() == query.NodeTypeBlock {
+			d++
+		}
+	}
+	return d
+}
+
+// text returns the formatted text of the token.
+func (f *formatter) text(tok *sitter.Node) string {
+	s := tok.Content(f.input)
+	switch tok.Type() {
+	case query.NodeTypeComment:
+		return strings.TrimRight(s, " \t\r")
+	case query.NodeTypeString:
+		return requote(s, f.opts.Quote)
+	}
+	return s
+}
+
+// space returns the whitespace between two tokens on the same line.
+func space(input []byte, prev, next *sitter.Node) string {
+	prevType, nextType := prev.Type(), next.Type()
+	prevParent, nextParent := parentType(prev), parentType(next)
+	switch {
+	case nextType == query.NodeTypeComment:
+		return "  "
+	case prevType == "(" || prevType == "[" || prevType == "{":
+		return ""
+	case nextType == ")" || nextType == "]" || nextType == "}" || nextType == "," || nextType == ":":
+		return ""
+	case prevType == ",":
+		return " "
+	case nextType == ".":
+		if prevType == "integer" {
+			// "1.real" would be a float
+			return " "
+		}
+		return ""
+	case prevType == ".":
+		return ""
+	case nextType == "(" && (nextParent == query.NodeTypeArgList || nextParent == query.NodeTypeParameters ||
+		nextParent == "generator_expression" && parentType(next.Parent()) == query.NodeTypeCall):
+		return ""
+	case nextType == "[" && nextParent == "subscript":
+		return ""
+	case prevType == ":" && prevParent == "slice":
+		return ""
+	case (prevType == "=" || nextType == "=") && isKeywordArgument(prev, next):
+		return ""
+	case (prevType == "-" || prevType == "+" || prevType == "~") && prevParent == "unary_operator":
+		return ""
+	case prevType == "*" || prevType == "**":
+		switch prevParent {
+		case "list_splat", "dictionary_splat", "list_splat_pattern", "dictionary_splat_pattern":
+			return ""
+		}
+	}
+	return " "
+}
+
+// isKeywordArgument reports whether one of the tokens is the `=` of a
+// keyword argument or a default parameter without a type.
+func isKeywordArgument(prev, next *sitter.Node) bool {
+	eq := next
+	if prev.Type() == "=" {
+		eq = prev
+	}
+	switch parentType(eq) {
+	case query.NodeTypeKeywordArgument, "default_parameter":
+		return true
+	}
+	return false
+}
+
+func parentType(n *sitter.Node) string {
+	if p := n.Parent(); p != nil {
+		return p.Type()
+	}
+	return ""
+}
+
+// requote changes the quotes of a string literal to the preferred quote, if
+// that doesn't require escaping quotes within the string.
+func requote(s string, quote string) string {
+	var want byte
+	switch quote {
+	case "double":
+		want = '"'
+	case "single":
+		want = '\''
+	default:
+		return s
+	}
+
+	prefixLen := strings.IndexAny(s, `"'`)
+	if prefixLen < 0 {
+		return s
+	}
+	prefix, literal := s[:prefixLen], s[prefixLen:]
+	have := literal[0]
+	if have == want {
+		return s
+	}
+	delim := literal[:1]
+	if strings.HasPrefix(literal, strings.Repeat(delim, 3)) && len(literal) >= 6 {
+		delim = literal[:3]
+	}
+	body := literal[len(delim) : len(literal)-len(delim)]
+	if strings.IndexByte(body, want) >= 0 || strings.Contains(body, `\`+string(have)) {
+		return s
+	}
+	newDelim := strings.Repeat(string(want), len(delim))
+	return prefix + newDelim + body + newDelim
+}
@@ -0,0 +1,80 @@
+package format
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		input, golden string
+		opts          Options
+	}{
+		{input: "../../Tiltfile", golden: "Tiltfile.golden"},
+		{input: "../analysis/builtins.py", golden: "builtins.py.golden"},
+		{input: "../analysis/builtins.py", golden: "builtins.py.indent2.golden", opts: Options{IndentWidth: 2, Quote: "double"}},
+		{input: "testdata/style.star", golden: "style.star.golden", opts: Options{Quote: "double"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			input, err := os.ReadFile(tt.input)
+			require.NoError(t, err)
+			formatted, err := Source(context.Background(), input, tt.opts)
+			require.NoError(t, err)
+
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				require.NoError(t, os.WriteFile(golden, formatted, 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(formatted))
+
+			again, err := Source(context.Background(), formatted, tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, string(formatted), string(again), "formatting isn't idempotent")
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	tests := map[string]struct {
+		input, expected string
+		opts            Options
+	}{
+		"empty":               {input: "\n\n", expected: ""},
+		"final newline":       {input: "x = 1", expected: "x = 1\n"},
+		"splat":               {input: "f( * args , ** kwargs )\n", expected: "f(*args, **kwargs)\n"},
+		"power":               {input: "x = 2**-y\n", expected: "x = 2 ** -y\n"},
+		"integer attribute":   {input: "x = 1 .real\n", expected: "x = 1 .real\n"},
+		"trailing comma":      {input: "x = (1 ,)\n", expected: "x = (1,)\n"},
+		"semicolons":          {input: "a=1 ;b=2\n", expected: "a = 1; b = 2\n"},
+		"slice step":          {input: "x[ :: 2]\n", expected: "x[::2]\n"},
+		"nested brackets":     {input: "f(g(\n1,\n),\n)\n", expected: "f(g(\n    1,\n),\n)\n"},
+		"single quotes":       {input: `x = ["a", "it's", r"\d", b"""doc"""]` + "\n", expected: `x = ['a', "it's", r'\d', b'''doc''']` + "\n", opts: Options{Quote: "single"}},
+		"escaped quotes":      {input: `x = 'it\'s'` + "\n", expected: `x = 'it\'s'` + "\n", opts: Options{Quote: "double"}},
+		"indent width":        {input: "if x:\n        y()\n", expected: "if x:\n   y()\n", opts: Options{IndentWidth: 3}},
+		"blank lines nested":  {input: "def f():\n    x = 1\n\n\n\n    y = 2\n", expected: "def f():\n    x = 1\n\n    y = 2\n"},
+		"comment after block": {input: "if x:\n  y()\n# after\nz()\n", expected: "if x:\n    y()\n# after\nz()\n"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			formatted, err := Source(context.Background(), []byte(tt.input), tt.opts)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(formatted))
+		})
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := Source(context.Background(), []byte("x = 1\ndef f(:\n"), Options{})
+	assert.EqualError(t, err, "syntax error at line 2, column 7")
+}
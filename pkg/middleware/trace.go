@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.lsp.dev/jsonrpc2"
+)
+
+// Direction is the direction of a traced message.
+type Direction string
+
+const (
+	// Incoming messages are sent by the client to the server.
+	Incoming Direction = "in"
+	// Outgoing messages are sent by the server to the client.
+	Outgoing Direction = "out"
+)
+
+// TraceRecord is a JSON-RPC message of a trace.
+type TraceRecord struct {
+	Time time.Time `json:"time"`
+	// Session tells apart the sessions of a server serving several clients,
+	// starting at 1.
+	Session   int             `json:"session"`
+	Direction Direction       `json:"direction"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// Tracer records the JSON-RPC messages of sessions with their time, as a
+// JSON object per line.
+//
+// Unlike the middlewares of handlers, which only see the requests of the
+// client and the replies to them, it wraps the stream of a session, so that
+// the notifications and requests sent by the server, and the replies of the
+// client to them, are recorded too.
+type Tracer struct {
+	mu       sync.Mutex
+	enc      *json.Encoder
+	sessions int
+	err      error
+}
+
+// NewTracer creates a Tracer that writes the trace to w. Writes aren't
+// buffered, so that the trace is complete if the server is killed, and the
+// first error writing is kept for Err.
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{enc: json.NewEncoder(w)}
+}
+
+// Stream returns a stream that records the messages of a new session read
+// from and written to s.
+func (t *Tracer) Stream(s jsonrpc2.Stream) jsonrpc2.Stream {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sessions++
+	return &traceStream{Stream: s, tracer: t, session: t.sessions}
+}
+
+// Err returns the first error writing the trace.
+func (t *Tracer) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.err
+}
+
+func (t *Tracer) record(session int, direction Direction, msg jsonrpc2.Message) {
+	data, err := json.Marshal(msg)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err == nil {
+		err = t.enc.Encode(TraceRecord{
+			Time:      time.Now(),
+			Session:   session,
+			Direction: direction,
+			Message:   data,
+		})
+	}
+	if err != nil && t.err == nil {
+		t.err = err
+	}
+}
+
+type traceStream struct {
+	jsonrpc2.Stream
+	tracer  *Tracer
+	session int
+}
+
+func (s *traceStream) Read(ctx context.Context) (jsonrpc2.Message, int64, error) {
+	msg, n, err := s.Stream.Read(ctx)
+	if err == nil {
+		s.tracer.record(s.session, Incoming, msg)
+	}
+	return msg, n, err
+}
+
+func (s *traceStream) Write(ctx context.Context, msg jsonrpc2.Message) (int64, error) {
+	n, err := s.Stream.Write(ctx, msg)
+	if err == nil {
+		s.tracer.record(s.session, Outgoing, msg)
+	}
+	return n, err
+}
+
+// ReadTrace reads the records of a trace written by a Tracer.
+func ReadTrace(r io.Reader) ([]TraceRecord, error) {
+	var records []TraceRecord
+	scanner := bufio.NewScanner(r)
+	// messages can be as large as the documents they contain
+	scanner.Buffer(nil, 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record TraceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,110 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/jsonrpc2"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/middleware"
+)
+
+// fakeStream reads the messages it was created with and discards the ones
+// written to it.
+type fakeStream struct {
+	messages []jsonrpc2.Message
+}
+
+func (s *fakeStream) Read(context.Context) (jsonrpc2.Message, int64, error) {
+	if len(s.messages) == 0 {
+		return nil, 0, io.EOF
+	}
+	msg := s.messages[0]
+	s.messages = s.messages[1:]
+	return msg, 0, nil
+}
+
+func (s *fakeStream) Write(context.Context, jsonrpc2.Message) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStream) Close() error {
+	return nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestTracer(t *testing.T) {
+	ctx := context.Background()
+	call, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(1), "initialize", map[string]string{})
+	require.NoError(t, err)
+	response, err := jsonrpc2.NewResponse(jsonrpc2.NewNumberID(1), map[string]string{}, nil)
+	require.NoError(t, err)
+	notification, err := jsonrpc2.NewNotification("exit", nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	tracer := middleware.NewTracer(&out)
+	first := tracer.Stream(&fakeStream{messages: []jsonrpc2.Message{call}})
+	second := tracer.Stream(&fakeStream{messages: []jsonrpc2.Message{notification}})
+
+	_, _, err = first.Read(ctx)
+	require.NoError(t, err)
+	_, err = first.Write(ctx, response)
+	require.NoError(t, err)
+	_, _, err = second.Read(ctx)
+	require.NoError(t, err)
+	// failed reads aren't recorded
+	_, _, err = second.Read(ctx)
+	require.ErrorIs(t, err, io.EOF)
+	require.NoError(t, tracer.Err())
+
+	records, err := middleware.ReadTrace(&out)
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, 1, records[0].Session)
+	assert.Equal(t, middleware.Incoming, records[0].Direction)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`, string(records[0].Message))
+	assert.Equal(t, 1, records[1].Session)
+	assert.Equal(t, middleware.Outgoing, records[1].Direction)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"result":{}}`, string(records[1].Message))
+	assert.Equal(t, 2, records[2].Session)
+	assert.Equal(t, middleware.Incoming, records[2].Direction)
+	assert.False(t, records[2].Time.Before(records[0].Time))
+}
+
+func TestTracerWriteError(t *testing.T) {
+	ctx := context.Background()
+	notification, err := jsonrpc2.NewNotification("exit", nil)
+	require.NoError(t, err)
+
+	tracer := middleware.NewTracer(failingWriter{})
+	stream := tracer.Stream(&fakeStream{})
+	// the session goes on if the trace can't be written
+	_, err = stream.Write(ctx, notification)
+	require.NoError(t, err)
+	assert.EqualError(t, tracer.Err(), "disk full")
+}
+
+func TestReadTrace(t *testing.T) {
+	records, err := middleware.ReadTrace(strings.NewReader(
+		`{"time":"2022-01-02T03:04:05Z","session":1,"direction":"in","message":{"jsonrpc":"2.0","method":"exit"}}` + "\n\n"))
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, middleware.Incoming, records[0].Direction)
+	assert.Equal(t, 2022, records[0].Time.Year())
+
+	_, err = middleware.ReadTrace(strings.NewReader("{}\nnot json\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2:")
+}
@@ -0,0 +1,108 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/docstring"
+)
+
+// Documentation is the documentation of a function or a module, as the
+// sections that hovers, completion items and reference pages show, so that
+// they all show the same docstring the same way.
+type Documentation struct {
+	Description string
+	// Params are the parameters of the signature, followed by those that are
+	// only documented, e.g. because the signature uses `**kwargs`.
+	Params []ParameterDoc
+	// ReturnType is the return type hint of the signature and Returns the
+	// description of the return value.
+	ReturnType string
+	Returns    string
+	// Sections are the other sections of the docstring, e.g. "Raises" or
+	// "Examples", the fields blocks first.
+	Sections []DocSection
+}
+
+// ParameterDoc is a parameter of a function with its description. The name
+// of variadic parameters has their `*` or `**` prefix.
+type ParameterDoc struct {
+	Name         string
+	TypeHint     string
+	DefaultValue string
+	Desc         string
+}
+
+// DocSection is a section of a docstring other than the description, the
+// parameters and the return value.
+type DocSection struct {
+	Title string
+	// Fields are the fields of a fields block, e.g. the exceptions listed
+	// under "Raises", and Body the text of a remark block.
+	Fields []docstring.Field
+	Body   string
+	// Code reports whether the body is code, as for examples.
+	Code bool
+}
+
+// Documentation returns the documentation of the function.
+func (s Signature) Documentation() Documentation {
+	return NewDocumentation(s.Docs, s.Params, s.ReturnType)
+}
+
+// NewDocumentation arranges a docstring into sections, along with the
+// parameters and the return type hint of the signature it documents, if any.
+func NewDocumentation(docs docstring.Parsed, params []Parameter, returnType string) Documentation {
+	d := Documentation{
+		Description: strings.TrimSpace(docs.Description),
+		ReturnType:  returnType,
+		Returns:     strings.TrimSpace(docs.Returns()),
+	}
+
+	descs := make(map[string]string)
+	for _, arg := range docs.Args() {
+		descs[strings.TrimLeft(arg.Name, "*")] = arg.Desc
+	}
+	for _, p := range params {
+		if p.Name == "" {
+			continue
+		}
+		name := p.Name
+		if strings.HasPrefix(p.Content, "**") {
+			name = "**" + name
+		} else if strings.HasPrefix(p.Content, "*") {
+			name = "*" + name
+		}
+		d.Params = append(d.Params, ParameterDoc{
+			Name:         name,
+			TypeHint:     p.TypeHint,
+			DefaultValue: p.DefaultValue,
+			Desc:         descs[p.Name],
+		})
+		delete(descs, p.Name)
+	}
+	for _, arg := range docs.Args() {
+		if desc, found := descs[strings.TrimLeft(arg.Name, "*")]; found {
+			d.Params = append(d.Params, ParameterDoc{Name: arg.Name, Desc: desc})
+		}
+	}
+
+	for _, block := range docs.Fields {
+		if block.Title == "Args" || len(block.Fields) == 0 {
+			continue
+		}
+		d.Sections = append(d.Sections, DocSection{Title: block.Title, Fields: block.Fields})
+	}
+	for _, remark := range docs.Remarks {
+		body := strings.TrimSpace(remark.Body)
+		if remark.Title == "Returns" || body == "" {
+			continue
+		}
+		section := DocSection{Title: remark.Title, Body: body}
+		if remark.Title == "Example" || remark.Title == "Examples" {
+			section.Title = "Examples"
+			section.Code = true
+		}
+		d.Sections = append(d.Sections, section)
+	}
+	return d
+}
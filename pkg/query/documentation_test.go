@@ -0,0 +1,54 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/docstring"
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+func TestSignatureDocumentation(t *testing.T) {
+	code := `
+def run(cmd: str, *args, env: dict = {}, **kwargs) -> str:
+    """Runs a command.
+
+    Args:
+      cmd: command to run
+      echo_off: don't print the command
+
+    Returns:
+      The output of the command.
+
+    Raises:
+      Error: if the command fails
+
+    Examples:
+      run("make")
+    """
+    pass
+`
+	f := newQueryFixture(t, "", code)
+	sig, found := query.Function(f.document(), f.root, "run")
+	require.True(t, found)
+
+	assert.Equal(t, query.Documentation{
+		Description: "Runs a command.",
+		Params: []query.ParameterDoc{
+			{Name: "cmd", TypeHint: "str", Desc: "command to run"},
+			{Name: "*args"},
+			{Name: "env", TypeHint: "dict", DefaultValue: "{}"},
+			{Name: "**kwargs"},
+			{Name: "echo_off", Desc: "don't print the command"},
+		},
+		ReturnType: "str",
+		Returns:    "The output of the command.",
+		Sections: []query.DocSection{
+			{Title: "Raises", Fields: []docstring.Field{{Name: "Error", Desc: "if the command fails"}}},
+			{Title: "Examples", Body: `run("make")`, Code: true},
+		},
+	}, sig.Documentation())
+	assert.Equal(t, "Runs a command.", sig.Symbol().Detail)
+}
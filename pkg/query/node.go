@@ -18,6 +18,7 @@ const (
 	NodeTypeList                = "list"
 	NodeTypeComment             = "comment"
 	NodeTypeBlock               = "block"
+	NodeTypeListSplatPattern    = "list_splat_pattern"
 	NodeTypeERROR               = "ERROR"
 
 	FieldName       = "name"
@@ -33,8 +33,11 @@ type Parameter struct {
 	TypeHint     string
 	DefaultValue string
 	Content      string
-	DocURI       uri.URI
-	Location     protocol.Location
+	// KeywordOnly reports whether the parameter follows `*` or `*args`, so
+	// that it can only be passed by keyword.
+	KeywordOnly bool
+	DocURI      uri.URI
+	Location    protocol.Location
 }
 
 func (p Parameter) ParameterInfo(fnDocs docstring.Parsed) protocol.ParameterInformation {
@@ -65,6 +68,13 @@ func (p Parameter) ParameterInfo(fnDocs docstring.Parsed) protocol.ParameterInfo
 	return pi
 }
 
+// TypeHintExpr parses the type hint of the parameter. The result is zero if
+// there is no type hint or if it can't be parsed.
+func (p Parameter) TypeHintExpr() TypeExpr {
+	t, _ := ParseTypeExpr(p.TypeHint)
+	return t
+}
+
 func (p Parameter) Symbol() Symbol {
 	return Symbol{
 		Name:     p.Name,
@@ -91,6 +101,17 @@ func extractParameters(doc DocumentContent, fnDocs docstring.Parsed,
 		panic(fmt.Errorf("invalid node type: %v", node.Type()))
 	}
 
+	// a bare `*` isn't matched by the query, since it has no name
+	var star *sitter.Node
+	for i := 0; i < int(node.NamedChildCount()) && star == nil; i++ {
+		c := node.NamedChild(i)
+		if c.Type() == NodeTypeListSplatPattern {
+			star = c
+		} else if c.NamedChildCount() > 0 && c.NamedChild(0).Type() == NodeTypeListSplatPattern {
+			star = c
+		}
+	}
+
 	var params []Parameter
 	Query(node, FunctionParameters, func(q *sitter.Query, match *sitter.QueryMatch) bool {
 		param := Parameter{
@@ -109,6 +130,7 @@ func extractParameters(doc DocumentContent, fnDocs docstring.Parsed,
 			case "param":
 				param.Content = content
 				param.Location = NodeLocation(c.Node, param.DocURI)
+				param.KeywordOnly = star != nil && c.Node.StartByte() > star.StartByte()
 			}
 		}
 
@@ -17,3 +17,17 @@ func Parse(ctx context.Context, input []byte) (*sitter.Tree, error) {
 
 	return tree, nil
 }
+
+// FirstSyntaxError returns the first node of the tree that is missing or
+// couldn't be parsed, or the node itself if there is none.
+func FirstSyntaxError(n *sitter.Node) *sitter.Node {
+	if n.Type() == NodeTypeERROR || n.IsMissing() {
+		return n
+	}
+	for i := 0; i < int(n.ChildCount()); i++ {
+		if child := n.Child(i); child.HasError() || child.IsMissing() {
+			return FirstSyntaxError(child)
+		}
+	}
+	return n
+}
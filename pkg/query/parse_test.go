@@ -0,0 +1,27 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+func TestFirstSyntaxError(t *testing.T) {
+	tree, err := query.Parse(context.Background(), []byte("x = 1\ny = = 2\n"))
+	require.NoError(t, err)
+	defer tree.Close()
+
+	n := query.FirstSyntaxError(tree.RootNode())
+	assert.True(t, n.Type() == query.NodeTypeERROR || n.IsMissing())
+	assert.Equal(t, uint32(1), n.StartPoint().Row)
+
+	valid, err := query.Parse(context.Background(), []byte("x = 1\n"))
+	require.NoError(t, err)
+	defer valid.Close()
+	root := valid.RootNode()
+	assert.Equal(t, root, query.FirstSyntaxError(root))
+}
@@ -98,24 +98,20 @@ func (s Signature) Label() string {
 	return sb.String()
 }
 
+// ReturnTypeExpr parses the return type annotation of the function. The
+// result is zero if there is no annotation or if it can't be parsed.
+func (s Signature) ReturnTypeExpr() TypeExpr {
+	t, _ := ParseTypeExpr(s.ReturnType)
+	return t
+}
+
+// Symbol returns the symbol of the function, with the description of its
+// docstring as detail. The full documentation is given by Documentation.
 func (s Signature) Symbol() Symbol {
-	argsList := []string{}
-	returns := s.Docs.Returns()
-	for _, arg := range s.Docs.Args() {
-		argsList = append(argsList, fmt.Sprintf("%s: %s", arg.Name, arg.Desc))
-	}
-	argsFormatted := strings.Join(argsList, "\\\n")
-	detail := s.Docs.Description
-	if len(argsFormatted) > 0 {
-		detail += fmt.Sprintf("\n## Parameters\n%s", argsFormatted)
-	}
-	if len(returns) > 0 {
-		detail += fmt.Sprintf("\n## Returns\n%s", returns)
-	}
 	return Symbol{
 		Name:   s.Name,
 		Kind:   protocol.SymbolKindFunction,
-		Detail: detail,
+		Detail: s.Docs.Description,
 		Location: protocol.Location{
 			URI:   s.docURI,
 			Range: s.Range,
@@ -149,6 +145,24 @@ func ExtractSignature(doc DocumentContent, n *sitter.Node) Signature {
 	}
 }
 
+// ModuleDocstring returns the docstring at the top of the document, if any.
+func ModuleDocstring(doc DocumentContent) docstring.Parsed {
+	root := doc.Tree().RootNode()
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		n := root.NamedChild(i)
+		if n.Type() == NodeTypeComment {
+			continue
+		}
+		if n.Type() == NodeTypeExpressionStatement {
+			if docStringNode := n.NamedChild(0); docStringNode != nil && docStringNode.Type() == NodeTypeString {
+				return docstring.Parse(Unquote(doc.Input(), docStringNode))
+			}
+		}
+		break
+	}
+	return docstring.Parsed{}
+}
+
 func extractDocstring(doc DocumentContent, n *sitter.Node) docstring.Parsed {
 	if n.Type() != NodeTypeBlock {
 		panic(fmt.Errorf("invalid node type: %s", n.Type()))
@@ -146,3 +146,20 @@ type Symbol struct {
 func (s Symbol) HasLocation() bool {
 	return s.Location.URI != ""
 }
+
+// DocumentSymbol converts the symbol and its children to their LSP
+// representation.
+func (s Symbol) DocumentSymbol() protocol.DocumentSymbol {
+	var children []protocol.DocumentSymbol
+	for _, c := range s.Children {
+		children = append(children, c.DocumentSymbol())
+	}
+	return protocol.DocumentSymbol{
+		Name:     s.Name,
+		Detail:   s.Detail,
+		Kind:     s.Kind,
+		Tags:     s.Tags,
+		Range:    s.Location.Range,
+		Children: children,
+	}
+}
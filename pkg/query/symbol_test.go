@@ -139,3 +139,21 @@ def foo(a, b=True, c=None):
 	}
 	assert.Equal(t, []string{"d", "bar", "baz", "a", "b", "c"}, names)
 }
+
+func TestSymbolDocumentSymbol(t *testing.T) {
+	r := protocol.Range{Start: protocol.Position{Line: 1}, End: protocol.Position{Line: 3}}
+	sym := query.Symbol{
+		Name:     "Resource",
+		Detail:   "class Resource",
+		Kind:     protocol.SymbolKindClass,
+		Location: protocol.Location{URI: "file:///Tiltfile", Range: r},
+		Children: []query.Symbol{{Name: "name", Kind: protocol.SymbolKindField}},
+	}
+	assert.Equal(t, protocol.DocumentSymbol{
+		Name:     "Resource",
+		Detail:   "class Resource",
+		Kind:     protocol.SymbolKindClass,
+		Range:    r,
+		Children: []protocol.DocumentSymbol{{Name: "name", Kind: protocol.SymbolKindField}},
+	}, sym.DocumentSymbol())
+}
@@ -0,0 +1,288 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TypeExpr is a parsed type annotation, e.g. the return type of a function
+// in a stub file like `List[Tuple[int, any]]`.
+//
+// `Optional[T]`, `Union[A, B]` and `A | B` are all represented as unions,
+// and `Callable[[A, B], R]` as a callable with parameter and result types.
+type TypeExpr struct {
+	// Name of a (possibly generic) type as written, e.g. `List` or `str`.
+	// Empty for unions.
+	Name string
+	// Args are the type arguments of a generic type.
+	Args []TypeExpr
+	// Union holds the alternatives of a union type.
+	Union []TypeExpr
+	// Params are the parameter types of a `Callable`, or nil if they are
+	// unspecified (`Callable[..., R]` or just `Callable`).
+	Params []TypeExpr
+	// Result is the result type of a `Callable`, if specified.
+	Result *TypeExpr
+}
+
+// IsZero reports whether the type expression is empty, e.g. because there
+// was no type annotation.
+func (t TypeExpr) IsZero() bool {
+	return t.Name == "" && len(t.Union) == 0
+}
+
+// IsCallable reports whether the type expression is a `Callable`.
+func (t TypeExpr) IsCallable() bool {
+	return t.Name == "Callable"
+}
+
+// String formats the type expression in a normalized form, e.g.
+// `Optional[str]` as `str | None`.
+func (t TypeExpr) String() string {
+	if len(t.Union) > 0 {
+		alternatives := make([]string, len(t.Union))
+		for i, alt := range t.Union {
+			alternatives[i] = alt.String()
+		}
+		return strings.Join(alternatives, " | ")
+	}
+	if t.IsCallable() && t.Result != nil {
+		params := "..."
+		if t.Params != nil {
+			params = "[" + joinTypeExprs(t.Params) + "]"
+		}
+		return fmt.Sprintf("Callable[%s, %s]", params, t.Result.String())
+	}
+	if len(t.Args) == 0 {
+		return t.Name
+	}
+	return t.Name + "[" + joinTypeExprs(t.Args) + "]"
+}
+
+func joinTypeExprs(types []TypeExpr) string {
+	s := make([]string, len(types))
+	for i, t := range types {
+		s[i] = t.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// ParseTypeExpr parses a type annotation. An empty string results in a zero
+// TypeExpr.
+func ParseTypeExpr(s string) (TypeExpr, error) {
+	p := &typeExprParser{input: s}
+	p.next()
+	if p.tok == "" {
+		return TypeExpr{}, nil
+	}
+	t, err := p.union()
+	if err != nil {
+		return TypeExpr{}, err
+	}
+	if p.tok != "" {
+		return TypeExpr{}, p.errorf("unexpected %q", p.tok)
+	}
+	return t, nil
+}
+
+// typeExprParser is a recursive descent parser for type annotations. The
+// tokens are names (including dotted names), `...` and single punctuation
+// characters.
+type typeExprParser struct {
+	input string
+	pos   int
+	tok   string
+}
+
+func (p *typeExprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("invalid type %q: %s", p.input, fmt.Sprintf(format, args...))
+}
+
+func (p *typeExprParser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	if p.pos >= len(p.input) {
+		p.tok = ""
+		return
+	}
+
+	start := p.pos
+	switch c := p.input[p.pos]; {
+	case strings.HasPrefix(p.input[p.pos:], "..."):
+		p.pos += 3
+	case c == '"' || c == '\'':
+		// forward references are written as strings
+		end := strings.IndexByte(p.input[p.pos+1:], c)
+		if end == -1 {
+			p.pos = len(p.input)
+		} else {
+			p.pos += end + 2
+		}
+	case isTypeNameChar(rune(c)):
+		for p.pos < len(p.input) && isTypeNameChar(rune(p.input[p.pos])) {
+			p.pos++
+		}
+	default:
+		p.pos++
+	}
+	p.tok = p.input[start:p.pos]
+}
+
+func isTypeNameChar(c rune) bool {
+	return c == '_' || c == '.' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func (p *typeExprParser) expect(tok string) error {
+	if p.tok != tok {
+		if p.tok == "" {
+			return p.errorf("expected %q", tok)
+		}
+		return p.errorf("expected %q, found %q", tok, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+// union parses `primary ("|" primary)*`.
+func (p *typeExprParser) union() (TypeExpr, error) {
+	t, err := p.primary()
+	if err != nil {
+		return TypeExpr{}, err
+	}
+	alternatives := []TypeExpr{t}
+	for p.tok == "|" {
+		p.next()
+		alt, err := p.primary()
+		if err != nil {
+			return TypeExpr{}, err
+		}
+		alternatives = append(alternatives, alt)
+	}
+	return newUnionTypeExpr(alternatives), nil
+}
+
+// primary parses a name with optional type arguments.
+func (p *typeExprParser) primary() (TypeExpr, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return TypeExpr{}, p.errorf("unexpected end")
+	case tok[0] == '"' || tok[0] == '\'':
+		p.next()
+		return ParseTypeExpr(strings.Trim(tok, `"'`))
+	case !isTypeNameChar(rune(tok[0])):
+		return TypeExpr{}, p.errorf("unexpected %q", tok)
+	}
+	p.next()
+
+	name := tok
+	if i := strings.LastIndexByte(name, '.'); i != -1 && strings.HasPrefix(name, "typing.") {
+		name = name[i+1:]
+	}
+	if p.tok != "[" {
+		return TypeExpr{Name: name}, nil
+	}
+	p.next()
+
+	if name == "Callable" {
+		return p.callable()
+	}
+
+	var args []TypeExpr
+	for p.tok != "]" {
+		arg, err := p.union()
+		if err != nil {
+			return TypeExpr{}, err
+		}
+		args = append(args, arg)
+		if p.tok != "," {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect("]"); err != nil {
+		return TypeExpr{}, err
+	}
+
+	switch name {
+	case "Optional":
+		if len(args) != 1 {
+			return TypeExpr{}, p.errorf("Optional takes a single type argument")
+		}
+		return newUnionTypeExpr([]TypeExpr{args[0], {Name: "None"}}), nil
+	case "Union":
+		if len(args) == 0 {
+			return TypeExpr{}, p.errorf("Union needs type arguments")
+		}
+		return newUnionTypeExpr(args), nil
+	}
+	return TypeExpr{Name: name, Args: args}, nil
+}
+
+// callable parses the arguments of `Callable[[A, B], R]` or
+// `Callable[..., R]` after the opening bracket.
+func (p *typeExprParser) callable() (TypeExpr, error) {
+	t := TypeExpr{Name: "Callable"}
+	switch p.tok {
+	case "...":
+		p.next()
+	case "[":
+		p.next()
+		t.Params = []TypeExpr{}
+		for p.tok != "]" {
+			param, err := p.union()
+			if err != nil {
+				return TypeExpr{}, err
+			}
+			t.Params = append(t.Params, param)
+			if p.tok != "," {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect("]"); err != nil {
+			return TypeExpr{}, err
+		}
+	default:
+		return TypeExpr{}, p.errorf("expected parameter list of Callable")
+	}
+	if err := p.expect(","); err != nil {
+		return TypeExpr{}, err
+	}
+	result, err := p.union()
+	if err != nil {
+		return TypeExpr{}, err
+	}
+	t.Result = &result
+	if err := p.expect("]"); err != nil {
+		return TypeExpr{}, err
+	}
+	return t, nil
+}
+
+// newUnionTypeExpr flattens nested unions and removes duplicates.
+func newUnionTypeExpr(alternatives []TypeExpr) TypeExpr {
+	var flat []TypeExpr
+	seen := make(map[string]bool)
+	for _, alt := range alternatives {
+		for _, a := range alt.alternatives() {
+			if s := a.String(); !seen[s] {
+				seen[s] = true
+				flat = append(flat, a)
+			}
+		}
+	}
+	if len(flat) == 1 {
+		return flat[0]
+	}
+	return TypeExpr{Union: flat}
+}
+
+func (t TypeExpr) alternatives() []TypeExpr {
+	if len(t.Union) > 0 {
+		return t.Union
+	}
+	return []TypeExpr{t}
+}
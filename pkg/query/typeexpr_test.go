@@ -0,0 +1,83 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/query"
+)
+
+func TestParseTypeExpr(t *testing.T) {
+	for _, tc := range []struct {
+		s, expected string
+	}{
+		{"", ""},
+		{"str", "str"},
+		{"None", "None"},
+		{"List[String]", "List[String]"},
+		{"List[ Tuple[int,any] ]", "List[Tuple[int, any]]"},
+		{"Dict[str, List[str]]", "Dict[str, List[str]]"},
+		{"Optional[str]", "str | None"},
+		{"Union[int, str]", "int | str"},
+		{"Union[int, Union[str, None]]", "int | str | None"},
+		{"int | None | int", "int | None"},
+		{"List[int | str]", "List[int | str]"},
+		{"Callable", "Callable"},
+		{"Callable[[str, int], bool]", "Callable[[str, int], bool]"},
+		{"Callable[[], None]", "Callable[[], None]"},
+		{"Callable[..., Any]", "Callable[..., Any]"},
+		{"typing.List[str]", "List[str]"},
+		{"k8s.Object", "k8s.Object"},
+		{`"Blob"`, "Blob"},
+		{"List[str,]", "List[str]"},
+	} {
+		t.Run(tc.s, func(t *testing.T) {
+			expr, err := query.ParseTypeExpr(tc.s)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, expr.String())
+		})
+	}
+}
+
+func TestParseTypeExprStructure(t *testing.T) {
+	expr, err := query.ParseTypeExpr("Callable[[str], Optional[List[int]]]")
+	require.NoError(t, err)
+	require.True(t, expr.IsCallable())
+	require.Len(t, expr.Params, 1)
+	assert.Equal(t, "str", expr.Params[0].Name)
+	require.NotNil(t, expr.Result)
+	require.Len(t, expr.Result.Union, 2)
+	assert.Equal(t, "List", expr.Result.Union[0].Name)
+	assert.Equal(t, []query.TypeExpr{{Name: "int"}}, expr.Result.Union[0].Args)
+	assert.Equal(t, "None", expr.Result.Union[1].Name)
+}
+
+func TestParseTypeExprErrors(t *testing.T) {
+	for _, s := range []string{
+		"List[str",
+		"List[str]]",
+		"Optional[int, str]",
+		"Union[]",
+		"Callable[str, int]",
+		"int |",
+		"[int]",
+	} {
+		t.Run(s, func(t *testing.T) {
+			_, err := query.ParseTypeExpr(s)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSignatureReturnTypeExpr(t *testing.T) {
+	f := newQueryFixture(t, "", `
+def f(x: Optional[str] = None) -> List[Tuple[int, any]]:
+  pass
+`)
+	sig, found := query.Function(f.document(), f.root, "f")
+	require.True(t, found)
+	assert.Equal(t, "List[Tuple[int, any]]", sig.ReturnTypeExpr().String())
+	assert.Equal(t, "str | None", sig.Params[0].TypeHintExpr().String())
+}
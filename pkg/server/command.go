@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+)
+
+func (s *Server) ExecuteCommand(ctx context.Context, params *protocol.ExecuteCommandParams) (interface{}, error) {
+	switch params.Command {
+	case analysis.CompletionItemAcceptedCommand:
+		if len(params.Arguments) != 1 {
+			return nil, jsonrpc2.NewError(jsonrpc2.InvalidParams,
+				fmt.Sprintf("%s expects exactly one argument", params.Command))
+		}
+		label, ok := params.Arguments[0].(string)
+		if !ok {
+			return nil, jsonrpc2.NewError(jsonrpc2.InvalidParams,
+				fmt.Sprintf("%s expects a string argument", params.Command))
+		}
+		s.completionHistory.Add(label)
+		return nil, nil
+	}
+	return nil, jsonrpc2.NewError(jsonrpc2.InvalidParams,
+		fmt.Sprintf("unknown command: %s", params.Command))
+}
@@ -2,10 +2,20 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 
 	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
 )
 
+// completionItemData is attached to completion items so that the document and
+// position can be recovered in a subsequent completionItem/resolve request.
+type completionItemData struct {
+	URI      protocol.DocumentURI `json:"uri"`
+	Position protocol.Position    `json:"position"`
+}
+
 func (s *Server) Completion(ctx context.Context, params *protocol.CompletionParams) (*protocol.CompletionList, error) {
 	doc, err := s.docs.Read(ctx, params.TextDocument.URI)
 	if err != nil {
@@ -17,7 +27,47 @@ func (s *Server) Completion(ctx context.Context, params *protocol.CompletionPara
 		With(textDocumentFields(params.TextDocumentPositionParams)...)
 	logger.Debug("completion")
 
-	result := s.analyzer.Completion(doc, params.Position)
+	ctx = analysis.WithClientCapabilities(ctx, s.clientCapabilities)
+	ctx = analysis.WithCompletionHistory(ctx, s.completionHistory)
+	result := s.analyzerFor(ctx, params.TextDocument.URI).Completion(ctx, doc, params.Position)
+
+	data := completionItemData{
+		URI:      params.TextDocument.URI,
+		Position: params.Position,
+	}
+	for i := range result.Items {
+		result.Items[i].Data = data
+	}
 
 	return result, nil
 }
+
+func (s *Server) CompletionResolve(ctx context.Context, params *protocol.CompletionItem) (*protocol.CompletionItem, error) {
+	var data completionItemData
+	if params.Data != nil {
+		raw, err := json.Marshal(params.Data)
+		if err == nil {
+			err = json.Unmarshal(raw, &data)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if data.URI == "" {
+		return params, nil
+	}
+
+	doc, err := s.docs.Read(ctx, data.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	protocol.LoggerFromContext(ctx).
+		With(uriField(data.URI), positionField(data.Position)).
+		Debug("completion resolve")
+
+	ctx = analysis.WithClientCapabilities(ctx, s.clientCapabilities)
+	result := s.analyzerFor(ctx, data.URI).CompletionResolve(ctx, doc, data.Position, *params)
+	return &result, nil
+}
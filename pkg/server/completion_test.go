@@ -0,0 +1,56 @@
+package server_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func TestServer_CompletionResolve(t *testing.T) {
+	f := newFixture(t)
+
+	docURI := uri.File("./test.star")
+
+	src := `
+def foo(a, b=1):
+  """Does foo.
+
+  Args:
+    a: the a
+  """
+  pass
+
+fo
+`
+
+	f.mustWriteDocument("./test.star", src)
+
+	var list protocol.CompletionList
+	f.mustEditorCall(protocol.MethodTextDocumentCompletion, protocol.CompletionParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: docURI},
+			Position:     protocol.Position{Line: 9, Character: 2},
+		},
+	}, &list)
+
+	require.Len(t, list.Items, 1)
+	item := list.Items[0]
+	assert.Equal(t, "foo", item.Label)
+	assert.Equal(t, "Does foo.", item.Detail)
+	assert.Nil(t, item.Documentation)
+	require.NotNil(t, item.Data)
+
+	var resolved protocol.CompletionItem
+	f.mustEditorCall(protocol.MethodCompletionItemResolve, item, &resolved)
+
+	assert.Equal(t, "foo", resolved.Label)
+	assert.Equal(t, "foo(a, b=1)", resolved.Detail)
+	requireJsonEqual(t, protocol.MarkupContent{
+		Kind: protocol.Markdown,
+		Value: "Does foo.\n\n**Parameters**\n\n| Parameter | Type | Default | Description |\n| --- | --- | --- | --- |\n" +
+			"| `a` |  |  | the a |\n| `b` |  | `1` |  |",
+	}, resolved.Documentation)
+}
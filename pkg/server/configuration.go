@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+	"go.uber.org/zap"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+)
+
+// DidChangeConfiguration applies the settings of the editor. Editors that
+// push their settings send them with the notification; for editors that
+// support `workspace/configuration`, the settings of each workspace folder
+// are pulled instead.
+func (s *Server) DidChangeConfiguration(ctx context.Context, params *protocol.DidChangeConfigurationParams) (err error) {
+	if settings, found := configSection(params.Settings); found {
+		s.mu.Lock()
+		s.settings = settings
+		s.mu.Unlock()
+		s.reconfigure(ctx)
+		return nil
+	}
+	s.pullConfiguration(ctx)
+	return nil
+}
+
+// configSection extracts the settings of the language server from the
+// settings pushed by the editor.
+func configSection(settings interface{}) (interface{}, bool) {
+	m, ok := settings.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	section, found := m[config.Section]
+	return section, found
+}
+
+// pullConfiguration requests the settings of the workspace folders from the
+// editor, if it supports it, and applies them once they have been received.
+//
+// The request is sent in the background, since the response can't be read
+// while a handler is running, and canceled if the server shuts down.
+func (s *Server) pullConfiguration(ctx context.Context) {
+	if s.clientCapabilities.Workspace == nil || !s.clientCapabilities.Workspace.Configuration {
+		return
+	}
+	roots := s.docs.Roots()
+	if len(roots) == 0 {
+		return
+	}
+	items := make([]protocol.ConfigurationItem, len(roots))
+	for i, root := range roots {
+		items[i] = protocol.ConfigurationItem{ScopeURI: root, Section: config.Section}
+	}
+
+	s.goBackground(ctx, func(ctx context.Context) {
+		result, err := s.notifier.Configuration(ctx, &protocol.ConfigurationParams{Items: items})
+		if err != nil {
+			protocol.LoggerFromContext(ctx).Warn("failed to get configuration", zap.Error(err))
+			return
+		}
+		s.mu.Lock()
+		for i, root := range roots {
+			if i < len(result) {
+				s.rootSettings[root] = result[i]
+			}
+		}
+		s.mu.Unlock()
+		s.reconfigure(ctx)
+	})
+}
+
+// rootConfig reads the configuration file of the workspace folder and
+// applies the settings of the editor, preferring settings pulled for the
+// folder over those pushed for all folders.
+func (s *Server) rootConfig(ctx context.Context, root uri.URI) (*config.Config, error) {
+	cfg, err := config.Load(root.Filename())
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	settings, found := s.rootSettings[root]
+	if !found {
+		settings = s.settings
+	}
+	s.mu.Unlock()
+
+	overrides, err := config.FromSettings(settings, root.Filename())
+	if err != nil {
+		// the configuration file still applies
+		s.showWarning(ctx, fmt.Sprintf("Invalid %q settings: %v", config.Section, err))
+		return cfg, nil
+	}
+	return cfg.Merge(overrides), nil
+}
+
+// reconfigure drops the analyzers of the workspace folders so that they are
+// created with the current settings, and updates the diagnostics of the open
+// documents.
+func (s *Server) reconfigure(ctx context.Context) {
+	s.mu.Lock()
+	s.configGeneration++
+	s.rootAnalyzers = make(map[uri.URI]*analysis.Analyzer)
+	open := make([]uri.URI, 0, len(s.languageIDs))
+	for u := range s.languageIDs {
+		open = append(open, u)
+	}
+	s.mu.Unlock()
+
+	for _, u := range open {
+		_ = s.publishDiagnostics(ctx, protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: u},
+		}, s.diagnostics(ctx, u))
+	}
+}
+
+func (s *Server) showWarning(ctx context.Context, message string) {
+	protocol.LoggerFromContext(ctx).Warn(message)
+	_ = s.notifier.ShowMessage(ctx, &protocol.ShowMessageParams{
+		Type:    protocol.MessageTypeWarning,
+		Message: message,
+	})
+}
@@ -0,0 +1,181 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+	"github.com/tilt-dev/starlark-lsp/pkg/server"
+)
+
+// configuredAnalyzer creates analyzers with a typed `timeout()` builtin,
+// configured by the settings of the editor.
+func configuredAnalyzer(ctx context.Context, _ uri.URI, cfg *config.Config) (*analysis.Analyzer, error) {
+	opts := []analysis.AnalyzerOption{analysis.WithBuiltins(fstest.MapFS{
+		"__init__.py": {Data: []byte("def timeout(seconds: float):\n  pass\n")},
+	})}
+	return analysis.NewAnalyzer(ctx, append(opts, cfg.AnalyzerOptions(ctx)...)...)
+}
+
+// requireNextDiagnostics skips editor events until diagnostics are published
+// for the document and returns their codes.
+func (f *fixture) requireNextDiagnostics(u uri.URI) []interface{} {
+	f.t.Helper()
+	for {
+		select {
+		case <-time.After(time.Second):
+			require.FailNow(f.t, "Timed out waiting for diagnostics")
+		case event := <-f.editorEvents:
+			if event.Method() != protocol.MethodTextDocumentPublishDiagnostics {
+				continue
+			}
+			var params protocol.PublishDiagnosticsParams
+			require.NoError(f.t, json.Unmarshal(event.Params(), &params))
+			if params.URI != u {
+				continue
+			}
+			codes := []interface{}{}
+			for _, d := range params.Diagnostics {
+				codes = append(codes, d.Code)
+			}
+			return codes
+		}
+	}
+}
+
+func (f *fixture) openConfiguredDocument(root string, caps protocol.ClientCapabilities) uri.URI {
+	f.t.Helper()
+	var resp protocol.InitializeResult
+	f.mustEditorCall(protocol.MethodInitialize, protocol.InitializeParams{
+		Capabilities:     caps,
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: string(uri.File(root)), Name: "root"}},
+	}, &resp)
+
+	docURI := uri.File(filepath.Join(root, "Tiltfile"))
+	var syncResp jsonrpc2.Response
+	f.mustEditorCall(protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: docURI, Text: "timeout('1s')\n"},
+	}, &syncResp)
+	require.Equal(f.t, []interface{}{}, f.requireNextDiagnostics(docURI))
+	return docURI
+}
+
+func TestDidChangeConfigurationPush(t *testing.T) {
+	f := newFixture(t, server.WithRootAnalyzerFunc(configuredAnalyzer))
+	docURI := f.openConfiguredDocument(t.TempDir(), protocol.ClientCapabilities{})
+
+	require.NoError(t, f.editorConn.Notify(f.ctx, protocol.MethodWorkspaceDidChangeConfiguration, protocol.DidChangeConfigurationParams{
+		Settings: map[string]interface{}{
+			"starlark": map[string]interface{}{"dialect": map[string]interface{}{"typeCheck": true}},
+		},
+	}))
+	require.Equal(t, []interface{}{analysis.TypeMismatchCode}, f.requireNextDiagnostics(docURI))
+
+	require.NoError(t, f.editorConn.Notify(f.ctx, protocol.MethodWorkspaceDidChangeConfiguration, protocol.DidChangeConfigurationParams{
+		Settings: map[string]interface{}{
+			"starlark": map[string]interface{}{
+				"dialect":     map[string]interface{}{"typeCheck": true},
+				"diagnostics": map[string]interface{}{"disabled": []string{analysis.TypeMismatchCode}},
+			},
+		},
+	}))
+	require.Equal(t, []interface{}{}, f.requireNextDiagnostics(docURI))
+}
+
+func TestDidChangeConfigurationPull(t *testing.T) {
+	f := newFixture(t, server.WithRootAnalyzerFunc(configuredAnalyzer))
+	root := t.TempDir()
+	docURI := f.openConfiguredDocument(root, protocol.ClientCapabilities{
+		Workspace: &protocol.WorkspaceClientCapabilities{Configuration: true},
+	})
+
+	f.setEditorSettings(uri.File(root), map[string]interface{}{"dialect": map[string]interface{}{"typeCheck": true}})
+	require.NoError(t, f.editorConn.Notify(f.ctx, protocol.MethodInitialized, protocol.InitializedParams{}))
+	require.Equal(t, []interface{}{analysis.TypeMismatchCode}, f.requireNextDiagnostics(docURI))
+
+	// settings without the section make the server pull them again
+	f.setEditorSettings(uri.File(root), nil)
+	require.NoError(t, f.editorConn.Notify(f.ctx, protocol.MethodWorkspaceDidChangeConfiguration, protocol.DidChangeConfigurationParams{
+		Settings: map[string]interface{}{},
+	}))
+	require.Equal(t, []interface{}{}, f.requireNextDiagnostics(docURI))
+}
+
+func TestConfiguredLoadAliases(t *testing.T) {
+	f := newFixture(t, server.WithRootAnalyzerFunc(configuredAnalyzer))
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "lib"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "lib", "k8s.star"), []byte("k8s = 1\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, config.FileNames[0]), []byte("load:\n  aliases:\n    \"@lib\": lib\n"), 0644))
+
+	var resp protocol.InitializeResult
+	f.mustEditorCall(protocol.MethodInitialize, protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: string(uri.File(root)), Name: "root"}},
+	}, &resp)
+
+	docURI := uri.File(filepath.Join(root, "Tiltfile"))
+	var syncResp jsonrpc2.Response
+	f.mustEditorCall(protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: docURI, Text: "load('@lib/k8s.star', 'k8s')\n"},
+	}, &syncResp)
+	require.Equal(t, []interface{}{}, f.requireNextDiagnostics(docURI))
+}
+
+func TestDidChangeConfigurationWhileCreatingAnalyzer(t *testing.T) {
+	building := make(chan struct{})
+	proceed := make(chan struct{})
+	var calls int32
+	slowAnalyzer := func(ctx context.Context, root uri.URI, cfg *config.Config) (*analysis.Analyzer, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(building)
+			<-proceed
+		}
+		return configuredAnalyzer(ctx, root, cfg)
+	}
+	f := newFixture(t, server.WithRootAnalyzerFunc(slowAnalyzer))
+	root := t.TempDir()
+	var resp protocol.InitializeResult
+	f.mustEditorCall(protocol.MethodInitialize, protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: string(uri.File(root)), Name: "root"}},
+	}, &resp)
+	docURI := uri.File(filepath.Join(root, "Tiltfile"))
+	f.mustWriteDocument(docURI.Filename(), "timeout('1s')\n")
+
+	// a request creates the analyzer with the settings from before the push
+	hovered := make(chan error, 1)
+	go func() {
+		_, err := f.server.Hover(f.ctx, &protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: docURI},
+			},
+		})
+		hovered <- err
+	}()
+	<-building
+	require.NoError(t, f.server.DidChangeConfiguration(f.ctx, &protocol.DidChangeConfigurationParams{
+		Settings: map[string]interface{}{
+			"starlark": map[string]interface{}{"dialect": map[string]interface{}{"typeCheck": true}},
+		},
+	}))
+	close(proceed)
+	require.NoError(t, <-hovered)
+
+	// the analyzer created with the old settings isn't kept
+	var syncResp jsonrpc2.Response
+	f.mustEditorCall(protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: docURI, Text: "timeout('1s')\n"},
+	}, &syncResp)
+	require.Equal(t, []interface{}{analysis.TypeMismatchCode}, f.requireNextDiagnostics(docURI))
+}
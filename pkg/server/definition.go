@@ -8,7 +8,7 @@ import (
 	"go.uber.org/zap"
 )
 
-func (s Server) Definition(ctx context.Context, params *protocol.DefinitionParams) (result []protocol.Location, err error) {
+func (s *Server) Definition(ctx context.Context, params *protocol.DefinitionParams) (result []protocol.Location, err error) {
 	doc, err := s.docs.Read(ctx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
@@ -19,7 +19,7 @@ func (s Server) Definition(ctx context.Context, params *protocol.DefinitionParam
 		With(textDocumentFields(params.TextDocumentPositionParams)...)
 	logger.Debug("definition")
 
-	positions := s.analyzer.Definition(ctx, doc, params.Position)
+	positions := s.analyzerFor(ctx, params.TextDocument.URI).Definition(ctx, doc, params.Position)
 	logger.With(zap.Namespace("definition")).Debug(fmt.Sprintf("found definition locations: %v", positions))
 
 	return positions, nil
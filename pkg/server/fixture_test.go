@@ -31,11 +31,17 @@ type fixture struct {
 	t            testing.TB
 	ctx          context.Context
 	docManager   *document.Manager
+	server       *server.Server
 	editorConn   jsonrpc2.Conn
 	editorEvents chan jsonrpc2.Request
+
+	mu sync.Mutex
+	// editorSettings are returned for `workspace/configuration` requests,
+	// by the scope URI of the requested items
+	editorSettings map[uri.URI]interface{}
 }
 
-func newFixture(t testing.TB) *fixture {
+func newFixture(t testing.TB, opts ...server.ServerOpt) *fixture {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	t.Cleanup(cancel)
 
@@ -55,7 +61,7 @@ func newFixture(t testing.TB) *fixture {
 
 	docManager := newDocumentManager(t)
 	analyzer, _ := analysis.NewAnalyzer(ctx)
-	s := server.NewServer(cancel, notifier, docManager, analyzer)
+	s := server.NewServer(cancel, notifier, docManager, analyzer, opts...)
 
 	// TODO(milas): AsyncHandler does not stop if the server is shut down which
 	// 	can cause panics in tests (due to logs being emitted after tests are
@@ -71,11 +77,19 @@ func newFixture(t testing.TB) *fixture {
 	h := s.Handler(testMiddleware...)
 	serverJsonConn.Go(protocol.WithLogger(ctx, logger.Named("server")), h)
 
+	f := &fixture{
+		t:              t,
+		ctx:            ctx,
+		docManager:     docManager,
+		server:         s,
+		editorSettings: make(map[uri.URI]interface{}),
+	}
+
 	editorStream := jsonrpc2.NewStream(editorConn)
 	editorJsonConn := jsonrpc2.NewConn(editorStream)
 	editorChan := make(chan jsonrpc2.Request, 20)
 	editorJsonConn.Go(protocol.WithLogger(ctx, logger.Named("editor")),
-		func(ctx context.Context, _ jsonrpc2.Replier, req jsonrpc2.Request) error {
+		func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
 			protocol.LoggerFromContext(ctx).Debug("received message",
 				zap.String("method", req.Method()),
 				zap.Int("len", len(req.Params())))
@@ -84,6 +98,9 @@ func newFixture(t testing.TB) *fixture {
 			default:
 				panic("editor channel was full")
 			}
+			if req.Method() == protocol.MethodWorkspaceConfiguration {
+				return reply(ctx, f.configuration(req), nil)
+			}
 			return nil
 		})
 
@@ -101,13 +118,27 @@ func newFixture(t testing.TB) *fixture {
 		<-serverJsonConn.Done()
 	})
 
-	return &fixture{
-		t:            t,
-		ctx:          ctx,
-		docManager:   docManager,
-		editorConn:   editorJsonConn,
-		editorEvents: editorChan,
+	f.editorConn = editorJsonConn
+	f.editorEvents = editorChan
+	return f
+}
+
+func (f *fixture) setEditorSettings(scope uri.URI, settings interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.editorSettings[scope] = settings
+}
+
+func (f *fixture) configuration(req jsonrpc2.Request) []interface{} {
+	var params protocol.ConfigurationParams
+	require.NoError(f.t, json.Unmarshal(req.Params(), &params))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result := make([]interface{}, len(params.Items))
+	for i, item := range params.Items {
+		result[i] = f.editorSettings[item.ScopeURI]
 	}
+	return result
 }
 
 func (f *fixture) mustWriteDocument(path string, source string) {
@@ -282,7 +313,7 @@ func (t *testDocument) Loads() []document.LoadStatement {
 }
 
 func (t *testDocument) URI() uri.URI {
-	return uri.New("file://test.txt")
+	return t.doc.URI()
 }
 
 func (t *testDocument) Copy() document.Document {
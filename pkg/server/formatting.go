@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"unicode/utf16"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+	"go.uber.org/zap"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/format"
+)
+
+// Formatting formats the whole document. Documents with syntax errors are
+// left alone.
+func (s *Server) Formatting(ctx context.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
+	opts := s.formatOptions(ctx, params.TextDocument.URI)
+	doc, err := s.docs.Read(ctx, params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	formatted, err := format.Tree(doc.Input(), doc.Tree(), opts)
+	if err != nil {
+		protocol.LoggerFromContext(ctx).Debug("not formatting document",
+			zap.String("uri", string(params.TextDocument.URI)), zap.Error(err))
+		return []protocol.TextEdit{}, nil
+	}
+	if bytes.Equal(formatted, doc.Input()) {
+		return []protocol.TextEdit{}, nil
+	}
+	return []protocol.TextEdit{{
+		Range:   protocol.Range{End: endPosition(doc.Input())},
+		NewText: string(formatted),
+	}}, nil
+}
+
+// formatOptions returns the style of the configuration of the document's
+// workspace folder. The indentation of the editor is ignored, so that
+// documents are formatted the same way as by `starlark-lsp format`.
+func (s *Server) formatOptions(ctx context.Context, u uri.URI) format.Options {
+	var opts format.Options
+	root := s.docs.Root(u)
+	if root == "" {
+		return opts
+	}
+	// errors of the configuration are reported when the workspace folder's
+	// analyzer is created
+	cfg, err := s.rootConfig(ctx, root)
+	if err != nil {
+		return opts
+	}
+	return cfg.FormatOptions()
+}
+
+// endPosition returns the position at the end of the text, with the
+// character offset counted in UTF-16 code units.
+func endPosition(text []byte) protocol.Position {
+	last := text[bytes.LastIndexByte(text, '\n')+1:]
+	return protocol.Position{
+		Line:      uint32(bytes.Count(text, []byte("\n"))),
+		Character: uint32(len(utf16.Encode([]rune(string(last))))),
+	}
+}
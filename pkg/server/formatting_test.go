@@ -0,0 +1,50 @@
+package server_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+)
+
+func TestServer_Formatting(t *testing.T) {
+	f := newFixture(t)
+	root := t.TempDir()
+	var resp protocol.InitializeResult
+	f.mustEditorCall(protocol.MethodInitialize, protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: string(uri.File(root)), Name: "root"}},
+	}, &resp)
+	assert.True(t, resp.Capabilities.DocumentFormattingProvider.(bool))
+
+	formatting := func(path, source string) []protocol.TextEdit {
+		t.Helper()
+		f.mustWriteDocument(path, source)
+		var edits []protocol.TextEdit
+		f.mustEditorCall(protocol.MethodTextDocumentFormatting, protocol.DocumentFormattingParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri.File(path)},
+			Options:      protocol.FormattingOptions{TabSize: 2, InsertSpaces: true},
+		}, &edits)
+		return edits
+	}
+
+	// the editor's indentation is ignored, as by `starlark-lsp format`
+	outside := filepath.Join(t.TempDir(), "Tiltfile")
+	assert.Equal(t, []protocol.TextEdit{{
+		Range:   protocol.Range{End: protocol.Position{Line: 1, Character: 10}},
+		NewText: "if x:\n    y('é')\n",
+	}}, formatting(outside, "if x:\n  y( 'é' )"))
+	assert.Empty(t, formatting(outside, "if x:\n    y('é')\n"))
+	assert.Empty(t, formatting(outside, "if x:\ny("))
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, config.FileNames[0]), []byte("format:\n  indentWidth: 2\n  quote: double\n"), 0644))
+	assert.Equal(t, []protocol.TextEdit{{
+		Range:   protocol.Range{End: protocol.Position{Line: 2, Character: 0}},
+		NewText: "if x:\n  y(\"é\")\n",
+	}}, formatting(filepath.Join(root, "Tiltfile"), "if x:\n    y('é')\n"))
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
 )
 
 func (s *Server) Hover(ctx context.Context, params *protocol.HoverParams) (result *protocol.Hover, err error) {
@@ -17,5 +19,6 @@ func (s *Server) Hover(ctx context.Context, params *protocol.HoverParams) (resul
 		With(textDocumentFields(params.TextDocumentPositionParams)...)
 	logger.Debug("hover")
 
-	return s.analyzer.Hover(ctx, doc, params.Position), nil
+	ctx = analysis.WithClientCapabilities(ctx, s.clientCapabilities)
+	return s.analyzerFor(ctx, params.TextDocument.URI).Hover(ctx, doc, params.Position), nil
 }
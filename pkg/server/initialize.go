@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
 )
 
 func (s *Server) Initialize(ctx context.Context,
@@ -13,6 +15,7 @@ func (s *Server) Initialize(ctx context.Context,
 		Type:    protocol.MessageTypeLog,
 	})
 
+	s.clientCapabilities = params.Capabilities
 	s.docs.Initialize(params)
 	return &protocol.InitializeResult{
 		Capabilities: protocol.ServerCapabilities{
@@ -30,9 +33,27 @@ func (s *Server) Initialize(ctx context.Context,
 			DocumentSymbolProvider: true,
 			CompletionProvider: &protocol.CompletionOptions{
 				TriggerCharacters: []string{"."},
+				ResolveProvider:   true,
+			},
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			DocumentFormattingProvider: true,
+			ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
+				Commands: []string{analysis.CompletionItemAcceptedCommand},
+			},
+			Workspace: &protocol.ServerCapabilitiesWorkspace{
+				WorkspaceFolders: &protocol.ServerCapabilitiesWorkspaceFolders{
+					Supported:           true,
+					ChangeNotifications: true,
+				},
 			},
-			HoverProvider:      true,
-			DefinitionProvider: true,
 		},
 	}, nil
 }
+
+// Initialized pulls the settings of the editor once it's ready to respond
+// to requests from the server.
+func (s *Server) Initialized(ctx context.Context, params *protocol.InitializedParams) (err error) {
+	s.pullConfiguration(ctx)
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"go.lsp.dev/protocol"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
 )
 
 func TestInitialize(t *testing.T) {
@@ -30,9 +32,20 @@ func TestInitialize(t *testing.T) {
 			DocumentSymbolProvider: true,
 			CompletionProvider: &protocol.CompletionOptions{
 				TriggerCharacters: []string{"."},
+				ResolveProvider:   true,
+			},
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			DocumentFormattingProvider: true,
+			ExecuteCommandProvider: &protocol.ExecuteCommandOptions{
+				Commands: []string{analysis.CompletionItemAcceptedCommand},
+			},
+			Workspace: &protocol.ServerCapabilitiesWorkspace{
+				WorkspaceFolders: &protocol.ServerCapabilitiesWorkspaceFolders{
+					Supported:           true,
+					ChangeNotifications: true,
+				},
 			},
-			HoverProvider:      true,
-			DefinitionProvider: true,
 		},
 	}
 	requireJsonEqual(t, expected, resp)
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/pkg/xcontext"
+	"go.lsp.dev/protocol"
+)
+
+// lifecycle tracks the requests of a session and where the session is in
+// the lifecycle of the protocol: requests are handled until the client
+// sends the shutdown request, after which they're rejected until the
+// client sends the exit notification.
+type lifecycle struct {
+	mu       sync.Mutex
+	shutdown bool
+	exited   bool
+
+	// requests are the requests being handled, including those queued by
+	// asynchronous handlers
+	requests activity
+	// background is the work started by requests that outlives them, like
+	// pulling the configuration
+	background activity
+	// closing is closed to cancel the background work once the server
+	// shuts down
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{closing: make(chan struct{})}
+}
+
+// Shutdown rejects further requests, cancels the background work and waits
+// for it to stop, and closes the documents. The server exits once the
+// client sends the exit notification.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	s.lifecycle.mu.Lock()
+	s.lifecycle.shutdown = true
+	s.lifecycle.mu.Unlock()
+
+	err = s.stopBackground(ctx)
+	s.docs.Close()
+	return err
+}
+
+// Exit stops the server. The exit code it should exit with is then given by
+// ExitCode.
+func (s *Server) Exit(ctx context.Context) (err error) {
+	s.lifecycle.mu.Lock()
+	s.lifecycle.exited = true
+	s.lifecycle.mu.Unlock()
+
+	s.cancel()
+	return nil
+}
+
+// ExitCode returns the status code to exit with, and whether the client has
+// sent the exit notification. As the protocol specifies, the code is 0 if
+// the client sent the shutdown request before, and 1 otherwise.
+func (s *Server) ExitCode() (code int, exited bool) {
+	s.lifecycle.mu.Lock()
+	defer s.lifecycle.mu.Unlock()
+	if !s.lifecycle.shutdown {
+		code = 1
+	}
+	return code, s.lifecycle.exited
+}
+
+// Close waits for the requests still being handled once the connection to
+// the client is closed, e.g. those queued by asynchronous handlers, and for
+// the background work to stop, and closes the documents, as Shutdown does
+// for clients that exit or disconnect without shutting down. It returns the
+// error of the context if it's done before the requests are.
+func (s *Server) Close(ctx context.Context) error {
+	err := s.lifecycle.requests.wait(ctx)
+	if bgErr := s.stopBackground(ctx); err == nil {
+		err = bgErr
+	}
+	s.docs.Close()
+	return err
+}
+
+// stopBackground cancels the background work and waits for it to stop, or
+// for the context to be done.
+func (s *Server) stopBackground(ctx context.Context) error {
+	s.lifecycle.closeOnce.Do(func() {
+		close(s.lifecycle.closing)
+	})
+	return s.lifecycle.background.wait(ctx)
+}
+
+// goBackground runs work started by a request in the background, with a
+// context that isn't canceled along with the request but once the server
+// shuts down. Work isn't started after that.
+func (s *Server) goBackground(ctx context.Context, work func(ctx context.Context)) {
+	select {
+	case <-s.lifecycle.closing:
+		return
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(xcontext.Detach(ctx))
+	s.lifecycle.background.start()
+	go func() {
+		defer s.lifecycle.background.done()
+		defer cancel()
+		go func() {
+			select {
+			case <-s.lifecycle.closing:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		work(ctx)
+	}()
+}
+
+// trackRequests counts the requests being handled until they're replied
+// to. It comes before any asynchronous handler, so that requests queued
+// by it are counted too.
+func (s *Server) trackRequests(next jsonrpc2.Handler) jsonrpc2.Handler {
+	return func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		s.lifecycle.requests.start()
+		var once sync.Once
+		done := func() {
+			once.Do(s.lifecycle.requests.done)
+		}
+		err := next(ctx, func(ctx context.Context, result interface{}, err error) error {
+			defer done()
+			return reply(ctx, result, err)
+		}, req)
+		if err != nil {
+			// the request failed without being handled
+			done()
+		}
+		return err
+	}
+}
+
+// checkLifecycle rejects the requests sent after the shutdown request, or
+// the exit notification, other than exit, as the protocol specifies.
+func (s *Server) checkLifecycle(next jsonrpc2.Handler) jsonrpc2.Handler {
+	return func(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
+		s.lifecycle.mu.Lock()
+		rejected := (s.lifecycle.shutdown || s.lifecycle.exited) && req.Method() != protocol.MethodExit
+		s.lifecycle.mu.Unlock()
+		if rejected {
+			return reply(ctx, nil, jsonrpc2.NewError(jsonrpc2.InvalidRequest,
+				fmt.Sprintf("%s: server is shut down", req.Method())))
+		}
+		return next(ctx, reply, req)
+	}
+}
+
+// activity counts work in progress. Unlike with a sync.WaitGroup, work can
+// start while the activity is waited for, e.g. a request that was read from
+// the connection as it was closed.
+type activity struct {
+	mu    sync.Mutex
+	count int
+	// idle is closed once no work is in progress anymore
+	idle chan struct{}
+}
+
+func (a *activity) start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.count == 0 {
+		a.idle = make(chan struct{})
+	}
+	a.count++
+}
+
+func (a *activity) done() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.count--
+	if a.count == 0 {
+		close(a.idle)
+	}
+}
+
+// wait waits for the work in progress to be done, or for the context to be
+// done.
+func (a *activity) wait(ctx context.Context) error {
+	a.mu.Lock()
+	idle := a.idle
+	busy := a.count > 0
+	a.mu.Unlock()
+	if !busy {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
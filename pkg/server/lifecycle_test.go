@@ -0,0 +1,111 @@
+package server_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/jsonrpc2"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+// requireInvalidRequest fails the test if the error isn't an InvalidRequest
+// error of the server.
+func requireInvalidRequest(t testing.TB, err error) {
+	t.Helper()
+	var rpcErr *jsonrpc2.Error
+	require.True(t, errors.As(err, &rpcErr), "expected a JSON-RPC error, got %v", err)
+	assert.Equal(t, jsonrpc2.InvalidRequest, rpcErr.Code)
+}
+
+// requireExited waits for the server to handle the exit notification, which
+// cancels the context of the fixture.
+func (f *fixture) requireExited() {
+	f.t.Helper()
+	select {
+	case <-f.ctx.Done():
+	case <-time.After(time.Second):
+		require.Fail(f.t, "Timed out waiting for the server to exit")
+	}
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	f := newFixture(t)
+
+	var resp protocol.InitializeResult
+	f.mustEditorCall(protocol.MethodInitialize, protocol.InitializeParams{}, &resp)
+	f.mustEditorCall(protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:  uri.File("./test.star"),
+			Text: "x = 1\n",
+		},
+	}, nil)
+	require.Len(t, f.docManager.Keys(), 1)
+
+	f.mustEditorCall(protocol.MethodShutdown, nil, nil)
+	// the documents are closed
+	assert.Empty(t, f.docManager.Keys())
+
+	// requests after shutdown are rejected
+	_, err := f.editorConn.Call(f.ctx, protocol.MethodTextDocumentHover, protocol.HoverParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri.File("./test.star")},
+		},
+	}, nil)
+	requireInvalidRequest(t, err)
+	_, err = f.editorConn.Call(f.ctx, protocol.MethodShutdown, nil, nil)
+	requireInvalidRequest(t, err)
+
+	_, exited := f.server.ExitCode()
+	assert.False(t, exited)
+
+	require.NoError(t, f.editorConn.Notify(f.ctx, protocol.MethodExit, nil))
+	f.requireExited()
+	code, exited := f.server.ExitCode()
+	assert.True(t, exited)
+	assert.Equal(t, 0, code)
+}
+
+func TestServer_ExitWithoutShutdown(t *testing.T) {
+	f := newFixture(t)
+
+	var resp protocol.InitializeResult
+	f.mustEditorCall(protocol.MethodInitialize, protocol.InitializeParams{}, &resp)
+	require.NoError(t, f.editorConn.Notify(f.ctx, protocol.MethodExit, nil))
+	f.requireExited()
+
+	code, exited := f.server.ExitCode()
+	assert.True(t, exited)
+	assert.Equal(t, 1, code)
+}
+
+func TestServer_CloseWaitsForRequests(t *testing.T) {
+	f := newFixture(t)
+	f.mustWriteDocument("./test.star", "x = 1\n")
+
+	// the request is being handled until its reply is sent
+	handling := make(chan struct{})
+	release := make(chan struct{})
+	req, err := jsonrpc2.NewCall(jsonrpc2.NewNumberID(1), protocol.MethodWorkspaceSymbol, protocol.WorkspaceSymbolParams{})
+	require.NoError(t, err)
+	go func() {
+		_ = f.server.Handler(jsonrpc2.ReplyHandler)(f.ctx, func(ctx context.Context, result interface{}, err error) error {
+			close(handling)
+			<-release
+			return nil
+		}, req)
+	}()
+	<-handling
+
+	ctx, cancel := context.WithTimeout(f.ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, f.server.Close(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, f.server.Close(f.ctx))
+	assert.Empty(t, f.docManager.Keys())
+}
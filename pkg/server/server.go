@@ -2,11 +2,15 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"go.lsp.dev/jsonrpc2"
 	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
 
 	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
 	"github.com/tilt-dev/starlark-lsp/pkg/document"
 	"github.com/tilt-dev/starlark-lsp/pkg/middleware"
 )
@@ -24,27 +28,128 @@ type Server struct {
 	docs *document.Manager
 	// analyzer performs queries on Document objects to build LSP responses
 	analyzer *analysis.Analyzer
+	// clientCapabilities are the capabilities advertised by the editor
+	// during initialization
+	clientCapabilities protocol.ClientCapabilities
+	// completionHistory tracks recently accepted completion items
+	completionHistory *analysis.CompletionHistory
+
+	// rootAnalyzerFunc creates analyzers for workspace folders; if nil,
+	// analyzer is used for all of them
+	rootAnalyzerFunc RootAnalyzerFunc
+	mu               sync.Mutex
+	rootAnalyzers    map[uri.URI]*analysis.Analyzer
+	// configGeneration is incremented when the settings change, so that
+	// analyzers created with older settings aren't kept
+	configGeneration int
+	// languageIDs are the languages of the open documents, which select
+	// their builtin profiles
+	languageIDs map[uri.URI]string
+	// settings are the settings pushed by the editor for all workspace
+	// folders, rootSettings those pulled for each folder
+	settings     interface{}
+	rootSettings map[uri.URI]interface{}
+
+	lifecycle *lifecycle
+}
+
+// RootAnalyzerFunc creates the analyzer for the documents within a workspace
+// folder, e.g. with the builtins configured for that folder. The
+// configuration is read from the configuration file of the folder, with the
+// settings of the editor applied.
+type RootAnalyzerFunc func(ctx context.Context, root uri.URI, cfg *config.Config) (*analysis.Analyzer, error)
+
+type ServerOpt func(s *Server)
+
+// WithRootAnalyzerFunc uses separate analyzers for each workspace folder.
+// The analyzer passed to NewServer is still used for documents outside of
+// the workspace folders and if creating the analyzer for a folder fails.
+func WithRootAnalyzerFunc(fn RootAnalyzerFunc) ServerOpt {
+	return func(s *Server) {
+		s.rootAnalyzerFunc = fn
+	}
 }
 
-func NewServer(cancel context.CancelFunc, notifier protocol.Client, docManager *document.Manager, analyzer *analysis.Analyzer) *Server {
-	return &Server{
+func NewServer(cancel context.CancelFunc, notifier protocol.Client, docManager *document.Manager, analyzer *analysis.Analyzer, opts ...ServerOpt) *Server {
+	s := &Server{
 		cancel:   cancel,
 		notifier: notifier,
 		docs:     docManager,
 		analyzer: analyzer,
+
+		completionHistory: analysis.NewCompletionHistory(),
+		rootAnalyzers:     make(map[uri.URI]*analysis.Analyzer),
+		languageIDs:       make(map[uri.URI]string),
+		rootSettings:      make(map[uri.URI]interface{}),
+		lifecycle:         newLifecycle(),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
-func (s *Server) Handler(middlewares ...middleware.Middleware) jsonrpc2.Handler {
-	serverHandler := protocol.ServerHandler(s, jsonrpc2.MethodNotFoundHandler)
-	return middleware.WrapHandler(serverHandler, middlewares...)
+// analyzerFor returns the analyzer for the document, using the builtin
+// profile that matches the document within the analyzer of the workspace
+// folder that contains it.
+func (s *Server) analyzerFor(ctx context.Context, u uri.URI) *analysis.Analyzer {
+	analyzer := s.rootAnalyzer(ctx, u)
+
+	s.mu.Lock()
+	languageID := s.languageIDs[u]
+	s.mu.Unlock()
+	return analyzer.ForDocument(u, languageID)
 }
 
-func (s *Server) Shutdown(ctx context.Context) (err error) {
-	return nil
+// rootAnalyzer returns the analyzer for the workspace folder that contains
+// the document. Analyzers are created when they are first needed.
+func (s *Server) rootAnalyzer(ctx context.Context, u uri.URI) *analysis.Analyzer {
+	if s.rootAnalyzerFunc == nil {
+		return s.analyzer
+	}
+	root := s.docs.Root(u)
+	if root == "" {
+		return s.analyzer
+	}
+
+	s.mu.Lock()
+	analyzer, found := s.rootAnalyzers[root]
+	generation := s.configGeneration
+	s.mu.Unlock()
+	if found {
+		return analyzer
+	}
+
+	cfg, err := s.rootConfig(ctx, root)
+	if err == nil {
+		s.docs.SetLoadPaths(ctx, root, cfg.DocumentLoadPaths())
+		analyzer, err = s.rootAnalyzerFunc(ctx, root, cfg)
+	}
+	if err != nil {
+		s.showWarning(ctx, fmt.Sprintf("Failed to configure workspace folder %s: %v", root.Filename(), err))
+		analyzer = s.analyzer
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.configGeneration != generation {
+		// the settings changed while the analyzer was created, so it's only
+		// used for this request
+		return analyzer
+	}
+	if existing, found := s.rootAnalyzers[root]; found {
+		// created concurrently by another request
+		return existing
+	}
+	s.rootAnalyzers[root] = analyzer
+	return analyzer
 }
 
-func (s *Server) Exit(ctx context.Context) (err error) {
-	s.cancel()
-	return nil
+// Handler returns the handler of the requests of the client, with the
+// middlewares applied in order. Requests are tracked before the middlewares,
+// and rejected after them once the server is shut down.
+func (s *Server) Handler(middlewares ...middleware.Middleware) jsonrpc2.Handler {
+	serverHandler := protocol.ServerHandler(s, jsonrpc2.MethodNotFoundHandler)
+	all := append([]middleware.Middleware{s.trackRequests}, middlewares...)
+	all = append(all, s.checkLifecycle)
+	return middleware.WrapHandler(serverHandler, all...)
 }
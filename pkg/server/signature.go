@@ -18,7 +18,7 @@ func (s *Server) SignatureHelp(ctx context.Context,
 	}
 	defer doc.Close()
 
-	resp := s.analyzer.SignatureHelp(doc, params.Position)
+	resp := s.analyzerFor(ctx, params.TextDocument.URI).SignatureHelp(doc, params.Position)
 	if resp != nil && len(resp.Signatures) != 0 {
 		logger.With(
 			zap.Namespace("signature"),
@@ -3,26 +3,9 @@ package server
 import (
 	"context"
 
-	"github.com/tilt-dev/starlark-lsp/pkg/query"
-
 	"go.lsp.dev/protocol"
 )
 
-func toDocumentSymbol(s query.Symbol) protocol.DocumentSymbol {
-	var children []protocol.DocumentSymbol
-	for _, c := range s.Children {
-		children = append(children, toDocumentSymbol(c))
-	}
-	return protocol.DocumentSymbol{
-		Name:     s.Name,
-		Detail:   s.Detail,
-		Kind:     s.Kind,
-		Tags:     s.Tags,
-		Range:    s.Location.Range,
-		Children: children,
-	}
-}
-
 func (s *Server) DocumentSymbol(ctx context.Context,
 	params *protocol.DocumentSymbolParams) ([]interface{}, error) {
 
@@ -35,7 +18,7 @@ func (s *Server) DocumentSymbol(ctx context.Context,
 	symbols := doc.Symbols()
 	result := make([]interface{}, len(symbols))
 	for i := range symbols {
-		result[i] = toDocumentSymbol(symbols[i])
+		result[i] = symbols[i].DocumentSymbol()
 	}
 	return result, nil
 }
@@ -4,12 +4,23 @@ import (
 	"context"
 
 	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
 )
 
 func (s *Server) DidOpen(ctx context.Context, params *protocol.DidOpenTextDocumentParams) (err error) {
 	uri := params.TextDocument.URI
+	s.mu.Lock()
+	s.languageIDs[uri] = string(params.TextDocument.LanguageID)
+	s.mu.Unlock()
+
 	contents := []byte(params.TextDocument.Text)
 	_, err = s.docs.Write(ctx, uri, contents)
+	if err == nil {
+		_ = s.publishDiagnostics(ctx, protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+			Version:                params.TextDocument.Version,
+		}, s.diagnostics(ctx, uri))
+	}
 	return err
 }
 
@@ -21,6 +32,9 @@ func (s *Server) DidChange(ctx context.Context, params *protocol.DidChangeTextDo
 	uri := params.TextDocument.URI
 	contents := []byte(params.ContentChanges[0].Text)
 	diags, err := s.docs.Write(ctx, uri, contents)
+	if err == nil {
+		diags = s.diagnostics(ctx, uri)
+	}
 	_ = s.publishDiagnostics(ctx, params.TextDocument, diags)
 	return err
 }
@@ -34,6 +48,9 @@ func (s *Server) DidSave(ctx context.Context, params *protocol.DidSaveTextDocume
 
 func (s *Server) DidClose(_ context.Context, params *protocol.DidCloseTextDocumentParams) (err error) {
 	s.docs.Remove(params.TextDocument.URI)
+	s.mu.Lock()
+	delete(s.languageIDs, params.TextDocument.URI)
+	s.mu.Unlock()
 	return nil
 }
 
@@ -47,3 +64,16 @@ func (s *Server) publishDiagnostics(ctx context.Context, textDoc protocol.Versio
 		Diagnostics: diags,
 	})
 }
+
+// diagnostics analyzes the document and returns its diagnostics.
+func (s *Server) diagnostics(ctx context.Context, u uri.URI) []protocol.Diagnostic {
+	// creating the analyzer can change the load paths of the document, so
+	// it's read afterwards
+	analyzer := s.analyzerFor(ctx, u)
+	doc, err := s.docs.Read(ctx, u)
+	if err != nil {
+		return nil
+	}
+	defer doc.Close()
+	return analyzer.Diagnostics(doc)
+}
@@ -1,13 +1,21 @@
 package server_test
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
+	"testing/fstest"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.lsp.dev/jsonrpc2"
 	"go.lsp.dev/protocol"
 	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+	"github.com/tilt-dev/starlark-lsp/pkg/server"
 )
 
 func TestServer_DidOpen(t *testing.T) {
@@ -79,3 +87,72 @@ func TestServer_DidClose(t *testing.T) {
 	require.ErrorIs(t, os.ErrNotExist, err, "file does not exist", "Document should no longer exist")
 	require.Zero(t, doc, "Document was not zero-value")
 }
+
+func TestServer_DidOpenPublishesDiagnostics(t *testing.T) {
+	f := newFixture(t)
+
+	var resp jsonrpc2.Response
+	f.mustEditorCall(protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{
+			URI:     uri.File("./test.star"),
+			Version: 3,
+			Text:    "foo(1)",
+		},
+	}, &resp)
+
+	var params protocol.PublishDiagnosticsParams
+	f.requireNextEditorEvent(protocol.MethodTextDocumentPublishDiagnostics, &params)
+	require.Equal(t, uint32(3), params.Version)
+	require.Equal(t, uri.File("./test.star"), params.URI)
+}
+
+// profileBuiltins creates analyzers with a `describe()` builtin in the
+// default builtins and in a profile for the "skylark" language.
+func profileBuiltins(ctx context.Context, _ uri.URI, _ *config.Config) (*analysis.Analyzer, error) {
+	sky, err := analysis.LoadBuiltinsFromSource(ctx, []byte("def describe():\n  \"\"\"Builtin of skylark.\"\"\"\n  pass\n"), "sky.py")
+	if err != nil {
+		return nil, err
+	}
+	return analysis.NewAnalyzer(ctx,
+		analysis.WithBuiltins(fstest.MapFS{
+			"__init__.py": {Data: []byte("def describe():\n  \"\"\"Builtin of starlark.\"\"\"\n  pass\n")},
+		}),
+		analysis.WithProfiles(analysis.Profile{Name: "sky", LanguageIDs: []string{"skylark"}, Builtins: sky}),
+	)
+}
+
+func TestServer_DidOpenSelectsProfile(t *testing.T) {
+	f := newFixture(t, server.WithRootAnalyzerFunc(profileBuiltins))
+	root := t.TempDir()
+
+	var resp protocol.InitializeResult
+	f.mustEditorCall(protocol.MethodInitialize, protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: string(uri.File(root)), Name: "root"}},
+	}, &resp)
+
+	hover := func(u uri.URI) string {
+		t.Helper()
+		var result *protocol.Hover
+		f.mustEditorCall(protocol.MethodTextDocumentHover, protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: u},
+				Position:     protocol.Position{Character: 2},
+			},
+		}, &result)
+		require.NotNil(t, result)
+		return result.Contents.Value
+	}
+
+	docURI := uri.File(filepath.Join(root, "defs.sky"))
+	var syncResp jsonrpc2.Response
+	f.mustEditorCall(protocol.MethodTextDocumentDidOpen, protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: docURI, LanguageID: "skylark", Text: "describe()"},
+	}, &syncResp)
+	assert.Contains(t, hover(docURI), "Builtin of skylark.")
+
+	f.mustEditorCall(protocol.MethodTextDocumentDidClose, protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: docURI},
+	}, &syncResp)
+	f.mustWriteDocument(docURI.Filename(), "describe()")
+	assert.Contains(t, hover(docURI), "Builtin of starlark.")
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+)
+
+func (s *Server) DidChangeWorkspaceFolders(ctx context.Context, params *protocol.DidChangeWorkspaceFoldersParams) (err error) {
+	for _, folder := range params.Event.Removed {
+		s.docs.RemoveRoot(uri.URI(folder.URI))
+	}
+	for _, folder := range params.Event.Added {
+		s.docs.AddRoot(uri.URI(folder.URI))
+	}
+
+	// drop the analyzers of removed folders, analyzers for added folders are
+	// created when they are first needed
+	roots := make(map[uri.URI]bool)
+	for _, root := range s.docs.Roots() {
+		roots[root] = true
+	}
+	s.mu.Lock()
+	for root := range s.rootAnalyzers {
+		if !roots[root] {
+			delete(s.rootAnalyzers, root)
+		}
+	}
+	for root := range s.rootSettings {
+		if !roots[root] {
+			delete(s.rootSettings, root)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(params.Event.Added) > 0 {
+		s.pullConfiguration(ctx)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/tilt-dev/starlark-lsp/pkg/analysis"
+	"github.com/tilt-dev/starlark-lsp/pkg/config"
+	"github.com/tilt-dev/starlark-lsp/pkg/server"
+)
+
+// rootBuiltins creates analyzers whose builtins define a `describe()`
+// function that mentions the name of the workspace folder.
+func rootBuiltins(ctx context.Context, root uri.URI, _ *config.Config) (*analysis.Analyzer, error) {
+	builtins := fmt.Sprintf("def describe():\n  \"\"\"Builtin of %s.\"\"\"\n  pass\n", filepath.Base(root.Filename()))
+	return analysis.NewAnalyzer(ctx, analysis.WithBuiltins(fstest.MapFS{
+		"__init__.py": {Data: []byte(builtins)},
+	}))
+}
+
+func TestMultiRootWorkspace(t *testing.T) {
+	f := newFixture(t, server.WithRootAnalyzerFunc(rootBuiltins))
+	frontend := filepath.Join(t.TempDir(), "frontend")
+	backend := filepath.Join(t.TempDir(), "backend")
+
+	var resp protocol.InitializeResult
+	f.mustEditorCall(protocol.MethodInitialize, protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{
+			{URI: string(uri.File(frontend)), Name: "frontend"},
+			{URI: string(uri.File(backend)), Name: "backend"},
+		},
+	}, &resp)
+	assert.Equal(t, []uri.URI{uri.File(frontend), uri.File(backend)}, f.docManager.Roots())
+
+	f.mustWriteDocument(filepath.Join(frontend, "Tiltfile"), "describe()")
+	f.mustWriteDocument(filepath.Join(backend, "Tiltfile"), "describe()")
+	hover := func(path string) string {
+		t.Helper()
+		var result *protocol.Hover
+		f.mustEditorCall(protocol.MethodTextDocumentHover, protocol.HoverParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri.File(path)},
+				Position:     protocol.Position{Character: 2},
+			},
+		}, &result)
+		if result == nil {
+			return ""
+		}
+		return result.Contents.Value
+	}
+	assert.Contains(t, hover(filepath.Join(frontend, "Tiltfile")), "Builtin of frontend.")
+	assert.Contains(t, hover(filepath.Join(backend, "Tiltfile")), "Builtin of backend.")
+
+	require.NoError(t, f.editorConn.Notify(f.ctx, protocol.MethodWorkspaceDidChangeWorkspaceFolders, protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Removed: []protocol.WorkspaceFolder{{URI: string(uri.File(backend)), Name: "backend"}},
+		},
+	}))
+	// documents outside of the workspace folders use the default analyzer
+	assert.Equal(t, "", hover(filepath.Join(backend, "Tiltfile")))
+	assert.Equal(t, []uri.URI{uri.File(frontend)}, f.docManager.Roots())
+}